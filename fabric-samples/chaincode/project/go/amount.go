@@ -0,0 +1,312 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultCurrency is the currency of the amounts created by InitLedger and
+// of the records converted by MigrateAmounts
+const defaultCurrency = "EUR"
+
+// Amount is an exact amount of money in minor units (cents) of its currency
+type Amount struct {
+	Value    int64  `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// NewAmount returns the amount of cents in the given currency
+func NewAmount(cents int64, currency string) Amount {
+	return Amount{Value: cents, Currency: currency}
+}
+
+// Add returns a + b, failing when the currencies differ or the sum overflows
+func (a Amount) Add(b Amount) (Amount, error) {
+	err := a.checkCurrency(b)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	if (b.Value > 0 && a.Value > math.MaxInt64-b.Value) || (b.Value < 0 && a.Value < math.MinInt64-b.Value) {
+		return Amount{}, newError(CodeInvalidAmount, "amount overflow adding %s to %s", b, a)
+	}
+
+	return Amount{Value: a.Value + b.Value, Currency: a.currency(b)}, nil
+}
+
+// Sub returns a - b, failing when the currencies differ or the difference
+// overflows
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b.Value == math.MinInt64 {
+		return Amount{}, newError(CodeInvalidAmount, "amount overflow subtracting %s from %s", b, a)
+	}
+
+	return a.Add(Amount{Value: -b.Value, Currency: b.Currency})
+}
+
+// LessThan reports whether a < b, failing when the currencies differ
+func (a Amount) LessThan(b Amount) (bool, error) {
+	err := a.checkCurrency(b)
+	if err != nil {
+		return false, err
+	}
+
+	return a.Value < b.Value, nil
+}
+
+// IsNegative reports whether the amount is below zero
+func (a Amount) IsNegative() bool {
+	return a.Value < 0
+}
+
+// IsPositive reports whether the amount is above zero
+func (a Amount) IsPositive() bool {
+	return a.Value > 0
+}
+
+func (a Amount) String() string {
+	sign := ""
+	value := a.Value
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	return fmt.Sprintf("%s%d.%02d %s", sign, value/100, value%100, a.Currency)
+}
+
+// checkCurrency allows amounts without a currency, such as the zero value,
+// to be combined with amounts of any currency
+func (a Amount) checkCurrency(b Amount) error {
+	if a.Currency != "" && b.Currency != "" && a.Currency != b.Currency {
+		return newError(CodeInvalidAmount, "can not combine amounts in %s and %s", a.Currency, b.Currency)
+	}
+
+	return nil
+}
+
+func (a Amount) currency(b Amount) string {
+	if a.Currency != "" {
+		return a.Currency
+	}
+
+	return b.Currency
+}
+
+// sumAmounts adds up the amounts, starting from zero in the given currency
+func sumAmounts(currency string, amounts ...Amount) (Amount, error) {
+	sum := NewAmount(0, currency)
+	for _, amount := range amounts {
+		var err error
+		sum, err = sum.Add(amount)
+		if err != nil {
+			return Amount{}, err
+		}
+	}
+
+	return sum, nil
+}
+
+// MigrateAmounts converts the money of owners and repair shops, the prices of
+// cars and malfunctions, and the amounts of listings and offers stored as
+// floating point numbers into cents of the given currency. Records that are
+// already migrated are left untouched, so the transaction can be repeated.
+// It returns the number of converted records and can only be called by
+// identities carrying the cars.admin attribute.
+func (s *SmartContract) MigrateAmounts(ctx contractapi.TransactionContextInterface, currency string) (int, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return 0, newError(CodeUnauthorized, "submitting client not authorized to migrate amounts, does not have %s role", adminAttribute)
+	}
+
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		changed, err := migrateRecord(ctx, queryResponse.Key, queryResponse.Value, currency, "money", "price")
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			migrated++
+		}
+	}
+
+	objectFields := map[string]string{
+		listingObjectType: "askingPrice",
+		offerObjectType:   "amount",
+		shopObjectType:    "money",
+	}
+	for objectType, field := range objectFields {
+		count, err := migrateObjects(ctx, objectType, currency, field)
+		if err != nil {
+			return 0, err
+		}
+		migrated += count
+	}
+
+	return migrated, nil
+}
+
+// migrateObjects converts the amount field of every record stored under the
+// composite key object type
+func migrateObjects(ctx contractapi.TransactionContextInterface, objectType string, currency string, field string) (int, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer resultIter.Close()
+
+	migrated := 0
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		changed, err := migrateRecord(ctx, queryResponse.Key, queryResponse.Value, currency, field)
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateRecord converts the legacy amount fields of a JSON record and stores
+// it back when anything changed
+func migrateRecord(ctx contractapi.TransactionContextInterface, key string, value []byte, currency string, fields ...string) (bool, error) {
+	recordAsBytes, changed, err := convertLegacyAmounts(value, currency, fields...)
+	if err != nil {
+		return false, newError(CodeInternal, "failed to migrate %s: %v", key, err)
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, ctx.GetStub().PutState(key, recordAsBytes)
+}
+
+// convertLegacyAmounts rewrites the given amount fields of a JSON record,
+// including the prices of its malfunctions, from plain numbers to cents. It
+// reports whether anything was converted.
+func convertLegacyAmounts(value []byte, currency string, fields ...string) ([]byte, bool, error) {
+	var record map[string]json.RawMessage
+	if json.Unmarshal(value, &record) != nil {
+		// index entries and other values that are not JSON objects
+		return value, false, nil
+	}
+
+	changed, err := migrateFields(record, currency, fields...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if rawMalfunctions, ok := record["malfunctions"]; ok {
+		var malfunctions []map[string]json.RawMessage
+		if json.Unmarshal(rawMalfunctions, &malfunctions) == nil {
+			malfunctionsChanged := false
+			for _, malfunction := range malfunctions {
+				converted, err := migrateFields(malfunction, currency, "price")
+				if err != nil {
+					return nil, false, newError(CodeInvalidArgument, "malfunctions: %v", err)
+				}
+				malfunctionsChanged = malfunctionsChanged || converted
+			}
+
+			if malfunctionsChanged {
+				record["malfunctions"], err = json.Marshal(malfunctions)
+				if err != nil {
+					return nil, false, err
+				}
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return value, false, nil
+	}
+
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return recordAsBytes, true, nil
+}
+
+// migrateFields replaces the fields of the record that hold a plain number
+// with the equivalent amount in cents
+func migrateFields(record map[string]json.RawMessage, currency string, fields ...string) (bool, error) {
+	changed := false
+	for _, field := range fields {
+		raw, ok := record[field]
+		if !ok {
+			continue
+		}
+
+		amount, legacy, err := legacyAmount(raw, currency)
+		if err != nil {
+			return false, newError(CodeInvalidArgument, "field %s: %v", field, err)
+		}
+		if !legacy {
+			continue
+		}
+
+		record[field], err = json.Marshal(amount)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// legacyAmount converts a floating point amount, as stored before amounts had
+// a currency, into cents. It reports false for values that are not numbers.
+func legacyAmount(raw json.RawMessage, currency string) (Amount, bool, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] == '{' || bytes.Equal(raw, []byte("null")) {
+		return Amount{}, false, nil
+	}
+
+	var value float64
+	err := json.Unmarshal(raw, &value)
+	if err != nil {
+		return Amount{}, false, nil
+	}
+
+	cents := math.Round(value * 100)
+	if cents >= math.MaxInt64 || cents < math.MinInt64 {
+		return Amount{}, false, newError(CodeInvalidAmount, "amount %v overflows", value)
+	}
+
+	return NewAmount(int64(cents), currency), true, nil
+}
@@ -0,0 +1,74 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmountArithmetic(t *testing.T) {
+	sum, err := NewAmount(10, "EUR").Add(NewAmount(20, "EUR"))
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(30, "EUR"), sum)
+	require.Equal(t, "0.30 EUR", sum.String())
+
+	_, err = NewAmount(10, "EUR").Add(NewAmount(20, "USD"))
+	requireError(t, err, CodeInvalidAmount, "can not combine amounts in EUR and USD")
+
+	_, err = NewAmount(math.MaxInt64, "EUR").Add(NewAmount(1, "EUR"))
+	require.Error(t, err)
+
+	_, err = NewAmount(math.MinInt64, "EUR").Sub(NewAmount(1, "EUR"))
+	require.Error(t, err)
+
+	difference, err := Amount{}.Sub(NewAmount(150, "EUR"))
+	require.NoError(t, err)
+	require.Equal(t, "-1.50 EUR", difference.String())
+}
+
+func TestMigrateAmounts(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	ctx.stub.MockTransactionStart("legacy")
+	require.NoError(t, ctx.stub.PutState("OWNER9", []byte(`{"id":9,"name":"Ana","money":10.1}`)))
+	require.NoError(t, ctx.stub.PutState(carKey("9"), []byte(`{"id":9,"owner":"9","price":5000.5,"malfunctions":[{"id":"1","price":0.29}]}`)))
+	shopKey, err := ctx.stub.CreateCompositeKey(shopObjectType, []string{"shop9"})
+	require.NoError(t, err)
+	require.NoError(t, ctx.stub.PutState(shopKey, []byte(`{"id":"shop9","money":250}`)))
+
+	_, err = s.MigrateAmounts(ctx, "EUR")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to migrate amounts, does not have cars.admin role")
+
+	ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+	migrated, err := s.MigrateAmounts(ctx, "EUR")
+	require.NoError(t, err)
+	require.Equal(t, 3, migrated)
+
+	migrated, err = s.MigrateOwnerPrivateData(ctx.withSalt())
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	owner, err := s.GetOwnerById(ctx, "OWNER9")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1010, "EUR"), owner.Money)
+
+	car, err := s.GetCarById(ctx, "9")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(500050, "EUR"), car.Price)
+	require.Equal(t, NewAmount(29, "EUR"), car.Malfunctions[0].Price)
+
+	shop, err := s.GetRepairShop(ctx, "shop9")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(25000, "EUR"), shop.Money)
+
+	// migrated records are not converted again
+	migrated, err = s.MigrateAmounts(ctx, "EUR")
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
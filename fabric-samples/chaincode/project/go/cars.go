@@ -8,7 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -18,26 +21,50 @@ type SmartContract struct {
 }
 
 type Owner struct {
-	Id      int     `json:"id"`
-	Name    string  `json:"name"`
-	Surname string  `json:"surname"`
-	Email   string  `json:"email"`
-	Money   float64 `json:"money"`
+	DocType  string `json:"docType"`
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+	Email    string `json:"email"`
+	Money    Amount `json:"money"`
+	ClientId string `json:"clientId"`
+	MSPID    string `json:"mspId"`
+	Salt     string `json:"salt"`
 }
 
 type Malfunction struct {
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Id          string    `json:"id"`
+	Description string    `json:"description"`
+	Price       Amount    `json:"price"`
+	Severity    string    `json:"severity"`
+	Status      string    `json:"status"`
+	ReportedAt  time.Time `json:"reportedAt"`
+	ReportedBy  string    `json:"reportedBy"`
+	ShopId      string    `json:"shopId,omitempty"`
+	RepairedAt  time.Time `json:"repairedAt"`
+	RecallId    string    `json:"recallId,omitempty"`
 }
 
 type Car struct {
-	Id           int           `json:"id"`
-	Make         string        `json:"make"`
-	Model        string        `json:"model"`
-	Color        string        `json:"color"`
-	Owner        string        `json:"owner"`
-	Malfunctions []Malfunction `json:"malfunctions"`
-	Price        float64       `json:"price"`
+	DocType          string            `json:"docType"`
+	Id               int               `json:"id"`
+	Vin              string            `json:"vin"`
+	Make             string            `json:"make"`
+	Model            string            `json:"model"`
+	Year             int               `json:"year"`
+	Mileage          int               `json:"mileage"`
+	Color            string            `json:"color"`
+	Owner            string            `json:"owner"`
+	Status           string            `json:"status"`
+	Malfunctions     []Malfunction     `json:"malfunctions"`
+	Price            Amount            `json:"price"`
+	ApprovedBuyer    string            `json:"approvedBuyer,omitempty"`
+	OdometerReadings []OdometerReading `json:"odometerReadings"`
+	MileageTampered  bool              `json:"mileageTampered"`
+	Shares           []OwnerShare      `json:"shares,omitempty"`
+	SaleMajority     int               `json:"saleMajority,omitempty"`
+	SaleConsents     []string          `json:"saleConsents,omitempty"`
+	ProposedShares   *SharesProposal   `json:"proposedShares,omitempty"`
 }
 
 type QueryResult struct {
@@ -45,188 +72,310 @@ type QueryResult struct {
 	Record *Car
 }
 
+// InitLedger puts the sample owners and cars in world state. The salts of
+// the owners are derived from the "salt" field of the transient map, or from
+// the transaction id when there is none. It refuses to run again once any of
+// them exists, so that it can not reset the balances and identity bindings
+// of the owners.
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	cars := []Car{
-		{Id: 1, Make: "Toyota", Model: "Prius", Color: "blue", Owner: "1",
+		{Id: 1, Vin: "JTDKN3DU6A0123456", Make: "Toyota", Model: "Prius", Year: 2010, Mileage: 182000, Color: "blue", Owner: "1",
 			Malfunctions: []Malfunction{
-				{Description: "Broken brake", Price: 200},
+				{Id: "1", Description: "Broken brake", Price: NewAmount(20000, defaultCurrency), Severity: SeverityHigh},
 			},
-			Price: 5000,
+			Price: NewAmount(500000, defaultCurrency),
 		},
-		{Id: 2, Make: "Ford", Model: "Mustang", Color: "blue", Owner: "3", Malfunctions: []Malfunction{}, Price: 3000},
-		{Id: 3, Make: "Hyundai", Model: "Tucson", Color: "green", Owner: "2",
+		{Id: 2, Vin: "1FA6P8CF2H5234567", Make: "Ford", Model: "Mustang", Year: 2017, Mileage: 64000, Color: "blue", Owner: "3", Malfunctions: []Malfunction{}, Price: NewAmount(300000, defaultCurrency)},
+		{Id: 3, Vin: "KM8J33A41GU345678", Make: "Hyundai", Model: "Tucson", Year: 2016, Mileage: 98000, Color: "green", Owner: "2",
 			Malfunctions: []Malfunction{
-				{Description: "Broken window", Price: 1000},
+				{Id: "1", Description: "Broken window", Price: NewAmount(100000, defaultCurrency), Severity: SeverityMedium},
 			},
-			Price: 2500,
+			Price: NewAmount(250000, defaultCurrency),
 		},
-		{Id: 4, Make: "Volkswagen", Model: "Passat", Color: "blue", Owner: "2", Malfunctions: []Malfunction{}, Price: 7000},
-		{Id: 5, Make: "Tesla", Model: "S", Color: "blue", Owner: "3", Malfunctions: []Malfunction{}, Price: 20000},
-		{Id: 6, Make: "Peugeot", Model: "205", Color: "black", Owner: "3", Malfunctions: []Malfunction{}, Price: 2000},
+		{Id: 4, Vin: "WVWZZZ3C58E456789", Make: "Volkswagen", Model: "Passat", Year: 2008, Mileage: 241000, Color: "blue", Owner: "2", Malfunctions: []Malfunction{}, Price: NewAmount(700000, defaultCurrency)},
+		{Id: 5, Vin: "5YJSA1E25HF567890", Make: "Tesla", Model: "S", Year: 2017, Mileage: 71000, Color: "blue", Owner: "3", Malfunctions: []Malfunction{}, Price: NewAmount(2000000, defaultCurrency)},
+		{Id: 6, Vin: "VF320ABC3GS678901", Make: "Peugeot", Model: "205", Year: 1990, Mileage: 305000, Color: "black", Owner: "3", Malfunctions: []Malfunction{}, Price: NewAmount(200000, defaultCurrency)},
 	}
 
 	owners := []Owner{
-		{Id: 1, Name: "Sara", Surname: "Poparic", Email: "sarapoparic@gmail.com", Money: 10000},
-		{Id: 2, Name: "Mila", Surname: "Poparic", Email: "milapoparic@gmail.com", Money: 5000},
-		{Id: 3, Name: "Nikola", Surname: "Nikolic", Email: "nikolanikolic@gmail.com", Money: 5000},
+		{Id: 1, Name: "Sara", Surname: "Poparic", Email: "sarapoparic@gmail.com", Money: NewAmount(1000000, defaultCurrency)},
+		{Id: 2, Name: "Mila", Surname: "Poparic", Email: "milapoparic@gmail.com", Money: NewAmount(500000, defaultCurrency)},
+		{Id: 3, Name: "Nikola", Surname: "Nikolic", Email: "nikolanikolic@gmail.com", Money: NewAmount(500000, defaultCurrency)},
 	}
 
 	for _, car := range cars {
-		carAsBytes, err := json.Marshal(car)
+		exists, err := s.CarExists(ctx, strconv.Itoa(car.Id))
 		if err != nil {
 			return err
 		}
+		if exists {
+			return newError(CodeAlreadyExists, "ledger is already initialized, car %d exists", car.Id)
+		}
+	}
+
+	for _, owner := range owners {
+		exists, err := s.OwnerExists(ctx, ownerKey(strconv.Itoa(owner.Id)))
+		if err != nil {
+			return err
+		}
+		if exists {
+			return newError(CodeAlreadyExists, "ledger is already initialized, owner %s exists", ownerKey(strconv.Itoa(owner.Id)))
+		}
+	}
+
+	reportedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	reportedBy, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, car := range cars {
+		car.DocType = carDocType
+		car.Status = CarActive
+		car.OdometerReadings = []OdometerReading{
+			{Mileage: car.Mileage, RecordedAt: reportedAt, RecordedBy: reportedBy},
+		}
+		for i := range car.Malfunctions {
+			car.Malfunctions[i].Status = MalfunctionOpen
+			car.Malfunctions[i].ReportedAt = reportedAt
+			car.Malfunctions[i].ReportedBy = reportedBy
+		}
 
-		err = ctx.GetStub().PutState(strconv.Itoa(car.Id), carAsBytes)
+		err = putCar(ctx, &car)
 		if err != nil {
-			return fmt.Errorf("failed to put car to world state. %v", err)
+			return err
 		}
 
-		indexName := "color~owner~id"
-		colorOwnerIndexKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{car.Color, car.Owner, strconv.Itoa(car.Id)})
+		err = putVinIndex(ctx, &car)
 		if err != nil {
 			return err
 		}
+	}
+
+	// the owners join the organization of the client that initializes the
+	// ledger, whose collection then holds them
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	// deploying with an init function runs InitLedger without a transient
+	// map, the salts then come from the transaction id. That hides nothing
+	// the source of the sample owners does not give away already.
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return errLedger("error getting transient: %v", err)
+	}
 
-		value := []byte{0x00}
-		err = ctx.GetStub().PutState(colorOwnerIndexKey, value)
+	salt := []byte(ctx.GetStub().GetTxID())
+	if _, ok := transientMap[saltTransientKey]; ok {
+		salt, err = readSalt(ctx)
 		if err != nil {
 			return err
 		}
 	}
 
 	for _, owner := range owners {
-		ownerAsBytes, _ := json.Marshal(owner)
-		err := ctx.GetStub().PutState("OWNER"+strconv.Itoa(owner.Id), ownerAsBytes)
+		owner.MSPID = mspID
+		owner.Salt = deriveSalt(salt, ownerKey(strconv.Itoa(owner.Id)))
+		err := putOwner(ctx, &owner)
+		if err != nil {
+			return err
+		}
 
+		err = putEmailIndex(ctx, &owner)
 		if err != nil {
-			return fmt.Errorf("failed to put to world state. %s", err.Error())
+			return err
 		}
 	}
 
 	return nil
 }
 
-// CreateCar adds a new car to the world state with given details
-func (s *SmartContract) CreateCar(ctx contractapi.TransactionContextInterface, carNumber string, make string, model string, color string, owner string) error {
-	car := Car{
-		Make:  make,
-		Model: model,
-		Color: color,
-		Owner: owner,
+// RegisterCar adds a new car with a valid 17 character VIN to the world state
+// and creates its index entries. The owner is given by key, such as OWNER1,
+// and must be the submitting client. The price is in cents of the currency of
+// the owner's money. When carId is empty the next free car id is generated,
+// otherwise it must be a positive number that is not used by another car.
+func (s *SmartContract) RegisterCar(ctx contractapi.TransactionContextInterface, carId string, vin string, make string, model string, year int, mileage int, color string, price int64, ownerId string) (*Car, error) {
+	if carId == "" {
+		nextId, err := s.nextCarId(ctx)
+		if err != nil {
+			return nil, err
+		}
+		carId = strconv.Itoa(nextId)
 	}
 
-	carAsBytes, _ := json.Marshal(car)
+	id, err := strconv.Atoi(carId)
+	if err != nil || id <= 0 {
+		return nil, newError(CodeInvalidArgument, "car id %s must be a positive number", carId)
+	}
 
-	return ctx.GetStub().PutState(carNumber, carAsBytes)
-}
+	exists, err := s.CarExists(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, newError(CodeAlreadyExists, "car %s already exists", carId)
+	}
 
-func (s *SmartContract) GetCarById(ctx contractapi.TransactionContextInterface, carId string) (*Car, error) {
+	vin, err = normalizeVin(vin)
+	if err != nil {
+		return nil, err
+	}
 
-	carAsBytes, err := ctx.GetStub().GetState(carId)
+	err = checkVinUnused(ctx, vin)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state. %s", err.Error())
+		return nil, err
 	}
 
-	if carAsBytes == nil {
-		return nil, fmt.Errorf("%s does not exist", carId)
+	if strings.TrimSpace(make) == "" || strings.TrimSpace(model) == "" || strings.TrimSpace(color) == "" {
+		return nil, newError(CodeInvalidArgument, "make, model and color of car must not be empty")
 	}
 
-	car := new(Car)
-	_ = json.Unmarshal(carAsBytes, car)
+	registeredAt, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	return car, nil
-}
+	// the first car was built in 1886 and model years run ahead of the
+	// calendar by at most one year
+	if year < 1886 || year > registeredAt.Year()+1 {
+		return nil, newError(CodeInvalidArgument, "year %d is not a valid model year", year)
+	}
 
-func (s *SmartContract) GetOwnerById(ctx contractapi.TransactionContextInterface, ownerId string) (*Owner, error) {
-	ownerAsBytes, err := ctx.GetStub().GetState(ownerId)
+	if mileage < 0 {
+		return nil, newError(CodeInvalidArgument, "mileage of car can not be negative")
+	}
 
+	if price <= 0 {
+		return nil, newError(CodeInvalidArgument, "price of car must be positive")
+	}
+
+	reading, err := newOdometerReading(ctx, mileage)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state. %s", err.Error())
+		return nil, err
 	}
 
-	if ownerAsBytes == nil {
-		return nil, fmt.Errorf("%s does not exist", ownerId)
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
 	}
 
-	owner := new(Owner)
-	_ = json.Unmarshal(ownerAsBytes, owner)
+	err = authorizeOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
 
-	return owner, nil
-}
+	currency := owner.Money.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
 
-func (s *SmartContract) GetCarsByColor(ctx contractapi.TransactionContextInterface, color string) ([]*Car, error) {
-	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey("color~owner~id", []string{color})
+	car := Car{
+		Id:               id,
+		Vin:              vin,
+		Make:             make,
+		Model:            model,
+		Year:             year,
+		Mileage:          mileage,
+		Color:            color,
+		OdometerReadings: []OdometerReading{reading},
+		Owner:            strconv.Itoa(owner.Id),
+		Status:           CarActive,
+		Malfunctions:     []Malfunction{},
+		Price:            NewAmount(price, currency),
+	}
+
+	err = putCar(ctx, &car)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resultIter.Close()
+	err = putVinIndex(ctx, &car)
+	if err != nil {
+		return nil, err
+	}
 
-	cars := make([]*Car, 0)
+	err = setCarEndorsement(ctx, carId, owner.MSPID)
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; resultIter.HasNext(); i++ {
-		responseRange, err := resultIter.Next()
-		if err != nil {
-			return nil, err
-		}
+	err = emitCarEvent(ctx, EventCarCreated, carId, "", car.Owner, car.Price)
+	if err != nil {
+		return nil, err
+	}
 
-		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
-		if err != nil {
-			return nil, err
-		}
+	return &car, nil
+}
 
-		carId := compositeKeyParts[2]
+// nextCarId returns the id following the biggest car id in world state
+func (s *SmartContract) nextCarId(ctx contractapi.TransactionContextInterface) (int, error) {
+	cars, err := s.GetAllCars(ctx)
+	if err != nil {
+		return 0, err
+	}
 
-		carAsset, err := s.GetCarById(ctx, carId)
-		if err != nil {
-			return nil, err
+	maxId := 0
+	for _, car := range cars {
+		if car.Id > maxId {
+			maxId = car.Id
 		}
-
-		cars = append(cars, carAsset)
 	}
 
-	return cars, nil
+	return maxId + 1, nil
 }
 
-func (s *SmartContract) GetCarsByColorAndOwner(ctx contractapi.TransactionContextInterface, color string, owner string) ([]*Car, error) {
-	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey("color~owner~id", []string{color, owner})
+func (s *SmartContract) GetCarById(ctx contractapi.TransactionContextInterface, carId string) (*Car, error) {
+
+	carAsBytes, err := ctx.GetStub().GetState(carKey(carId))
 	if err != nil {
-		return nil, err
+		return nil, errLedger("failed to read from world state. %s", err.Error())
 	}
 
-	defer resultIter.Close()
-
-	cars := make([]*Car, 0)
+	if carAsBytes == nil {
+		return nil, errCarNotFound(carId)
+	}
 
-	for i := 0; resultIter.HasNext(); i++ {
-		responseRange, err := resultIter.Next()
-		if err != nil {
-			return nil, err
-		}
+	car := new(Car)
+	err = json.Unmarshal(carAsBytes, car)
+	if err != nil {
+		return nil, newError(CodeInternal, "failed to unmarshal car %s: %v", carId, err)
+	}
 
-		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
-		if err != nil {
-			return nil, err
-		}
+	return car, nil
+}
 
-		carId := compositeKeyParts[2]
+// GetOwnerById returns the owner stored under ownerId together with its
+// private details. Only clients of the owner's organization can read them,
+// anyone else can read the public part with GetOwnerRecord.
+func (s *SmartContract) GetOwnerById(ctx contractapi.TransactionContextInterface, ownerId string) (*Owner, error) {
+	return s.getOwner(ctx, ownerId)
+}
 
-		carAsset, err := s.GetCarById(ctx, carId)
-		if err != nil {
-			return nil, err
-		}
+func (s *SmartContract) GetCarsByColor(ctx contractapi.TransactionContextInterface, color string) ([]*Car, error) {
+	return s.carsByIndex(ctx, colorIndex, color)
+}
 
-		cars = append(cars, carAsset)
-	}
+func (s *SmartContract) GetCarsByColorAndOwner(ctx contractapi.TransactionContextInterface, color string, owner string) ([]*Car, error) {
+	return s.carsByIndex(ctx, colorIndex, color, owner)
+}
 
-	return cars, nil
+// GetCarsByMakeAndModel returns the cars of the make and model, ignoring
+// case, using the make~model~id index
+func (s *SmartContract) GetCarsByMakeAndModel(ctx contractapi.TransactionContextInterface, make string, model string) ([]*Car, error) {
+	return s.carsByIndex(ctx, makeModelIndex, strings.ToLower(make), strings.ToLower(model))
 }
 
 func (s *SmartContract) GetAllCars(ctx contractapi.TransactionContextInterface) ([]*Car, error) {
-	// range query with empty string for startKey and endKey does an
-	// open-ended query of all assets in the chaincode namespace.
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	// every car key starts with the CAR prefix followed by digits, so the
+	// range below covers all of them and no owners or index entries.
+	resultsIterator, err := ctx.GetStub().GetStateByRange(carKeyPrefix, carKeyPrefix+"~")
 	if err != nil {
 		return nil, err
 	}
@@ -250,227 +399,439 @@ func (s *SmartContract) GetAllCars(ctx contractapi.TransactionContextInterface)
 	return cars, nil
 }
 
-// ChangeCarOwner updates the owner field of car with given id in world state
+// ChangeCarColor changes the color of the car with given id to a color that
+// is not blank. Only the owner of the car can recolor it, and not while it is
+// in the custody of a lessee.
 func (s *SmartContract) ChangeCarColor(ctx contractapi.TransactionContextInterface, carId string, color string) error {
+	if strings.TrimSpace(color) == "" {
+		return newError(CodeInvalidArgument, "color of car must not be empty")
+	}
+
 	car, err := s.GetCarById(ctx, carId)
 	if err != nil {
 		return err
 	}
 
-	oldKey, _ := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{car.Color, car.Owner, strconv.Itoa(car.Id)})
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
 
-	car.Color = color
-	carAsBytes, _ := json.Marshal(car)
-	err = ctx.GetStub().PutState(carId, carAsBytes)
+	err = checkCarStatus(car, inServiceStatuses...)
 	if err != nil {
 		return err
 	}
 
-	key, err := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{color, car.Owner, strconv.Itoa(car.Id)})
+	err = s.checkNotLeased(ctx, car)
 	if err != nil {
 		return err
 	}
 
-	ctx.GetStub().DelState(oldKey)
-	value := []byte{0x00}
-	err = ctx.GetStub().PutState(key, value)
+	oldColor := car.Color
+	car.Color = color
+	err = putCar(ctx, car)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return emitCarEvent(ctx, EventCarColorChanged, carId, oldColor, color, Amount{})
 }
 
-func (s *SmartContract) AddMalfunction(ctx contractapi.TransactionContextInterface, carId string, description string, price float64) error {
+// AddMalfunction reports a new open malfunction of the car with the given
+// severity (LOW, MEDIUM, HIGH or CRITICAL) and price in cents of the car's
+// currency. Mechanics can report malfunctions, and so can the lessee of a
+// leased car, though only a mechanic can report one that gets the car
+// scrapped.
+func (s *SmartContract) AddMalfunction(ctx contractapi.TransactionContextInterface, carId string, description string, price int64, severity string) error {
+	mechanicErr := assertMechanic(ctx)
 
 	car, err := s.GetCarById(ctx, carId)
 	if err != nil {
-		return fmt.Errorf("Car with specified id does not exist")
+		if mechanicErr != nil {
+			return mechanicErr
+		}
+		return errCarNotFound(carId)
 	}
 
-	malfunction := Malfunction{Description: description, Price: price}
+	if mechanicErr != nil {
+		lease, err := s.leaseInForce(ctx, car)
+		if err != nil {
+			return err
+		}
+
+		if lease == nil || s.authorizeLessee(ctx, lease) != nil {
+			return mechanicErr
+		}
+	}
 
-	malfunctions := car.Malfunctions
-	malfunctionsPrice := 0.0
-	for _, malfunction := range car.Malfunctions {
-		malfunctionsPrice += malfunction.Price
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
 	}
 
-	if (malfunctionsPrice + price) <= car.Price {
-		malfunctions = append(malfunctions, malfunction)
+	malfunction, err := newMalfunction(ctx, car, description, NewAmount(price, car.Price.Currency), severity)
+	if err != nil {
+		return err
+	}
 
-		car.Malfunctions = malfunctions
+	car.Malfunctions = append(car.Malfunctions, malfunction)
 
-		carAsBytes, _ := json.Marshal(car)
-		ctx.GetStub().PutState(carId, carAsBytes)
-	} else {
-		fmt.Println("Price of malfunctions is bigger than price of car, so car will be deleted")
-		s.DeleteCar(ctx, carId)
+	tooExpensive, totalPrice, err := openMalfunctionsExceedPrice(car)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if tooExpensive && mechanicErr != nil {
+		return newError(CodeInvalidState, "price of malfunctions of car %s would exceed its price, only a mechanic can report it", carId).
+			with("carId", carId).with("malfunctionsPrice", totalPrice.String()).with("carPrice", car.Price.String())
+	}
+
+	scrapped, totalPrice, err := s.putCarWithMalfunctions(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if scrapped {
+		return emitCarEvent(ctx, EventCarScrapped, carId, "", "", totalPrice)
+	}
+
+	return emitCarEvent(ctx, EventMalfunctionAdded, carId, "", malfunction.Description, malfunction.Price)
 }
 
-func (s *SmartContract) RepairCar(ctx contractapi.TransactionContextInterface, carId string) error {
+// RepairCar repairs every malfunction of the car that its owner sent to the
+// repair shop. It is called by a mechanic of the shop, and the price of the
+// repairs is moved to the shop from the insurers of approved claims and, for
+// the part not covered by them, from the co-owners by their shares. Co-owners
+// pay with their deposited funds when the peers of other organizations
+// endorse changes to the car, see DepositFunds.
+func (s *SmartContract) RepairCar(ctx contractapi.TransactionContextInterface, carId string, shopId string) error {
 
 	car, err := s.GetCarById(ctx, carId)
 	if err != nil {
-		fmt.Println("Car with specified id does not exist")
 		return err
 	}
 
-	owner, err := s.GetOwnerById(ctx, "OWNER"+car.Owner)
+	shop, err := s.GetRepairShop(ctx, shopId)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeMechanic(ctx, shop)
 	if err != nil {
 		return err
 	}
 
-	malfunctionsPrice := 0.0
-	for _, malfunction := range car.Malfunctions {
-		malfunctionsPrice += malfunction.Price
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
 	}
 
-	if malfunctionsPrice > owner.Money {
-		fmt.Println("Owner does not have enough money to repair car")
-		return nil
-	} else {
-		car.Malfunctions = []Malfunction{}
-		carAsBytes, _ := json.Marshal(car)
-		ctx.GetStub().PutState(carId, carAsBytes)
+	repairs := malfunctionsInRepairAt(car, shopId)
+	if len(repairs) == 0 {
+		return newError(CodeInvalidState, "car %s has no malfunctions in repair at shop %s", carId, shopId)
+	}
 
-		money := owner.Money - malfunctionsPrice
-		owner.Money = money
-		ownerAsBytes, _ := json.Marshal(owner)
-		ctx.GetStub().PutState("OWNER"+car.Owner, ownerAsBytes)
+	malfunctionsPrice, err := sumMalfunctionPrices(car.Price.Currency, repairs)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	ownerShare, claims, err := s.splitRepairCost(ctx, car, repairs)
+	if err != nil {
+		return err
+	}
+
+	// co-owners pay their share of the repairs
+	parts, err := s.coOwnerParts(ctx, carShares(car), ownerShare)
+	if err != nil {
+		return err
+	}
+
+	payers, affordable, err := s.canAfford(ctx, car, parts)
+	if err != nil {
+		return err
+	}
+
+	if !affordable {
+		return newError(CodeInsufficientFunds, "owner does not have enough money to repair car %s", carId).
+			with("carId", carId).with("price", ownerShare.String())
+	}
+
+	repairedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range car.Malfunctions {
+		if car.Malfunctions[i].Status == MalfunctionInRepair && car.Malfunctions[i].ShopId == shopId {
+			markRepaired(&car.Malfunctions[i], repairedAt)
+		}
+	}
+
+	err = leaveRepair(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = payRepair(ctx, payers, parts, shop)
+	if err != nil {
+		return err
+	}
+
+	err = s.payClaims(ctx, claims, shop)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventCarRepaired, carId, "", shopId, malfunctionsPrice)
 }
 
+// TransferOwnership sells the car to the new owner approved with
+// ApproveTransfer, who pays its price. A buyer of another organization than
+// the car's owners pays with funds deposited with DepositFunds. The sellers
+// collect their part of the price with CollectPayments. A car with open
+// malfunctions is only sold to a buyer who accepts them, at its price less
+// their cost.
 func (s *SmartContract) TransferOwnership(ctx contractapi.TransactionContextInterface, carId string, newOwner string, acceptsMalfunctions bool) error {
-
 	carExists, err := s.CarExists(ctx, carId)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return errLedger("failed to read from world state: %v", err)
 	}
 
 	ownerExists, err := s.OwnerExists(ctx, newOwner)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return errLedger("failed to read from world state: %v", err)
+	}
+
+	if !carExists {
+		return errCarNotFound(carId)
+	}
+
+	if !ownerExists {
+		return newError(CodeOwnerNotFound, "%s does not exist", newOwner).with("ownerId", newOwner)
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	buyer, err := s.GetOwnerRecord(ctx, newOwner)
+	if err != nil {
+		return err
+	}
+
+	// the buyer submits the transfer, since it is their money that is spent,
+	// after the current owner approved them with ApproveTransfer
+	err = s.authorizeOwnerRecord(ctx, buyer)
+	if err != nil {
+		return err
+	}
+
+	buyerId := strconv.Itoa(buyer.Id)
+	if car.ApprovedBuyer != buyerId {
+		return newError(CodeNotApproved, "owner of car %s did not approve transfer to %s", carId, newOwner)
+	}
+
+	err = checkSaleConsent(car)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkInspection(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	listed, err := isListed(ctx, carId)
+	if err != nil {
+		return err
 	}
+	if listed {
+		return newError(CodeCarListed, "car %s is listed for sale, cancel the listing first", carId).with("carId", carId)
+	}
+
+	// a buyer taking the car with its open malfunctions pays the price less
+	// the cost of repairing them
+	price := car.Price
+	pending := openMalfunctions(car)
+	if len(pending) > 0 {
+		if !acceptsMalfunctions {
+			return newError(CodeHasMalfunctions, "car has malfunctions and new owner does not want them").
+				with("carId", carId).with("malfunctions", strconv.Itoa(len(pending)))
+		}
 
-	if carExists && ownerExists {
-		car, err := s.GetCarById(ctx, carId)
+		malfunctionsPrice, err := sumMalfunctionPrices(car.Price.Currency, pending)
 		if err != nil {
-			fmt.Println("Car with specified id does not exist")
 			return err
 		}
 
-		owner, err := s.GetOwnerById(ctx, newOwner)
+		price, err = car.Price.Sub(malfunctionsPrice)
 		if err != nil {
-			fmt.Println("Owner with specified id does not exist")
 			return err
 		}
+	}
 
-		if len(car.Malfunctions) == 0 && owner.Money >= car.Price {
-
-			oldOwnerId := car.Owner
-
-			oldKey, _ := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{car.Color, car.Owner, carId})
-			car.Owner = strconv.Itoa(owner.Id)
-			carAsBytes, _ := json.Marshal(car)
-			ctx.GetStub().PutState(carId, carAsBytes)
-
-			key, err := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{car.Color, strconv.Itoa(owner.Id), strconv.Itoa(car.Id)})
-			if err != nil {
-				return err
-			}
-
-			ctx.GetStub().DelState(oldKey)
-			value := []byte{0x00}
-			err = ctx.GetStub().PutState(key, value)
-			if err != nil {
-				return err
-			}
-
-			ownerMoney := owner.Money - car.Price
-			owner.Money = ownerMoney
-			ownerAsBytes, _ := json.Marshal(owner)
-			ctx.GetStub().PutState(newOwner, ownerAsBytes)
-
-			oldOwner, err := s.GetOwnerById(ctx, "OWNER"+oldOwnerId)
-			if err != nil {
-				fmt.Println("Owner with specified id does not exist")
-				return err
-			}
-
-			oldOwner.Money = oldOwner.Money + car.Price
-			oldOwnerAsBytes, _ := json.Marshal(oldOwner)
-			ctx.GetStub().PutState("OWNER"+oldOwnerId, oldOwnerAsBytes)
-
-		} else if len(car.Malfunctions) > 0 && acceptsMalfunctions {
-			malfunctionsPrice := 0.0
-			for _, malfunction := range car.Malfunctions {
-				malfunctionsPrice += malfunction.Price
-			}
-
-			carPrice := car.Price - malfunctionsPrice
-
-			if owner.Money >= carPrice {
-				oldOwnerId := car.Owner
-
-				oldKey, _ := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{car.Color, car.Owner, carId})
-				car.Owner = strconv.Itoa(owner.Id)
-				carAsBytes, _ := json.Marshal(car)
-				ctx.GetStub().PutState(carId, carAsBytes)
-
-				key, err := ctx.GetStub().CreateCompositeKey("color~owner~id", []string{car.Color, strconv.Itoa(owner.Id), strconv.Itoa(car.Id)})
-				if err != nil {
-					return err
-				}
-
-				ctx.GetStub().DelState(oldKey)
-				value := []byte{0x00}
-				err = ctx.GetStub().PutState(key, value)
-				if err != nil {
-					return err
-				}
-
-				ownerMoney := owner.Money - carPrice
-				owner.Money = ownerMoney
-				ownerAsBytes, _ := json.Marshal(owner)
-				ctx.GetStub().PutState(newOwner, ownerAsBytes)
-
-				oldOwner, err := s.GetOwnerById(ctx, "OWNER"+oldOwnerId)
-				if err != nil {
-					fmt.Println("Owner with specified id does not exist")
-					return err
-				}
-
-				oldOwner.Money = oldOwner.Money + carPrice
-				oldOwnerAsBytes, _ := json.Marshal(oldOwner)
-				ctx.GetStub().PutState("OWNER"+oldOwnerId, oldOwnerAsBytes)
-
-			} else {
-				return fmt.Errorf("new owner does not have enough money to buy this car")
-			}
-		} else if len(car.Malfunctions) > 0 && !acceptsMalfunctions {
-
-			return fmt.Errorf("car has malfunctions and new owner does not want them")
-
-		} else if len(car.Malfunctions) == 0 && owner.Money < car.Price {
-
-			return fmt.Errorf("new owner does not have enough money to buy this car")
-		}
+	// the peers of the sellers' organizations endorse the sale, so a buyer
+	// of another organization pays with deposited funds
+	endorsingOrgs, err := s.GetCarEndorsingOrgs(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	funds, err := s.fundsOf(ctx, buyerId, endorsingOrgs)
+	if err != nil {
+		return err
+	}
 
+	sufficient, err := funds.covers(price)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if !sufficient {
+		return newError(CodeInsufficientFunds, "new owner does not have enough money to buy this car").
+			with("carId", carId).with("price", price.String())
+	}
+
+	oldOwnerId := car.Owner
+	sellers := carShares(car)
+
+	err = s.setCarOwner(ctx, car, buyerId)
+	if err != nil {
+		return err
+	}
+
+	err = funds.spend(ctx, price)
+	if err != nil {
+		return err
+	}
+
+	// lenders of the car are paid off first out of the price, the co-owners
+	// share the rest
+	proceeds, err := s.settleLiens(ctx, carId, price)
+	if err != nil {
+		return err
+	}
+
+	err = s.paySellers(ctx, sellers, proceeds, PaymentSale)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventOwnershipTransfer, carId, oldOwnerId, car.Owner, price)
+}
+
+// ApproveTransfer lets the current owner of a car choose the buyer that may
+// take it over with TransferOwnership. Every co-owner of a co-owned car
+// approves the buyer in turn, and approving another buyer starts over.
+func (s *SmartContract) ApproveTransfer(ctx contractapi.TransactionContextInterface, carId string, newOwner string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	approvedBy, err := s.authorizeCoOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	buyer, err := s.GetOwnerRecord(ctx, newOwner)
+	if err != nil {
+		return err
+	}
+
+	if ownerPercent(car, strconv.Itoa(buyer.Id)) > 0 {
+		return newError(CodeInvalidState, "%s already owns car %s", newOwner, carId)
+	}
+
+	if car.ApprovedBuyer != strconv.Itoa(buyer.Id) {
+		car.ApprovedBuyer = strconv.Itoa(buyer.Id)
+		car.SaleConsents = nil
+	}
+
+	approved := false
+	for _, ownerId := range car.SaleConsents {
+		approved = approved || ownerId == approvedBy
+	}
+	if !approved && len(car.Shares) > 0 {
+		car.SaleConsents = append(car.SaleConsents, approvedBy)
+	}
+
+	return putCar(ctx, car)
+}
+
+// setCarOwner stores the car with its new owner as its sole owner and moves
+// its endorsement policy along
+func (s *SmartContract) setCarOwner(ctx contractapi.TransactionContextInterface, car *Car, newOwnerId string) error {
+	newOwner, err := s.GetOwnerRecord(ctx, ownerKey(newOwnerId))
+	if err != nil {
+		return err
+	}
+
+	car.Owner = newOwnerId
+	soleOwnership(car)
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return setCarEndorsement(ctx, strconv.Itoa(car.Id), newOwner.MSPID)
+}
+
+// putCar stores the car and updates its secondary index entries. The entries
+// are diffed against the car as stored before the transaction, so a
+// transaction writes each car once.
+func putCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	carId := strconv.Itoa(car.Id)
+	stored, err := storedCar(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	car.DocType = carDocType
+	carAsBytes, err := json.Marshal(car)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(carKey(carId), carAsBytes)
+	if err != nil {
+		return errLedger("failed to put car to world state. %v", err)
+	}
+
+	return updateCarIndexes(ctx, stored, car)
 }
 
 func (s *SmartContract) CarExists(ctx contractapi.TransactionContextInterface, carId string) (bool, error) {
-	car, err := ctx.GetStub().GetState(carId)
+	car, err := ctx.GetStub().GetState(carKey(carId))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, errLedger("failed to read from world state: %v", err)
 	}
 
 	return car != nil, nil
@@ -479,23 +840,98 @@ func (s *SmartContract) CarExists(ctx contractapi.TransactionContextInterface, c
 func (s *SmartContract) OwnerExists(ctx contractapi.TransactionContextInterface, ownerId string) (bool, error) {
 	owner, err := ctx.GetStub().GetState(ownerId)
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, errLedger("failed to read from world state: %v", err)
 	}
 
 	return owner != nil, nil
 }
 
+// DeleteCar lets the owner take the car off the road. The car is archived as
+// scrapped rather than removed from world state.
 func (s *SmartContract) DeleteCar(ctx contractapi.TransactionContextInterface, carId string) error {
 	exists, err := s.CarExists(ctx, carId)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return errLedger("failed to read from world state: %v", err)
 	}
 
 	if !exists {
-		return fmt.Errorf("the asset %s does not exist", carId)
+		return errCarNotFound(carId)
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	listed, err := isListed(ctx, carId)
+	if err != nil {
+		return err
+	}
+	if listed {
+		return newError(CodeCarListed, "car %s is listed for sale, cancel the listing first", carId).with("carId", carId)
+	}
+
+	err = s.checkNoActiveLiens(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.scrapCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventCarDeleted, carId, car.Owner, "", Amount{})
+}
+
+// scrapCar archives the car as scrapped, refunding open offers if it was
+// listed for sale and terminating its lease. The car stays in world state, so
+// that its history can still be looked up.
+func (s *SmartContract) scrapCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	carId := strconv.Itoa(car.Id)
+	listed, err := isListed(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	if listed {
+		err = s.closeOpenOffers(ctx, carId, "", OfferRejected)
+		if err != nil {
+			return err
+		}
+
+		err = deleteListing(ctx, carId)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.terminateLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = changeCarStatus(ctx, car, CarScrapped)
+	if err != nil {
+		return err
 	}
 
-	return ctx.GetStub().DelState(carId)
+	return putCar(ctx, car)
 }
 
 func main() {
@@ -507,7 +943,7 @@ func main() {
 		return
 	}
 
-	if err := chaincode.Start(); err != nil {
+	if err := shim.Start(codedChaincode{chaincode}); err != nil {
 		fmt.Printf("Error starting fabcar chaincode: %s", err.Error())
 	}
 }
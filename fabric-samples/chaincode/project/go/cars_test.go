@@ -0,0 +1,123 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractMetadata(t *testing.T) {
+	_, err := contractapi.NewChaincode(new(SmartContract))
+	require.NoError(t, err)
+}
+
+func TestTransferOwnershipNeedsApproval(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+	requireError(t, err, CodeNotApproved, "owner of car 4 did not approve transfer to OWNER1")
+
+	err = s.ApproveTransfer(ctx, "4", "OWNER1")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 4, does not own it")
+
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER1")
+	require.NoError(t, err)
+
+	// only the approved buyer can spend their money on the car
+	err = s.TransferOwnership(ctx, "4", "OWNER1", false)
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	ctx.isolated(t, func() {
+		err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+		require.NoError(t, err)
+	})
+
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, "1", car.Owner)
+	require.Empty(t, car.ApprovedBuyer)
+
+	buyer, err := s.GetOwnerById(ctx, "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(300000, "EUR"), buyer.Money)
+}
+
+func TestRegisterCar(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.RegisterCar(ctx, "", "1M8GDM9AXKP042788", "Fiat", "Punto", 2012, 120000, "white", 350000, "OWNER1")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	_, err = s.RegisterCar(ctx.asOwner(1), "1", "1M8GDM9AXKP042788", "Fiat", "Punto", 2012, 120000, "white", 350000, "OWNER1")
+	requireError(t, err, CodeAlreadyExists, "car 1 already exists")
+
+	_, err = s.RegisterCar(ctx, "", "JTDKN3DU6A0123456", "Fiat", "Punto", 2012, 120000, "white", 350000, "OWNER1")
+	requireError(t, err, CodeAlreadyExists, "VIN JTDKN3DU6A0123456 is already registered for car 1")
+
+	_, err = s.RegisterCar(ctx, "", "1M8GDM9AXKP042788", "Fiat", "Punto", 1800, 120000, "white", 350000, "OWNER1")
+	requireError(t, err, CodeInvalidArgument, "year 1800 is not a valid model year")
+
+	_, err = s.RegisterCar(ctx, "", "1M8GDM9AXKP042788", "Fiat", "Punto", 2012, 120000, " ", 350000, "OWNER1")
+	requireError(t, err, CodeInvalidArgument, "make, model and color of car must not be empty")
+
+	_, err = s.RegisterCar(ctx, "", "1M8GDM9AXKP042788", "Fiat", "Punto", 2012, 120000, "white", 350000, "OWNER7")
+	requireError(t, err, CodeOwnerNotFound, "OWNER7 does not exist")
+
+	car, err := s.RegisterCar(ctx, "", "1M8GDM9AXKP042788", "Fiat", "Punto", 2012, 120000, "white", 350000, "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, 7, car.Id)
+	require.Equal(t, "1", car.Owner)
+	require.Equal(t, NewAmount(350000, "EUR"), car.Price)
+
+	name, event := ctx.lastEvent(t)
+	require.Equal(t, EventCarCreated, name)
+	require.Equal(t, "7", event.CarId)
+
+	cars, err := s.GetCarsByColorAndOwner(ctx, "white", "1")
+	require.NoError(t, err)
+	require.Len(t, cars, 1)
+	require.Equal(t, "1M8GDM9AXKP042788", cars[0].Vin)
+}
+
+func TestChangeCarColor(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.ChangeCarColor(ctx.asOwner(1), "1", "")
+	requireError(t, err, CodeInvalidArgument, "color of car must not be empty")
+
+	err = s.ChangeCarColor(ctx.asOwner(1), "1", " \t")
+	requireError(t, err, CodeInvalidArgument, "color of car must not be empty")
+
+	err = s.ChangeCarColor(ctx.asOwner(1), "1", "red")
+	require.NoError(t, err)
+
+	car, err := s.GetCarById(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "red", car.Color)
+}
+
+func TestInitLedgerOnce(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+	err := s.InitLedger(ctx.withSalt())
+	requireError(t, err, CodeAlreadyExists, "ledger is already initialized, car 1 exists")
+
+	// nor once the sample cars are gone but their owners are not
+	for _, carId := range []string{"1", "2", "3", "4", "5", "6"} {
+		err = ctx.stub.DelState(carKey(carId))
+		require.NoError(t, err)
+	}
+
+	err = s.InitLedger(ctx.withSalt())
+	requireError(t, err, CodeAlreadyExists, "ledger is already initialized, owner OWNER1 exists")
+}
@@ -0,0 +1,370 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OwnerShare is the percentage of a car held by one of its co-owners
+type OwnerShare struct {
+	Owner   string `json:"owner"`
+	Percent int    `json:"percent"`
+}
+
+// OwnedCar is a car together with the share of it held by an owner
+type OwnedCar struct {
+	Car     *Car `json:"car"`
+	Percent int  `json:"percent"`
+}
+
+// SharesProposal is a split of a car among co-owners that its owner proposed.
+// It takes effect once every co-owner it grants a share to accepted it.
+type SharesProposal struct {
+	ProposedBy   string       `json:"proposedBy"`
+	Shares       []OwnerShare `json:"shares"`
+	SaleMajority int          `json:"saleMajority"`
+	AcceptedBy   []string     `json:"acceptedBy"`
+}
+
+// coOwnerPart is the part of an amount that a co-owner, given by numeric id,
+// pays or receives
+type coOwnerPart struct {
+	owner  string
+	amount Amount
+}
+
+// SetCarShares proposes to split the ownership of the car among co-owners,
+// given by key such as OWNER1, with percentages that sum to 100. The owner of
+// the car, who keeps managing it, must hold a share. Every other co-owner
+// accepts their share with AcceptCarShares, and the split takes effect once
+// all of them have. Selling the whole car then needs the approval of
+// co-owners holding at least saleMajority percent. Proposing again replaces a
+// proposal that was not accepted yet. Only a car with a single owner can be
+// split, after that the shares change with a sale of the whole car.
+func (s *SmartContract) SetCarShares(ctx contractapi.TransactionContextInterface, carId string, shares []OwnerShare, saleMajority int) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkSharesCanChange(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if saleMajority <= 0 || saleMajority > 100 {
+		return newError(CodeInvalidArgument, "sale majority %d must be between 1 and 100 percent", saleMajority)
+	}
+
+	total := 0
+	managed := false
+	normalized := make([]OwnerShare, 0, len(shares))
+	for _, share := range shares {
+		record, err := s.GetOwnerRecord(ctx, share.Owner)
+		if err != nil {
+			return err
+		}
+
+		ownerId := strconv.Itoa(record.Id)
+		for _, other := range normalized {
+			if other.Owner == ownerId {
+				return newError(CodeInvalidArgument, "%s has more than one share of car %s", share.Owner, carId)
+			}
+		}
+
+		if share.Percent <= 0 {
+			return newError(CodeInvalidArgument, "share of %s must be a positive percentage", share.Owner)
+		}
+
+		total += share.Percent
+		managed = managed || ownerId == car.Owner
+		normalized = append(normalized, OwnerShare{Owner: ownerId, Percent: share.Percent})
+	}
+
+	if total != 100 {
+		return newError(CodeInvalidArgument, "shares of car %s sum to %d percent, not 100", carId, total)
+	}
+
+	if !managed {
+		return newError(CodeInvalidArgument, "owner %s of car %s must keep a share", ownerKey(car.Owner), carId)
+	}
+
+	car.ProposedShares = &SharesProposal{
+		ProposedBy:   car.Owner,
+		Shares:       normalized,
+		SaleMajority: saleMajority,
+		AcceptedBy:   []string{car.Owner},
+	}
+
+	return s.putCarWithProposedShares(ctx, car)
+}
+
+// AcceptCarShares lets a co-owner granted a share of the car by its owner's
+// proposal accept it. The shares take effect with the last acceptance.
+func (s *SmartContract) AcceptCarShares(ctx contractapi.TransactionContextInterface, carId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	proposal := car.ProposedShares
+	if proposal == nil {
+		return newError(CodeInvalidState, "no shares of car %s are proposed", carId)
+	}
+
+	if proposal.ProposedBy != car.Owner {
+		return newError(CodeInvalidState, "shares of car %s were proposed by a previous owner", carId)
+	}
+
+	grantee := ""
+	for _, share := range proposal.Shares {
+		if _, err := s.submittingOwner(ctx, share.Owner); err == nil {
+			grantee = share.Owner
+			break
+		}
+	}
+
+	if grantee == "" {
+		return newError(CodeUnauthorized, "submitting client not authorized to accept shares of car %s, is not granted one", carId)
+	}
+
+	for _, ownerId := range proposal.AcceptedBy {
+		if ownerId == grantee {
+			return newError(CodeInvalidState, "%s already accepted the shares of car %s", ownerKey(grantee), carId)
+		}
+	}
+
+	err = s.checkSharesCanChange(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	proposal.AcceptedBy = append(proposal.AcceptedBy, grantee)
+
+	return s.putCarWithProposedShares(ctx, car)
+}
+
+// checkSharesCanChange makes sure that the car can be split among co-owners
+func (s *SmartContract) checkSharesCanChange(ctx contractapi.TransactionContextInterface, car *Car) error {
+	err := checkCarStatus(car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if len(car.Shares) > 0 {
+		return newError(CodeInvalidState, "car %d is already co-owned, its shares only change when the whole car is sold", car.Id)
+	}
+
+	return nil
+}
+
+// putCarWithProposedShares stores the car with its proposed shares. Once
+// every co-owner accepted them, they replace the shares of the car, and the
+// organizations of the co-owners endorse its changes from then on.
+func (s *SmartContract) putCarWithProposedShares(ctx contractapi.TransactionContextInterface, car *Car) error {
+	proposal := car.ProposedShares
+	if len(proposal.AcceptedBy) < len(proposal.Shares) {
+		return putCar(ctx, car)
+	}
+
+	orgs := make([]string, 0, len(proposal.Shares))
+	for _, share := range proposal.Shares {
+		record, err := s.GetOwnerRecord(ctx, ownerKey(share.Owner))
+		if err != nil {
+			return err
+		}
+		orgs = append(orgs, record.MSPID)
+	}
+
+	car.Shares = proposal.Shares
+	car.SaleMajority = proposal.SaleMajority
+	car.ApprovedBuyer = ""
+	car.SaleConsents = nil
+	car.ProposedShares = nil
+
+	err := putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return setCarEndorsement(ctx, strconv.Itoa(car.Id), orgs...)
+}
+
+// GetCarsByOwner returns the cars the owner, given by key such as OWNER1,
+// owns fully or in part, each with the owner's share of it. Scrapped cars
+// are left out.
+func (s *SmartContract) GetCarsByOwner(ctx contractapi.TransactionContextInterface, ownerId string) ([]*OwnedCar, error) {
+	record, err := s.GetOwnerRecord(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strconv.Itoa(record.Id)
+	cars, err := s.carsByIndex(ctx, ownerIndex, id)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]*OwnedCar, 0)
+	for _, car := range cars {
+		owned = append(owned, &OwnedCar{Car: car, Percent: ownerPercent(car, id)})
+	}
+
+	return owned, nil
+}
+
+// authorizeCoOwner makes sure that the submitting client holds a share of the
+// car and returns the id of its owner
+func (s *SmartContract) authorizeCoOwner(ctx contractapi.TransactionContextInterface, car *Car) (string, error) {
+	for _, share := range carShares(car) {
+		if _, err := s.submittingOwner(ctx, share.Owner); err == nil {
+			return share.Owner, nil
+		}
+	}
+
+	return "", newError(CodeUnauthorized, "submitting client not authorized to update car %d, does not own it", car.Id)
+}
+
+// coOwnerParts splits the amount among the co-owners of the car by their
+// shares. The cents that do not split evenly go to the first co-owner.
+func (s *SmartContract) coOwnerParts(ctx contractapi.TransactionContextInterface, shares []OwnerShare, amount Amount) ([]coOwnerPart, error) {
+	parts := make([]coOwnerPart, 0, len(shares))
+	remainder := amount
+	for _, share := range shares {
+		// splits the value in two so that multiplying by the percentage
+		// does not overflow
+		value := amount.Value/100*int64(share.Percent) + amount.Value%100*int64(share.Percent)/100
+		part := NewAmount(value, amount.Currency)
+
+		var err error
+		remainder, err = remainder.Sub(part)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, coOwnerPart{owner: share.Owner, amount: part})
+	}
+
+	if len(parts) > 0 {
+		var err error
+		parts[0].amount, err = parts[0].amount.Add(remainder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parts, nil
+}
+
+// canAfford returns the funds with which every co-owner pays their part of
+// a repair of the car, and tells whether each of them has the money for it
+func (s *SmartContract) canAfford(ctx contractapi.TransactionContextInterface, car *Car, parts []coOwnerPart) ([]*ownerFunds, bool, error) {
+	endorsingOrgs, err := s.GetCarEndorsingOrgs(ctx, strconv.Itoa(car.Id))
+	if err != nil {
+		return nil, false, err
+	}
+
+	payers := make([]*ownerFunds, 0, len(parts))
+	for _, part := range parts {
+		funds, err := s.fundsOf(ctx, part.owner, endorsingOrgs)
+		if err != nil {
+			return nil, false, err
+		}
+
+		sufficient, err := funds.covers(part.amount)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !sufficient {
+			return nil, false, nil
+		}
+		payers = append(payers, funds)
+	}
+
+	return payers, true, nil
+}
+
+// paySellers splits the proceeds of a sale, or the rent of a lease, among
+// the co-owners holding the shares, as payments for them to collect
+func (s *SmartContract) paySellers(ctx contractapi.TransactionContextInterface, shares []OwnerShare, proceeds Amount, reason string) error {
+	parts, err := s.coOwnerParts(ctx, shares, proceeds)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		err = s.payOwner(ctx, part.owner, part.amount, reason)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSaleConsent makes sure that co-owners holding the sale majority of
+// the car approved the sale to its approved buyer
+func checkSaleConsent(car *Car) error {
+	if len(car.Shares) == 0 {
+		return nil
+	}
+
+	consented := 0
+	for _, ownerId := range car.SaleConsents {
+		consented += ownerPercent(car, ownerId)
+	}
+
+	if consented < car.SaleMajority {
+		return newError(CodeNotApproved, "co-owners of car %d holding %d percent approved the sale to %s, %d percent are needed", car.Id, consented, ownerKey(car.ApprovedBuyer), car.SaleMajority)
+	}
+
+	return nil
+}
+
+// carShares returns the shares of the car. A car that is not co-owned is
+// held fully by its owner.
+func carShares(car *Car) []OwnerShare {
+	if len(car.Shares) == 0 {
+		return []OwnerShare{{Owner: car.Owner, Percent: 100}}
+	}
+
+	return car.Shares
+}
+
+// ownerPercent returns the share of the car held by the owner with the given
+// numeric id
+func ownerPercent(car *Car, ownerId string) int {
+	for _, share := range carShares(car) {
+		if share.Owner == ownerId {
+			return share.Percent
+		}
+	}
+
+	return 0
+}
+
+// soleOwnership makes the car fully owned by its owner again, after it was
+// sold as a whole, and drops shares the previous owner proposed
+func soleOwnership(car *Car) {
+	car.Shares = nil
+	car.ProposedShares = nil
+	car.SaleMajority = 0
+	car.SaleConsents = nil
+	car.ApprovedBuyer = ""
+}
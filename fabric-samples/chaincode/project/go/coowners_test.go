@@ -0,0 +1,200 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoOwnership(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.SetCarShares(ctx.asOwner(2), "4", []OwnerShare{{Owner: "OWNER2", Percent: 60}, {Owner: "OWNER3", Percent: 30}}, 100)
+	requireError(t, err, CodeInvalidArgument, "shares of car 4 sum to 90 percent, not 100")
+
+	err = s.SetCarShares(ctx.asOwner(2), "4", []OwnerShare{{Owner: "OWNER1", Percent: 60}, {Owner: "OWNER3", Percent: 40}}, 100)
+	requireError(t, err, CodeInvalidArgument, "owner OWNER2 of car 4 must keep a share")
+
+	err = s.AcceptCarShares(ctx.asOwner(3), "4")
+	requireError(t, err, CodeInvalidState, "no shares of car 4 are proposed")
+
+	err = s.SetCarShares(ctx.asOwner(2), "4", []OwnerShare{{Owner: "OWNER2", Percent: 60}, {Owner: "OWNER3", Percent: 40}}, 100)
+	require.NoError(t, err)
+
+	// the shares wait for the co-owner to accept theirs
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Empty(t, car.Shares)
+	require.Equal(t, []string{"2"}, car.ProposedShares.AcceptedBy)
+
+	err = s.AcceptCarShares(ctx.asOwner(1), "4")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to accept shares of car 4, is not granted one")
+
+	err = s.AcceptCarShares(ctx.asOwner(2), "4")
+	requireError(t, err, CodeInvalidState, "OWNER2 already accepted the shares of car 4")
+
+	err = s.AcceptCarShares(ctx.asOwner(3), "4")
+	require.NoError(t, err)
+
+	err = s.AcceptCarShares(ctx.asOwner(3), "4")
+	requireError(t, err, CodeInvalidState, "no shares of car 4 are proposed")
+
+	owned, err := s.GetCarsByOwner(ctx, "OWNER3")
+	require.NoError(t, err)
+	percents := map[int]int{}
+	for _, o := range owned {
+		percents[o.Car.Id] = o.Percent
+	}
+	require.Equal(t, 40, percents[4])
+	require.Equal(t, 100, percents[5])
+
+	err = s.ApproveTransfer(ctx.asOwner(3), "4", "OWNER2")
+	requireError(t, err, CodeInvalidState, "OWNER2 already owns car 4")
+
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER1")
+	require.NoError(t, err)
+
+	err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+	requireError(t, err, CodeNotApproved, "co-owners of car 4 holding 60 percent approved the sale to OWNER1, 100 percent are needed")
+
+	err = s.ApproveTransfer(ctx.asOwner(3), "4", "OWNER1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+		require.NoError(t, err)
+	})
+
+	car, err = s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, "1", car.Owner)
+	require.Empty(t, car.Shares)
+
+	// the price is split by the shares the co-owners held, and each collects
+	// their part
+	payments, err := s.GetPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, PaymentSale, payments[0].Reason)
+	require.Equal(t, NewAmount(420000, "EUR"), payments[0].Amount)
+
+	_, err = s.CollectPayments(ctx.asOwner(3), "OWNER2")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER2")
+
+	seller, err := s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(920000, "EUR"), seller.Money)
+
+	seller, err = s.CollectPayments(ctx.asOwner(3), "OWNER3")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(780000, "EUR"), seller.Money)
+
+	payments, err = s.GetPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Empty(t, payments)
+
+	seller, err = s.GetOwnerById(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(920000, "EUR"), seller.Money)
+}
+
+func TestCoOwnersShareRepairs(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.SetCarShares(ctx.asOwner(2), "4", []OwnerShare{{Owner: "OWNER2", Percent: 60}, {Owner: "OWNER3", Percent: 40}}, 100)
+	require.NoError(t, err)
+
+	err = s.AcceptCarShares(ctx.asOwner(3), "4")
+	require.NoError(t, err)
+
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.AddMalfunction(ctx, "4", "Worn brakes", 10001, SeverityMedium)
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx.asOwner(2), "4", "shop1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.RepairCar(ctx.asMechanic("Org2MSP"), "4", "shop1")
+		require.NoError(t, err)
+	})
+
+	// the cent that does not split evenly is paid by the first co-owner
+	expectedMoney := map[int]int64{2: 493999, 3: 496000}
+	for ownerId, money := range expectedMoney {
+		owner, err := s.GetOwnerById(ctx.asOwner(ownerId), fmt.Sprintf("OWNER%d", ownerId))
+		require.NoError(t, err)
+		require.Equal(t, NewAmount(money, "EUR"), owner.Money)
+	}
+
+	shop, err := s.GetRepairShop(ctx, "shop1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(10001, "EUR"), shop.Money)
+}
+
+func TestCoOwnersOfOtherOrganizationsShareRepairs(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.CreateOwner(ctx.as("x509::CN=ana", "Org2MSP", map[string]string{adminAttribute: "true"}).withOwnerDetails("Ana", "Anic", "ana@example.com", 100000), "")
+	require.NoError(t, err)
+
+	err = s.SetCarShares(ctx.asOwner(2), "4", []OwnerShare{{Owner: "OWNER2", Percent: 50}, {Owner: "OWNER4", Percent: 50}}, 100)
+	require.NoError(t, err)
+
+	err = s.AcceptCarShares(ctx.as("x509::CN=ana", "Org2MSP", nil), "4")
+	require.NoError(t, err)
+
+	orgs, err := s.GetCarEndorsingOrgs(ctx, "4")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"Org1MSP", "Org2MSP"}, orgs)
+
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.AddMalfunction(ctx, "4", "Worn brakes", 10000, SeverityMedium)
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx.asOwner(2), "4", "shop1")
+	require.NoError(t, err)
+
+	// neither organization can read the balance of the other's co-owner, so
+	// both pay with deposited funds
+	repair := func() error {
+		return s.RepairCar(ctx.asMechanic("Org2MSP"), "4", "shop1")
+	}
+
+	err = ctx.endorsed(t, repair, "Org1MSP", "Org2MSP")
+	requireError(t, err, CodeInsufficientFunds, "owner does not have enough money to repair car 4")
+
+	_, err = s.DepositFunds(ctx.asOwner(2), "OWNER2", 5000)
+	require.NoError(t, err)
+
+	_, err = s.DepositFunds(ctx.as("x509::CN=ana", "Org2MSP", nil), "OWNER4", 8000)
+	require.NoError(t, err)
+
+	err = ctx.endorsed(t, repair, "Org1MSP", "Org2MSP")
+	require.NoError(t, err)
+
+	payments, err := s.GetPayments(ctx, "OWNER2")
+	require.NoError(t, err)
+	require.Empty(t, payments)
+
+	payments, err = s.GetPayments(ctx, "OWNER4")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, NewAmount(3000, "EUR"), payments[0].Amount)
+
+	shop, err := s.GetRepairShop(ctx, "shop1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(10000, "EUR"), shop.Money)
+}
@@ -0,0 +1,131 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// GetCarEndorsingOrgs returns the organizations whose peers must endorse
+// changes to the car. An empty list means that the chaincode endorsement
+// policy applies, which is the case for cars of owners not yet bound to an
+// organization.
+func (s *SmartContract) GetCarEndorsingOrgs(ctx contractapi.TransactionContextInterface, carId string) ([]string, error) {
+	exists, err := s.CarExists(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errCarNotFound(carId)
+	}
+
+	policy, err := ctx.GetStub().GetStateValidationParameter(carKey(carId))
+	if err != nil {
+		return nil, errLedger("failed to read validation parameter of car %s: %v", carId, err)
+	}
+
+	if len(policy) == 0 {
+		return []string{}, nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return endorsementPolicy.ListOrgs(), nil
+}
+
+// setCarEndorsement makes the peers of the organizations of the car owners
+// the only endorsers of the car key, so that peers of other organizations can
+// not change the car on their own. A car that changes owner takes the
+// organization of the new owner, while the transfer itself is still endorsed
+// under the old policy. Owners that are not bound to an organization leave
+// the policy as it is.
+func setCarEndorsement(ctx contractapi.TransactionContextInterface, carId string, mspIDs ...string) error {
+	orgs := make([]string, 0, len(mspIDs))
+	for _, mspID := range mspIDs {
+		if mspID == "" {
+			return nil
+		}
+
+		known := false
+		for _, org := range orgs {
+			known = known || org == mspID
+		}
+		if !known {
+			orgs = append(orgs, mspID)
+		}
+	}
+
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return newError(CodeInternal, "failed to add org to endorsement policy: %v", err)
+	}
+
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return newError(CodeInternal, "failed to create endorsement policy bytes from org: %v", err)
+	}
+
+	err = ctx.GetStub().SetStateValidationParameter(carKey(carId), policy)
+	if err != nil {
+		return errLedger("failed to set validation parameter on car %s: %v", carId, err)
+	}
+
+	return nil
+}
+
+// setOwnerCarsEndorsement moves the endorsement policy of every car the owner
+// holds a share of to the organizations of its owners, including the one the
+// owner is now bound to
+func (s *SmartContract) setOwnerCarsEndorsement(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	cars, err := s.GetAllCars(ctx)
+	if err != nil {
+		return err
+	}
+
+	ownerId := strconv.Itoa(owner.Id)
+	for _, car := range cars {
+		if ownerPercent(car, ownerId) == 0 {
+			continue
+		}
+
+		// the record of the owner is not readable before the transaction
+		// commits, so its new organization is taken from the owner itself
+		orgs := make([]string, 0, len(carShares(car)))
+		for _, share := range carShares(car) {
+			if share.Owner == ownerId {
+				orgs = append(orgs, owner.MSPID)
+				continue
+			}
+
+			record, err := s.GetOwnerRecord(ctx, ownerKey(share.Owner))
+			if err != nil {
+				return err
+			}
+			orgs = append(orgs, record.MSPID)
+		}
+
+		err = setCarEndorsement(ctx, strconv.Itoa(car.Id), orgs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
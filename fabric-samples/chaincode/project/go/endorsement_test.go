@@ -0,0 +1,44 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarEndorsement(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	orgs, err := s.GetCarEndorsingOrgs(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, []string{"Org1MSP"}, orgs)
+
+	_, err = s.CreateOwner(ctx.as("x509::CN=buyer", "Org2MSP", map[string]string{adminAttribute: "true"}).withOwnerDetails("Ana", "Anic", "ana@example.com", 10000000), "")
+	require.NoError(t, err)
+
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER4")
+	require.NoError(t, err)
+
+	_, err = s.DepositFunds(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4", 700000)
+	require.NoError(t, err)
+
+	// the peers of the seller's organization endorse the sale, those of the
+	// buyer's as well
+	err = ctx.endorsed(t, func() error {
+		return s.TransferOwnership(ctx.as("x509::CN=buyer", "Org2MSP", nil), "4", "OWNER4", false)
+	}, "Org1MSP", "Org2MSP")
+	require.NoError(t, err)
+
+	// the car now needs the peers of the buyer's organization
+	orgs, err = s.GetCarEndorsingOrgs(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, []string{"Org2MSP"}, orgs)
+
+	_, err = s.GetCarEndorsingOrgs(ctx, "99")
+	requireError(t, err, CodeCarNotFound, "99 does not exist")
+}
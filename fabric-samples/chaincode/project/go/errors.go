@@ -0,0 +1,113 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Error codes of failed transactions. Clients can rely on the codes, while
+// the messages are meant for people and may change.
+const (
+	CodeCarNotFound        = "CAR_NOT_FOUND"
+	CodeOwnerNotFound      = "OWNER_NOT_FOUND"
+	CodeNotFound           = "NOT_FOUND"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+	CodeInvalidArgument    = "INVALID_ARGUMENT"
+	CodeInvalidAmount      = "INVALID_AMOUNT"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeInsufficientFunds  = "INSUFFICIENT_FUNDS"
+	CodeHasMalfunctions    = "HAS_MALFUNCTIONS"
+	CodeInvalidState       = "INVALID_STATE"
+	CodeNotApproved        = "NOT_APPROVED"
+	CodeCarListed          = "CAR_LISTED"
+	CodeCarLeased          = "CAR_LEASED"
+	CodeActiveLien         = "ACTIVE_LIEN"
+	CodeInspectionRequired = "INSPECTION_REQUIRED"
+	CodeLedger             = "LEDGER_ERROR"
+	CodeInternal           = "INTERNAL"
+)
+
+// CarError is the error of a failed transaction. It is returned to clients
+// as JSON, so that they can tell by its code why the transaction failed and
+// read the details without parsing the message.
+type CarError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// newError returns an error with the code and a message formatted like
+// fmt.Errorf does
+func newError(code string, format string, args ...interface{}) *CarError {
+	return &CarError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// with adds a detail to the error
+func (e *CarError) with(key string, value string) *CarError {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *CarError) Error() string {
+	errAsBytes, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+
+	return string(errAsBytes)
+}
+
+// errCarNotFound is returned for car ids that are not in world state
+func errCarNotFound(carId string) *CarError {
+	return newError(CodeCarNotFound, "%s does not exist", carId).with("carId", carId)
+}
+
+// errLedger wraps a failure of the peer to read or write state
+func errLedger(format string, args ...interface{}) *CarError {
+	return newError(CodeLedger, format, args...)
+}
+
+// codedChaincode makes sure that every failed transaction returns a CarError.
+// Errors that do not carry a code, such as those of the contract API
+// rejecting malformed arguments, are returned as INTERNAL.
+type codedChaincode struct {
+	shim.Chaincode
+}
+
+func (c codedChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return codeResponse(c.Chaincode.Init(stub))
+}
+
+func (c codedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	return codeResponse(c.Chaincode.Invoke(stub))
+}
+
+func codeResponse(response peer.Response) peer.Response {
+	if response.Status < shim.ERRORTHRESHOLD || isCarError(response.Message) {
+		return response
+	}
+
+	response.Message = newError(CodeInternal, "%s", response.Message).Error()
+	return response
+}
+
+// isCarError tells whether the message is an encoded CarError
+func isCarError(message string) bool {
+	if !strings.HasPrefix(message, "{") {
+		return false
+	}
+
+	var carErr CarError
+	return json.Unmarshal([]byte(message), &carErr) == nil && carErr.Code != ""
+}
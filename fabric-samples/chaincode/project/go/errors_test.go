@@ -0,0 +1,67 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodes(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.TransferOwnership(ctx.asOwner(1), "99", "OWNER1", false)
+	requireError(t, err, CodeCarNotFound, "99 does not exist")
+	require.Equal(t, map[string]string{"carId": "99"}, err.(*CarError).Details)
+
+	err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER9", false)
+	requireError(t, err, CodeOwnerNotFound, "OWNER9 does not exist")
+
+	err = s.ApproveTransfer(ctx.asOwner(1), "1", "OWNER2")
+	require.NoError(t, err)
+
+	err = s.TransferOwnership(ctx.asOwner(2), "1", "OWNER2", false)
+	requireError(t, err, CodeHasMalfunctions, "car has malfunctions and new owner does not want them")
+	require.Equal(t, map[string]string{"carId": "1", "malfunctions": "1"}, err.(*CarError).Details)
+
+	// a repair the owner can not pay for fails instead of doing nothing
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "4", "Engine failure", 600000, SeverityHigh)
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx.asOwner(2), "4", "shop1")
+	require.NoError(t, err)
+
+	err = s.RepairCar(ctx.asMechanic("Org2MSP"), "4", "shop1")
+	requireError(t, err, CodeInsufficientFunds, "owner does not have enough money to repair car 4")
+	require.Equal(t, "6000.00 EUR", err.(*CarError).Details["price"])
+
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, MalfunctionInRepair, car.Malfunctions[0].Status)
+
+	// errors of the contract API are returned as INTERNAL
+	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("cars", codedChaincode{chaincode})
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("NoSuchTransaction")})
+	require.Equal(t, int32(shim.ERROR), response.Status)
+
+	var carErr CarError
+	require.NoError(t, json.Unmarshal([]byte(response.Message), &carErr))
+	require.Equal(t, CodeInternal, carErr.Code)
+	require.Equal(t, "Function NoSuchTransaction not found in contract SmartContract", carErr.Message)
+	coded := errCarNotFound("7").Error()
+	require.Equal(t, coded, codeResponse(shim.Error(coded)).Message)
+}
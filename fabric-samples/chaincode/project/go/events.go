@@ -0,0 +1,63 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Names of the chaincode events emitted on car state changes. Fabric keeps
+// only one event per transaction, so each transaction emits the event that
+// describes its outcome.
+const (
+	EventCarCreated        = "CarCreated"
+	EventCarColorChanged   = "CarColorChanged"
+	EventMalfunctionAdded  = "MalfunctionAdded"
+	EventCarRepaired       = "CarRepaired"
+	EventOwnershipTransfer = "CarOwnershipTransferred"
+	EventCarDeleted        = "CarDeleted"
+	EventCarScrapped       = "CarScrapped"
+	EventMileageRecorded   = "MileageRecorded"
+	EventCarStolen         = "CarStolen"
+	EventCarRecovered      = "CarRecovered"
+	EventCarLeased         = "CarLeased"
+	EventCarReturned       = "CarReturned"
+)
+
+// CarEvent is the payload of every car chaincode event
+type CarEvent struct {
+	Type     string `json:"type"`
+	CarId    string `json:"carId"`
+	OldValue string `json:"oldValue,omitempty"`
+	NewValue string `json:"newValue,omitempty"`
+	Amount   Amount `json:"amount"`
+	TxId     string `json:"txId"`
+}
+
+// emitCarEvent sets the chaincode event of the transaction
+func emitCarEvent(ctx contractapi.TransactionContextInterface, eventType string, carId string, oldValue string, newValue string, amount Amount) error {
+	event := CarEvent{
+		Type:     eventType,
+		CarId:    carId,
+		OldValue: oldValue,
+		NewValue: newValue,
+		Amount:   amount,
+		TxId:     ctx.GetStub().GetTxID(),
+	}
+
+	eventAsBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().SetEvent(eventType, eventAsBytes)
+	if err != nil {
+		return errLedger("failed to set event %s: %v", eventType, err)
+	}
+
+	return nil
+}
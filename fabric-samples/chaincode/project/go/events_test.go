@@ -0,0 +1,56 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarEvents(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.ChangeCarColor(ctx.asOwner(1), "1", "red")
+	require.NoError(t, err)
+
+	name, event := ctx.lastEvent(t)
+	require.Equal(t, EventCarColorChanged, name)
+	require.Equal(t, CarEvent{Type: EventCarColorChanged, CarId: "1", OldValue: "blue", NewValue: "red", TxId: "tx1"}, event)
+
+	err = s.ApproveTransfer(ctx.asOwner(3), "6", "OWNER1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.TransferOwnership(ctx.asOwner(1), "6", "OWNER1", false)
+		require.NoError(t, err)
+	})
+
+	name, event = ctx.lastEvent(t)
+	require.Equal(t, EventOwnershipTransfer, name)
+	require.Equal(t, "3", event.OldValue)
+	require.Equal(t, "1", event.NewValue)
+	require.Equal(t, NewAmount(200000, "EUR"), event.Amount)
+
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "6", "Engine failure", 190000, SeverityCritical)
+	require.NoError(t, err)
+
+	name, event = ctx.lastEvent(t)
+	require.Equal(t, EventMalfunctionAdded, name)
+	require.Equal(t, "Engine failure", event.NewValue)
+
+	err = s.AddMalfunction(ctx, "6", "Broken gearbox", 20000, SeverityHigh)
+	require.NoError(t, err)
+
+	name, event = ctx.lastEvent(t)
+	require.Equal(t, EventCarScrapped, name)
+	require.Equal(t, NewAmount(210000, "EUR"), event.Amount)
+
+	// scrapped cars are archived, not removed
+	car, err := s.GetCarById(ctx, "6")
+	require.NoError(t, err)
+	require.Equal(t, CarScrapped, car.Status)
+}
@@ -0,0 +1,300 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Kinds of changes in the timeline of a car
+const (
+	HistoryCreated          = "CREATED"
+	HistoryOwnershipChanged = "OWNERSHIP_CHANGED"
+	HistoryColorChanged     = "COLOR_CHANGED"
+	HistoryMalfunctionAdded = "MALFUNCTION_ADDED"
+	HistoryRepaired         = "REPAIRED"
+	HistoryMileageRecorded  = "MILEAGE_RECORDED"
+	HistoryStatusChanged    = "STATUS_CHANGED"
+	HistoryDeleted          = "DELETED"
+)
+
+// CarHistoryRecord is one version of a car as written by a transaction
+type CarHistoryRecord struct {
+	Record    *Car      `json:"record"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// CarHistoryEvent is a single change between two versions of a car
+type CarHistoryEvent struct {
+	Type        string    `json:"type"`
+	TxId        string    `json:"txId"`
+	Timestamp   time.Time `json:"timestamp"`
+	From        string    `json:"from,omitempty"`
+	To          string    `json:"to,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Amount      Amount    `json:"amount"`
+}
+
+// CarHistory is the vehicle history report of a car
+type CarHistory struct {
+	CarId    string             `json:"carId"`
+	Records  []CarHistoryRecord `json:"records"`
+	Timeline []CarHistoryEvent  `json:"timeline"`
+}
+
+// GetCarHistory returns every version of the car since it was created, oldest
+// first, together with the timeline of changes derived from them. Versions
+// written under the bare numeric id, before MigrateKeys moved the car to its
+// CAR key, are part of the history as well.
+func (s *SmartContract) GetCarHistory(ctx contractapi.TransactionContextInterface, carId string) (*CarHistory, error) {
+	// the bare key of anything but a car id may hold an owner or another record
+	id, err := strconv.Atoi(carId)
+	if err != nil || id <= 0 {
+		return nil, newError(CodeInvalidArgument, "car id %s must be a positive number", carId)
+	}
+
+	legacyRecords, err := carHistoryRecords(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := carHistoryRecords(ctx, carKey(carId))
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := make(map[string]bool)
+	for _, record := range records {
+		migrated[record.TxId] = true
+	}
+
+	// the migration deletes the legacy record in the transaction that writes
+	// the new one, which is not a deletion of the car
+	for _, record := range legacyRecords {
+		if !(record.IsDelete && migrated[record.TxId]) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errCarNotFound(carId)
+	}
+
+	// the order of history entries differs between peer versions
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+
+	return &CarHistory{
+		CarId:    carId,
+		Records:  records,
+		Timeline: carTimeline(records),
+	}, nil
+}
+
+// carHistoryRecords reads every version written under the world state key.
+// Amounts of versions written before MigrateAmounts are converted to cents.
+func carHistoryRecords(ctx contractapi.TransactionContextInterface, key string) ([]CarHistoryRecord, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := make([]CarHistoryRecord, 0)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var car Car
+		if len(response.Value) > 0 {
+			carAsBytes, _, err := convertLegacyAmounts(response.Value, defaultCurrency, "price")
+			if err != nil {
+				return nil, err
+			}
+
+			err = json.Unmarshal(carAsBytes, &car)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		record := CarHistoryRecord{
+			TxId:      response.TxId,
+			Timestamp: timestamp,
+			Record:    &car,
+			IsDelete:  response.IsDelete,
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// carTimeline compares consecutive versions of a car and lists what changed
+func carTimeline(records []CarHistoryRecord) []CarHistoryEvent {
+	timeline := make([]CarHistoryEvent, 0)
+
+	var previous *Car
+	for _, record := range records {
+		event := CarHistoryEvent{TxId: record.TxId, Timestamp: record.Timestamp}
+
+		if record.IsDelete {
+			event.Type = HistoryDeleted
+			timeline = append(timeline, event)
+			previous = nil
+			continue
+		}
+
+		current := record.Record
+		if previous == nil {
+			event.Type = HistoryCreated
+			event.To = current.Owner
+			event.Amount = current.Price
+			timeline = append(timeline, event)
+
+			for _, malfunction := range current.Malfunctions {
+				timeline = append(timeline, malfunctionEvent(event, HistoryMalfunctionAdded, malfunction))
+			}
+
+			previous = current
+			continue
+		}
+
+		if previous.Owner != current.Owner {
+			event.Type = HistoryOwnershipChanged
+			event.From = previous.Owner
+			event.To = current.Owner
+			timeline = append(timeline, event)
+		}
+
+		if previous.Color != current.Color {
+			event.Type = HistoryColorChanged
+			event.From = previous.Color
+			event.To = current.Color
+			timeline = append(timeline, event)
+		}
+
+		for _, malfunction := range repairedMalfunctions(previous.Malfunctions, current.Malfunctions) {
+			timeline = append(timeline, malfunctionEvent(event, HistoryRepaired, malfunction))
+		}
+
+		for _, malfunction := range addedMalfunctions(previous.Malfunctions, current.Malfunctions) {
+			timeline = append(timeline, malfunctionEvent(event, HistoryMalfunctionAdded, malfunction))
+		}
+
+		if carStatus(previous) != carStatus(current) {
+			event.Type = HistoryStatusChanged
+			event.From = carStatus(previous)
+			event.To = carStatus(current)
+			timeline = append(timeline, event)
+		}
+
+		if len(current.OdometerReadings) > len(previous.OdometerReadings) {
+			for _, reading := range current.OdometerReadings[len(previous.OdometerReadings):] {
+				timeline = append(timeline, mileageEvent(event, previous.Mileage, reading))
+			}
+		}
+
+		previous = current
+	}
+
+	return timeline
+}
+
+func malfunctionEvent(event CarHistoryEvent, eventType string, malfunction Malfunction) CarHistoryEvent {
+	return CarHistoryEvent{
+		Type:        eventType,
+		TxId:        event.TxId,
+		Timestamp:   event.Timestamp,
+		Description: malfunction.Description,
+		Amount:      malfunction.Price,
+	}
+}
+
+func mileageEvent(event CarHistoryEvent, previousMileage int, reading OdometerReading) CarHistoryEvent {
+	mileageEvent := CarHistoryEvent{
+		Type:      HistoryMileageRecorded,
+		TxId:      event.TxId,
+		Timestamp: event.Timestamp,
+		From:      strconv.Itoa(previousMileage),
+		To:        strconv.Itoa(reading.Mileage),
+	}
+
+	if reading.Suspicious {
+		mileageEvent.Description = "suspected odometer tampering"
+	}
+
+	return mileageEvent
+}
+
+// malfunctionKey identifies a malfunction across versions of a car. Records
+// written before malfunctions had ids are matched by their contents.
+func malfunctionKey(malfunction Malfunction) string {
+	if malfunction.Id != "" {
+		return malfunction.Id
+	}
+
+	return fmt.Sprintf("%s|%v", malfunction.Description, malfunction.Price)
+}
+
+// repairedMalfunctions returns the unrepaired malfunctions of the previous
+// version that are either repaired or gone in the current one
+func repairedMalfunctions(previous []Malfunction, current []Malfunction) []Malfunction {
+	currentByKey := make(map[string]Malfunction)
+	for _, malfunction := range current {
+		currentByKey[malfunctionKey(malfunction)] = malfunction
+	}
+
+	var result []Malfunction
+	for _, malfunction := range previous {
+		if malfunction.Status == MalfunctionRepaired {
+			continue
+		}
+
+		repaired, found := currentByKey[malfunctionKey(malfunction)]
+		if !found {
+			result = append(result, malfunction)
+		} else if repaired.Status == MalfunctionRepaired {
+			result = append(result, repaired)
+		}
+	}
+
+	return result
+}
+
+// addedMalfunctions returns the malfunctions of the current version that the
+// previous one did not have
+func addedMalfunctions(previous []Malfunction, current []Malfunction) []Malfunction {
+	previousKeys := make(map[string]bool)
+	for _, malfunction := range previous {
+		previousKeys[malfunctionKey(malfunction)] = true
+	}
+
+	var result []Malfunction
+	for _, malfunction := range current {
+		if !previousKeys[malfunctionKey(malfunction)] {
+			result = append(result, malfunction)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,63 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarTimeline(t *testing.T) {
+	brake := Malfunction{Description: "Broken brake", Price: NewAmount(20000, "EUR")}
+	window := Malfunction{Description: "Broken window", Price: NewAmount(10000, "EUR")}
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	versions := []*Car{
+		{Id: 1, Color: "blue", Owner: "1", Malfunctions: []Malfunction{brake}, Price: NewAmount(500000, "EUR")},
+		{Id: 1, Color: "red", Owner: "1", Malfunctions: []Malfunction{brake, window}, Price: NewAmount(500000, "EUR")},
+		{Id: 1, Color: "red", Owner: "1", Malfunctions: []Malfunction{}, Price: NewAmount(500000, "EUR")},
+		{Id: 1, Color: "red", Owner: "2", Malfunctions: []Malfunction{}, Price: NewAmount(500000, "EUR")},
+		nil,
+	}
+
+	var records []CarHistoryRecord
+	for i, version := range versions {
+		records = append(records, CarHistoryRecord{
+			Record:    version,
+			TxId:      fmt.Sprintf("tx%d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			IsDelete:  version == nil,
+		})
+	}
+
+	var types []string
+	for _, event := range carTimeline(records) {
+		types = append(types, event.TxId+":"+event.Type)
+	}
+
+	require.Equal(t, []string{
+		"tx0:CREATED",
+		"tx0:MALFUNCTION_ADDED",
+		"tx1:COLOR_CHANGED",
+		"tx1:MALFUNCTION_ADDED",
+		"tx2:REPAIRED",
+		"tx2:REPAIRED",
+		"tx3:OWNERSHIP_CHANGED",
+		"tx4:DELETED",
+	}, types)
+}
+
+func TestCarHistoryOfOtherKeys(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	for _, carId := range []string{"OWNER1", "0", "-1", ""} {
+		_, err := s.GetCarHistory(ctx, carId)
+		requireError(t, err, CodeInvalidArgument, fmt.Sprintf("car id %s must be a positive number", carId))
+	}
+}
@@ -0,0 +1,178 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// adminAttribute marks identities that may bind owners to client identities
+const adminAttribute = "cars.admin"
+
+// getSubmittingClientIdentity returns the name and issuer of the identity that
+// invokes the smart contract, base64 decoded.
+func getSubmittingClientIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
+	b64ID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", errLedger("failed to read clientID: %v", err)
+	}
+	decodeID, err := base64.StdEncoding.DecodeString(b64ID)
+	if err != nil {
+		return "", newError(CodeInternal, "failed to base64 decode clientID: %v", err)
+	}
+	return string(decodeID), nil
+}
+
+// getSubmittingClientOrg returns the MSP ID of the identity that invokes the
+// smart contract.
+func getSubmittingClientOrg(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", errLedger("failed getting client's orgID: %v", err)
+	}
+	return mspID, nil
+}
+
+// authorizeOwner makes sure that the submitting client is the identity bound
+// to the given owner.
+func authorizeOwner(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	if owner.ClientId == "" {
+		return newError(CodeUnauthorized, "owner %s is not bound to a client identity", ownerKey(strconv.Itoa(owner.Id)))
+	}
+
+	clientID, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if clientID != owner.ClientId || mspID != owner.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not authorized to act as owner %s", ownerKey(strconv.Itoa(owner.Id)))
+	}
+
+	return nil
+}
+
+// authorizeCarOwner makes sure that the submitting client is the owner of the car
+func (s *SmartContract) authorizeCarOwner(ctx contractapi.TransactionContextInterface, car *Car) error {
+	_, err := s.submittingOwner(ctx, car.Owner)
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized to update car %d, does not own it", car.Id)
+	}
+
+	return nil
+}
+
+// submittingOwner returns the public record of the owner with the given
+// numeric id if the submitting client is bound to it. The client is checked
+// against the hash of the bound identity in the record, so that the peers of
+// organizations that can not read the owner's collection endorse it as well.
+func (s *SmartContract) submittingOwner(ctx contractapi.TransactionContextInterface, ownerId string) (*OwnerRecord, error) {
+	record, err := s.GetOwnerRecord(ctx, ownerKey(ownerId))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizeOwnerRecord(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// authorizeOwnerRecord makes sure that the submitting client is bound to the
+// owner with the public record. Owners of other organizations are refused
+// without reading their collections.
+func (s *SmartContract) authorizeOwnerRecord(ctx contractapi.TransactionContextInterface, record *OwnerRecord) error {
+	err := authorizeOwnerRead(ctx, record)
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client is not authorized to act as owner %s", ownerKey(strconv.Itoa(record.Id)))
+	}
+
+	// records written before they held the hash of the bound identity are
+	// checked against the private details
+	if record.ClientIdHash == "" {
+		owner, err := readOwner(ctx, record)
+		if err != nil {
+			return err
+		}
+
+		return authorizeOwner(ctx, owner)
+	}
+
+	clientID, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if clientIdHash(clientID) != record.ClientIdHash {
+		return newError(CodeUnauthorized, "submitting client is not authorized to act as owner %s", ownerKey(strconv.Itoa(record.Id)))
+	}
+
+	return nil
+}
+
+// BindOwnerIdentity links an existing owner to a client identity and MSP,
+// whose peers then endorse changes to the owner's cars. It is meant for
+// owners that were created by InitLedger and can only be called by
+// identities carrying the cars.admin attribute. An owner stays with the
+// organization whose collection holds it, so the administrator and the
+// identity must both belong to the owner's organization.
+func (s *SmartContract) BindOwnerIdentity(ctx contractapi.TransactionContextInterface, ownerId string, clientId string, mspId string) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized to bind owners, does not have %s role", adminAttribute)
+	}
+
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+
+	if mspId != owner.MSPID {
+		return newError(CodeInvalidArgument, "owner %s belongs to %s and can not be bound to an identity of %s", ownerId, owner.MSPID, mspId)
+	}
+
+	err = s.checkIdentityUnused(ctx, clientId, mspId, owner.Id)
+	if err != nil {
+		return err
+	}
+
+	owner.ClientId = clientId
+	owner.MSPID = mspId
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	return s.setOwnerCarsEndorsement(ctx, owner)
+}
+
+// checkIdentityUnused makes sure that no owner other than ownerId is bound to
+// the given identity. Only owners of the identity's organization can be
+// bound to it, which the submitting client must belong to.
+func (s *SmartContract) checkIdentityUnused(ctx contractapi.TransactionContextInterface, clientId string, mspId string, ownerId int) error {
+	owners, err := s.orgOwners(ctx, mspId)
+	if err != nil {
+		return err
+	}
+
+	for _, owner := range owners {
+		if owner.Id != ownerId && owner.ClientId == clientId && owner.MSPID == mspId {
+			return newError(CodeAlreadyExists, "client identity is already bound to owner %s", ownerKey(strconv.Itoa(owner.Id)))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindOwnerIdentity(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.BindOwnerIdentity(ctx, "OWNER1", "x509::CN=User1", "Org1MSP")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to bind owners, does not have cars.admin role")
+
+	ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+	err = s.BindOwnerIdentity(ctx, "OWNER1", "x509::CN=owner2", "Org1MSP")
+	requireError(t, err, CodeAlreadyExists, "client identity is already bound to owner OWNER2")
+}
+
+func TestCarOwnerAuthorization(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.ChangeCarColor(ctx.asOwner(2), "1", "red")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 1, does not own it")
+
+	err = s.DeleteCar(ctx, "1")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 1, does not own it")
+
+	err = s.SendCarToRepairShop(ctx, "1", "shop1")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 1, does not own it")
+
+	err = s.ChangeCarColor(ctx.asOwner(1), "1", "red")
+	require.NoError(t, err)
+}
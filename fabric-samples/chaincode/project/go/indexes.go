@@ -0,0 +1,328 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	colorIndex     = "color~owner~id"
+	makeModelIndex = "make~model~id"
+	ownerIndex     = "owner~id"
+)
+
+// carIndex is a secondary index of cars. Every car has an entry for each list
+// of attributes entries returns, stored as a composite key of the index name,
+// the attributes and the car id, so that cars can be looked up by any leading
+// attributes.
+type carIndex struct {
+	name    string
+	entries func(car *Car) [][]string
+}
+
+// carIndexes are kept up to date by putCar, which diffs the entries of the
+// stored car against those of the new one. Scrapped cars are only found
+// through their status. The vin~id index is not one of them, since a VIN
+// stays registered even for cars removed from world state.
+var carIndexes = []carIndex{
+	{name: colorIndex, entries: unlessScrapped(func(car *Car) [][]string {
+		return [][]string{{car.Color, car.Owner}}
+	})},
+	{name: makeModelIndex, entries: unlessScrapped(func(car *Car) [][]string {
+		return [][]string{{strings.ToLower(car.Make), strings.ToLower(car.Model)}}
+	})},
+	{name: ownerIndex, entries: unlessScrapped(func(car *Car) [][]string {
+		entries := make([][]string, 0, len(carShares(car)))
+		for _, share := range carShares(car) {
+			entries = append(entries, []string{share.Owner})
+		}
+		return entries
+	})},
+	{name: statusIndex, entries: func(car *Car) [][]string {
+		return [][]string{{carStatus(car)}}
+	}},
+}
+
+// IndexEntry is an entry of a secondary index, given by the index name and
+// its attributes, the last of which is the car id
+type IndexEntry struct {
+	Index      string   `json:"index"`
+	Attributes []string `json:"attributes"`
+}
+
+// IndexReport lists the entries missing from the secondary indexes of cars
+// and the stale entries that do not match any car
+type IndexReport struct {
+	Cars    int          `json:"cars"`
+	Missing []IndexEntry `json:"missing"`
+	Stale   []IndexEntry `json:"stale"`
+}
+
+// VerifyIndexes compares the secondary indexes of cars with the cars in
+// world state and reports the entries that drifted. It can only be called by
+// identities carrying the cars.admin attribute.
+func (s *SmartContract) VerifyIndexes(ctx contractapi.TransactionContextInterface) (*IndexReport, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return nil, newError(CodeUnauthorized, "submitting client not authorized to verify indexes, does not have %s role", adminAttribute)
+	}
+
+	return s.indexDrift(ctx)
+}
+
+// RebuildIndexes adds the missing entries to the secondary indexes of cars
+// and removes the stale ones, and reports what it repaired. It can only be
+// called by identities carrying the cars.admin attribute.
+func (s *SmartContract) RebuildIndexes(ctx contractapi.TransactionContextInterface) (*IndexReport, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return nil, newError(CodeUnauthorized, "submitting client not authorized to rebuild indexes, does not have %s role", adminAttribute)
+	}
+
+	report, err := s.indexDrift(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range report.Stale {
+		key, err := ctx.GetStub().CreateCompositeKey(entry.Index, entry.Attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range report.Missing {
+		key, err := ctx.GetStub().CreateCompositeKey(entry.Index, entry.Attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		value := []byte{0x00}
+		err = ctx.GetStub().PutState(key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// indexDrift compares the entries of every secondary index with the entries
+// the cars in world state should have
+func (s *SmartContract) indexDrift(ctx contractapi.TransactionContextInterface) (*IndexReport, error) {
+	cars, err := s.GetAllCars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := IndexReport{Cars: len(cars), Missing: []IndexEntry{}, Stale: []IndexEntry{}}
+	for _, index := range carIndexes {
+		expected := make(map[string]bool)
+		for _, car := range cars {
+			for _, attributes := range indexAttributes(index, car) {
+				key, err := ctx.GetStub().CreateCompositeKey(index.name, attributes)
+				if err != nil {
+					return nil, err
+				}
+				expected[key] = true
+
+				exists, err := ctx.GetStub().GetState(key)
+				if err != nil {
+					return nil, errLedger("failed to read from world state: %v", err)
+				}
+				if exists == nil {
+					report.Missing = append(report.Missing, IndexEntry{Index: index.name, Attributes: attributes})
+				}
+			}
+		}
+
+		resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(index.name, []string{})
+		if err != nil {
+			return nil, err
+		}
+
+		for resultIter.HasNext() {
+			responseRange, err := resultIter.Next()
+			if err != nil {
+				resultIter.Close()
+				return nil, err
+			}
+
+			if expected[responseRange.Key] {
+				continue
+			}
+
+			_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+			if err != nil {
+				resultIter.Close()
+				return nil, err
+			}
+			report.Stale = append(report.Stale, IndexEntry{Index: index.name, Attributes: compositeKeyParts})
+		}
+		resultIter.Close()
+	}
+
+	return &report, nil
+}
+
+// carsByIndex returns the cars whose entries in the index start with the
+// given attributes. Stale entries of cars that are no longer in world state
+// are skipped, VerifyIndexes reports them.
+func (s *SmartContract) carsByIndex(ctx contractapi.TransactionContextInterface, index string, attributes ...string) ([]*Car, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	cars := make([]*Car, 0)
+	for resultIter.HasNext() {
+		responseRange, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		carId := compositeKeyParts[len(compositeKeyParts)-1]
+		exists, err := s.CarExists(ctx, carId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		car, err := s.GetCarById(ctx, carId)
+		if err != nil {
+			return nil, err
+		}
+
+		cars = append(cars, car)
+	}
+
+	return cars, nil
+}
+
+// updateCarIndexes moves the index entries of a car from its stored version
+// to the new one. It removes the entries that only the stored version has and
+// writes all entries of the new version, so that a missing entry is restored
+// with the next write of the car. Either car may be nil.
+func updateCarIndexes(ctx contractapi.TransactionContextInterface, stored *Car, car *Car) error {
+	for _, index := range carIndexes {
+		oldKeys, err := indexKeys(ctx, index, stored)
+		if err != nil {
+			return err
+		}
+
+		newKeys, err := indexKeys(ctx, index, car)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range oldKeys {
+			if !containsKey(newKeys, key) {
+				err = ctx.GetStub().DelState(key)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, key := range newKeys {
+			value := []byte{0x00}
+			err = ctx.GetStub().PutState(key, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// storedCar returns the car as it is stored in world state, or nil if there
+// is no car with the given id
+func storedCar(ctx contractapi.TransactionContextInterface, carId string) (*Car, error) {
+	carAsBytes, err := ctx.GetStub().GetState(carKey(carId))
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if carAsBytes == nil {
+		return nil, nil
+	}
+
+	car := new(Car)
+	err = json.Unmarshal(carAsBytes, car)
+	if err != nil {
+		return nil, err
+	}
+
+	return car, nil
+}
+
+// indexAttributes returns the attributes of the entries of the car in the
+// index, each ending with the car id
+func indexAttributes(index carIndex, car *Car) [][]string {
+	if car == nil {
+		return nil
+	}
+
+	entries := index.entries(car)
+	for i := range entries {
+		entries[i] = append(entries[i], strconv.Itoa(car.Id))
+	}
+
+	return entries
+}
+
+// indexKeys returns the composite keys of the entries of the car in the index
+func indexKeys(ctx contractapi.TransactionContextInterface, index carIndex, car *Car) ([]string, error) {
+	var keys []string
+	for _, attributes := range indexAttributes(index, car) {
+		key, err := ctx.GetStub().CreateCompositeKey(index.name, attributes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// unlessScrapped leaves scrapped cars out of an index
+func unlessScrapped(entries func(car *Car) [][]string) func(car *Car) [][]string {
+	return func(car *Car) [][]string {
+		if carStatus(car) == CarScrapped {
+			return nil
+		}
+
+		return entries(car)
+	}
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
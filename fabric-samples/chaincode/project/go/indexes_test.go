@@ -0,0 +1,78 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexes(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	admin := func() *testContext {
+		return ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+	}
+
+	cars, err := s.GetCarsByMakeAndModel(ctx, "volkswagen", "PASSAT")
+	require.NoError(t, err)
+	require.Len(t, cars, 1)
+	require.Equal(t, 4, cars[0].Id)
+
+	err = s.ChangeCarColor(ctx.asOwner(3), "6", "red")
+	require.NoError(t, err)
+
+	cars, err = s.GetCarsByColor(ctx, "black")
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	// a scrapped car is only left in the status~id index
+	err = s.DeleteCar(ctx.asOwner(3), "6")
+	require.NoError(t, err)
+
+	cars, err = s.GetCarsByColor(ctx, "red")
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	cars, err = s.GetCarsByMakeAndModel(ctx, "Peugeot", "205")
+	require.NoError(t, err)
+	require.Empty(t, cars)
+
+	_, err = s.VerifyIndexes(ctx)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to verify indexes, does not have cars.admin role")
+
+	report, err := s.VerifyIndexes(admin())
+	require.NoError(t, err)
+	require.Equal(t, 6, report.Cars)
+	require.Empty(t, report.Missing)
+	require.Empty(t, report.Stale)
+
+	// drift left behind by earlier versions of the contract
+	staleKey, err := ctx.stub.CreateCompositeKey(colorIndex, []string{"black", "3", "6"})
+	require.NoError(t, err)
+	require.NoError(t, ctx.stub.PutState(staleKey, []byte{0x00}))
+
+	missingKey, err := ctx.stub.CreateCompositeKey(statusIndex, []string{CarActive, "1"})
+	require.NoError(t, err)
+	require.NoError(t, ctx.stub.DelState(missingKey))
+
+	report, err = s.VerifyIndexes(admin())
+	require.NoError(t, err)
+	require.Equal(t, []IndexEntry{{Index: statusIndex, Attributes: []string{CarActive, "1"}}}, report.Missing)
+	require.Equal(t, []IndexEntry{{Index: colorIndex, Attributes: []string{"black", "3", "6"}}}, report.Stale)
+
+	_, err = s.RebuildIndexes(admin())
+	require.NoError(t, err)
+
+	report, err = s.VerifyIndexes(admin())
+	require.NoError(t, err)
+	require.Empty(t, report.Missing)
+	require.Empty(t, report.Stale)
+
+	cars, err = s.GetCarsByStatus(ctx, CarActive)
+	require.NoError(t, err)
+	require.Len(t, cars, 5)
+}
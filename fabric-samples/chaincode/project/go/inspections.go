@@ -0,0 +1,449 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	stationObjectType     = "station"
+	certificateObjectType = "certificate"
+
+	// inspectorAttribute marks identities that may inspect cars for the
+	// stations of their organization
+	inspectorAttribute = "cars.inspector"
+
+	// inspectionRequiredConfig is the config entry that tells whether cars can
+	// only change owner with a valid inspection certificate
+	inspectionRequiredConfig = "inspectionRequired"
+)
+
+// InspectionStation belongs to an inspector organization and certifies that
+// cars are roadworthy
+type InspectionStation struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+}
+
+// Defect is a defect found by an inspection, with its price of repair in
+// cents of the car's currency
+type Defect struct {
+	Description string `json:"description"`
+	Price       int64  `json:"price"`
+	Severity    string `json:"severity"`
+}
+
+// Certificate is the outcome of a roadworthiness inspection. The defects
+// found are added to the car as malfunctions, whose ids the certificate
+// keeps. A passing certificate is valid until it expires.
+type Certificate struct {
+	DocType        string    `json:"docType"`
+	Id             string    `json:"id"`
+	CarId          string    `json:"carId"`
+	StationId      string    `json:"stationId"`
+	Passed         bool      `json:"passed"`
+	Mileage        int       `json:"mileage"`
+	MalfunctionIds []string  `json:"malfunctionIds"`
+	IssuedAt       time.Time `json:"issuedAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// RegisterInspectionStation adds an inspection station of the submitting
+// client's organization. Only inspectors can register stations.
+func (s *SmartContract) RegisterInspectionStation(ctx contractapi.TransactionContextInterface, stationId string, name string) (*InspectionStation, error) {
+	err := assertInspector(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if stationId == "" {
+		return nil, newError(CodeInvalidArgument, "inspection station id must not be empty")
+	}
+
+	_, err = s.GetInspectionStation(ctx, stationId)
+	if err == nil {
+		return nil, newError(CodeAlreadyExists, "inspection station %s already exists", stationId)
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	station := InspectionStation{
+		Id:    stationId,
+		Name:  name,
+		MSPID: mspID,
+	}
+
+	err = putStation(ctx, &station)
+	if err != nil {
+		return nil, err
+	}
+
+	return &station, nil
+}
+
+// GetInspectionStation returns the inspection station with given id
+func (s *SmartContract) GetInspectionStation(ctx contractapi.TransactionContextInterface, stationId string) (*InspectionStation, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(stationObjectType, []string{stationId})
+	if err != nil {
+		return nil, err
+	}
+
+	stationAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if stationAsBytes == nil {
+		return nil, newError(CodeNotFound, "inspection station %s does not exist", stationId)
+	}
+
+	station := new(InspectionStation)
+	err = json.Unmarshal(stationAsBytes, station)
+	if err != nil {
+		return nil, err
+	}
+
+	return station, nil
+}
+
+// IssueCertificate records the inspection of the car by the station. Every
+// defect found becomes an open malfunction of the car, and a car with a
+// critical defect can not pass. A car whose open malfunctions come to cost
+// more than the car is scrapped, as with AddMalfunction. The certificate
+// expires after the given number of days. It is called by an inspector of
+// the organization the station belongs to.
+func (s *SmartContract) IssueCertificate(ctx contractapi.TransactionContextInterface, stationId string, carId string, passed bool, defects []Defect, validDays int) (*Certificate, error) {
+	station, err := s.GetInspectionStation(ctx, stationId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeInspector(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	if validDays <= 0 {
+		return nil, newError(CodeInvalidArgument, "certificate must be valid for at least one day")
+	}
+
+	issuedAt, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := Certificate{
+		Id:             ctx.GetStub().GetTxID(),
+		CarId:          carId,
+		StationId:      stationId,
+		Passed:         passed,
+		Mileage:        car.Mileage,
+		MalfunctionIds: []string{},
+		IssuedAt:       issuedAt,
+		ExpiresAt:      issuedAt.AddDate(0, 0, validDays),
+	}
+
+	for _, defect := range defects {
+		if passed && defect.Severity == SeverityCritical {
+			return nil, newError(CodeInvalidArgument, "car %s can not pass the inspection with critical defect %s", carId, defect.Description)
+		}
+
+		malfunction, err := newMalfunction(ctx, car, defect.Description, NewAmount(defect.Price, car.Price.Currency), defect.Severity)
+		if err != nil {
+			return nil, err
+		}
+
+		car.Malfunctions = append(car.Malfunctions, malfunction)
+		certificate.MalfunctionIds = append(certificate.MalfunctionIds, malfunction.Id)
+	}
+
+	// defects that cost more than the car get it scrapped, as malfunctions
+	// reported by a mechanic do
+	scrapped := false
+	var totalPrice Amount
+	if len(defects) > 0 {
+		tooExpensive, _, err := openMalfunctionsExceedPrice(car)
+		if err != nil {
+			return nil, err
+		}
+
+		if passed && tooExpensive {
+			return nil, newError(CodeInvalidArgument, "car %s can not pass the inspection with defects that cost more than the car", carId)
+		}
+
+		scrapped, totalPrice, err = s.putCarWithMalfunctions(ctx, car)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = putCertificate(ctx, &certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	if scrapped {
+		err = emitCarEvent(ctx, EventCarScrapped, carId, "", "", totalPrice)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &certificate, nil
+}
+
+// GetCertificate returns the inspection certificate of the car with given id
+func (s *SmartContract) GetCertificate(ctx contractapi.TransactionContextInterface, carId string, certificateId string) (*Certificate, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(certificateObjectType, []string{carId, certificateId})
+	if err != nil {
+		return nil, err
+	}
+
+	certificateAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if certificateAsBytes == nil {
+		return nil, newError(CodeNotFound, "certificate %s of car %s does not exist", certificateId, carId)
+	}
+
+	certificate := new(Certificate)
+	err = json.Unmarshal(certificateAsBytes, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+// GetCertificatesForCar returns all inspection certificates of the car,
+// passed or failed, expired or not
+func (s *SmartContract) GetCertificatesForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Certificate, error) {
+	return s.certificates(ctx, carId)
+}
+
+// GetCarsWithExpiringInspection returns the cars whose latest inspection
+// certificate passed and is still valid, but expires within the given number
+// of days. Scrapped cars are left out.
+func (s *SmartContract) GetCarsWithExpiringInspection(ctx contractapi.TransactionContextInterface, days int) ([]*Car, error) {
+	if days < 0 {
+		return nil, newError(CodeInvalidArgument, "number of days can not be negative")
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := now.AddDate(0, 0, days)
+
+	all, err := s.certificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]*Certificate)
+	carIds := make([]string, 0)
+	for _, certificate := range all {
+		previous, ok := latest[certificate.CarId]
+		if !ok {
+			carIds = append(carIds, certificate.CarId)
+		}
+		if !ok || certificate.IssuedAt.After(previous.IssuedAt) {
+			latest[certificate.CarId] = certificate
+		}
+	}
+
+	cars := make([]*Car, 0)
+	for _, carId := range carIds {
+		certificate := latest[carId]
+		if !certificate.validAt(now) || certificate.ExpiresAt.After(deadline) {
+			continue
+		}
+
+		car, err := s.GetCarById(ctx, carId)
+		if err != nil {
+			return nil, err
+		}
+
+		if carStatus(car) != CarScrapped {
+			cars = append(cars, car)
+		}
+	}
+
+	return cars, nil
+}
+
+// SetInspectionRequired tells whether cars can only change owner with a
+// passing inspection certificate that has not expired. It can only be called
+// by identities carrying the cars.admin attribute.
+func (s *SmartContract) SetInspectionRequired(ctx contractapi.TransactionContextInterface, required bool) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized to configure the contract, does not have %s role", adminAttribute)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{inspectionRequiredConfig})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, []byte(strconv.FormatBool(required)))
+}
+
+// checkInspection refuses to transfer cars without a valid inspection
+// certificate, if the contract is configured to require one
+func (s *SmartContract) checkInspection(ctx contractapi.TransactionContextInterface, carId string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{inspectionRequiredConfig})
+	if err != nil {
+		return err
+	}
+
+	required, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return errLedger("failed to read from world state: %v", err)
+	}
+
+	if string(required) != "true" {
+		return nil
+	}
+
+	certificates, err := s.certificates(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	var latest *Certificate
+	for _, certificate := range certificates {
+		if latest == nil || certificate.IssuedAt.After(latest.IssuedAt) {
+			latest = certificate
+		}
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest == nil || !latest.validAt(now) {
+		return newError(CodeInspectionRequired, "car %s does not have a valid inspection certificate", carId).with("carId", carId)
+	}
+
+	return nil
+}
+
+// certificates returns the certificates whose key starts with the given
+// attributes, all of them if none are given
+func (s *SmartContract) certificates(ctx contractapi.TransactionContextInterface, attributes ...string) ([]*Certificate, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(certificateObjectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	certificates := make([]*Certificate, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var certificate Certificate
+		err = json.Unmarshal(queryResponse.Value, &certificate)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, &certificate)
+	}
+
+	return certificates, nil
+}
+
+// validAt tells whether the certificate passed and has not expired at the
+// given time
+func (c *Certificate) validAt(now time.Time) bool {
+	return c.Passed && now.Before(c.ExpiresAt)
+}
+
+// assertInspector makes sure that the submitting client has the inspector
+// role
+func assertInspector(ctx contractapi.TransactionContextInterface) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(inspectorAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized, does not have %s role", inspectorAttribute)
+	}
+
+	return nil
+}
+
+// authorizeInspector makes sure that the submitting client is an inspector
+// of the organization the station belongs to
+func authorizeInspector(ctx contractapi.TransactionContextInterface, station *InspectionStation) error {
+	err := assertInspector(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mspID != station.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not an inspector of station %s", station.Id)
+	}
+
+	return nil
+}
+
+func putStation(ctx contractapi.TransactionContextInterface, station *InspectionStation) error {
+	station.DocType = stationObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(stationObjectType, []string{station.Id})
+	if err != nil {
+		return err
+	}
+
+	stationAsBytes, err := json.Marshal(station)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, stationAsBytes)
+}
+
+func putCertificate(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	certificate.DocType = certificateObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(certificateObjectType, []string{certificate.CarId, certificate.Id})
+	if err != nil {
+		return err
+	}
+
+	certificateAsBytes, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, certificateAsBytes)
+}
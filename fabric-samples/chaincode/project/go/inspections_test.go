@@ -0,0 +1,101 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspections(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	inspector := func() *testContext {
+		return ctx.as("x509::CN=inspector", "InspectorMSP", map[string]string{inspectorAttribute: "true"})
+	}
+
+	_, err := s.RegisterInspectionStation(ctx.asMechanic("Org2MSP"), "station1", "Tehnicki Pregled")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.inspector role")
+
+	_, err = s.RegisterInspectionStation(inspector(), "station1", "Tehnicki Pregled")
+	require.NoError(t, err)
+
+	err = s.SetInspectionRequired(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}), true)
+	require.NoError(t, err)
+
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER1")
+	require.NoError(t, err)
+
+	err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+	requireError(t, err, CodeInspectionRequired, "car 4 does not have a valid inspection certificate")
+
+	_, err = s.IssueCertificate(inspector(), "station1", "4", true, []Defect{{Description: "Worn brakes", Price: 30000, Severity: SeverityCritical}}, 365)
+	requireError(t, err, CodeInvalidArgument, "car 4 can not pass the inspection with critical defect Worn brakes")
+
+	// a failing certificate records its defects as malfunctions
+	certificate, err := s.IssueCertificate(inspector(), "station1", "4", false, []Defect{{Description: "Broken headlight", Price: 5000, Severity: SeverityMedium}}, 365)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, certificate.MalfunctionIds)
+
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Len(t, car.Malfunctions, 1)
+	require.Equal(t, "Broken headlight", car.Malfunctions[0].Description)
+
+	err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", true)
+	requireError(t, err, CodeInspectionRequired, "car 4 does not have a valid inspection certificate")
+
+	ctx.stub.MockTransactionStart("inspection2")
+	_, err = s.IssueCertificate(inspector(), "station1", "4", true, nil, 20)
+	require.NoError(t, err)
+
+	expiring, err := s.GetCarsWithExpiringInspection(ctx, 30)
+	require.NoError(t, err)
+	require.Len(t, expiring, 1)
+	require.Equal(t, 4, expiring[0].Id)
+
+	expiring, err = s.GetCarsWithExpiringInspection(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, expiring)
+
+	ctx.isolated(t, func() {
+		err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", true)
+		require.NoError(t, err)
+	})
+}
+
+func TestInspectionDefectsScrapCar(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	inspector := func() *testContext {
+		return ctx.as("x509::CN=inspector", "InspectorMSP", map[string]string{inspectorAttribute: "true"})
+	}
+
+	_, err := s.RegisterInspectionStation(inspector(), "station1", "Tehnicki Pregled")
+	require.NoError(t, err)
+
+	// the defects of car 6 cost more than its price of 2000.00
+	defects := []Defect{
+		{Description: "Rusted frame", Price: 150000, Severity: SeverityHigh},
+		{Description: "Leaking gearbox", Price: 60000, Severity: SeverityMedium},
+	}
+
+	_, err = s.IssueCertificate(inspector(), "station1", "6", true, defects, 365)
+	requireError(t, err, CodeInvalidArgument, "car 6 can not pass the inspection with defects that cost more than the car")
+
+	certificate, err := s.IssueCertificate(inspector(), "station1", "6", false, defects, 365)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2"}, certificate.MalfunctionIds)
+
+	name, event := ctx.lastEvent(t)
+	require.Equal(t, EventCarScrapped, name)
+	require.Equal(t, NewAmount(210000, "EUR"), event.Amount)
+
+	car, err := s.GetCarById(ctx, "6")
+	require.NoError(t, err)
+	require.Equal(t, CarScrapped, car.Status)
+	require.Len(t, car.Malfunctions, 2)
+}
@@ -0,0 +1,754 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	insurerObjectType = "insurer"
+	policyObjectType  = "policy"
+	claimObjectType   = "claim"
+
+	// insurerAttribute marks identities that may issue policies and decide
+	// on claims for their organization
+	insurerAttribute = "cars.insurer"
+)
+
+// Policy statuses
+const (
+	PolicyProposed = "PROPOSED"
+	PolicyActive   = "ACTIVE"
+)
+
+// Claim statuses
+const (
+	ClaimFiled    = "FILED"
+	ClaimApproved = "APPROVED"
+	ClaimDenied   = "DENIED"
+	ClaimPaid     = "PAID"
+)
+
+// Insurer belongs to an insurer organization. It collects the premiums of its
+// policies and pays the approved claims.
+type Insurer struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// Policy insures a car of its owner up to the coverage limit. Every claim
+// is reduced by the deductible, and Covered is the part of the coverage limit
+// already promised to approved claims.
+type Policy struct {
+	DocType       string    `json:"docType"`
+	Id            string    `json:"id"`
+	CarId         string    `json:"carId"`
+	InsurerId     string    `json:"insurerId"`
+	OwnerId       string    `json:"ownerId"`
+	CoverageLimit Amount    `json:"coverageLimit"`
+	Deductible    Amount    `json:"deductible"`
+	Premium       Amount    `json:"premium"`
+	Covered       Amount    `json:"covered"`
+	ValidFrom     time.Time `json:"validFrom"`
+	ValidUntil    time.Time `json:"validUntil"`
+	Status        string    `json:"status"`
+}
+
+// Claim asks the insurer of a policy to pay for the repair of a malfunction
+type Claim struct {
+	DocType       string `json:"docType"`
+	Id            string `json:"id"`
+	CarId         string `json:"carId"`
+	PolicyId      string `json:"policyId"`
+	MalfunctionId string `json:"malfunctionId"`
+	Payout        Amount `json:"payout"`
+	Status        string `json:"status"`
+}
+
+// RegisterInsurer adds an insurer of the submitting client's organization
+// with its starting capital in cents of the given currency. Only insurers can
+// register insurers, and only those that also have the cars.admin role may
+// give them a capital other than zero.
+func (s *SmartContract) RegisterInsurer(ctx contractapi.TransactionContextInterface, insurerId string, name string, capital int64, currency string) (*Insurer, error) {
+	err := assertInsurer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if insurerId == "" {
+		return nil, newError(CodeInvalidArgument, "insurer id must not be empty")
+	}
+
+	if capital < 0 {
+		return nil, newError(CodeInvalidArgument, "capital of insurer can not be negative")
+	}
+
+	if capital > 0 {
+		err = ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+		if err != nil {
+			return nil, newError(CodeUnauthorized, "submitting client not authorized to give an insurer starting capital, does not have %s role", adminAttribute)
+		}
+	}
+
+	if currency == "" {
+		return nil, newError(CodeInvalidArgument, "currency of insurer's capital must not be empty")
+	}
+
+	_, err = s.GetInsurer(ctx, insurerId)
+	if err == nil {
+		return nil, newError(CodeAlreadyExists, "insurer %s already exists", insurerId)
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	insurer := Insurer{
+		Id:    insurerId,
+		Name:  name,
+		MSPID: mspID,
+		Money: NewAmount(capital, currency),
+	}
+
+	err = putInsurer(ctx, &insurer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &insurer, nil
+}
+
+// GetInsurer returns the insurer with given id
+func (s *SmartContract) GetInsurer(ctx contractapi.TransactionContextInterface, insurerId string) (*Insurer, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(insurerObjectType, []string{insurerId})
+	if err != nil {
+		return nil, err
+	}
+
+	insurerAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if insurerAsBytes == nil {
+		return nil, newError(CodeNotFound, "insurer %s does not exist", insurerId)
+	}
+
+	insurer := new(Insurer)
+	err = json.Unmarshal(insurerAsBytes, insurer)
+	if err != nil {
+		return nil, err
+	}
+
+	return insurer, nil
+}
+
+// IssuePolicy proposes a policy for the car to its owner. The amounts are in
+// cents of the car's currency and the policy is valid for the given number of
+// days from the time of the transaction. It is called by an insurer of the
+// organization the insurer belongs to, and takes effect once the owner pays
+// the premium with AcceptPolicy.
+func (s *SmartContract) IssuePolicy(ctx contractapi.TransactionContextInterface, insurerId string, carId string, coverageLimit int64, deductible int64, premium int64, validDays int) (*Policy, error) {
+	insurer, err := s.GetInsurer(ctx, insurerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeInsurer(ctx, insurer)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	if coverageLimit <= 0 || premium <= 0 {
+		return nil, newError(CodeInvalidArgument, "coverage limit and premium of policy must be positive")
+	}
+
+	if deductible < 0 {
+		return nil, newError(CodeInvalidArgument, "deductible of policy can not be negative")
+	}
+
+	if validDays <= 0 {
+		return nil, newError(CodeInvalidArgument, "policy must be valid for at least one day")
+	}
+
+	validFrom, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := car.Price.Currency
+	policy := Policy{
+		Id:            ctx.GetStub().GetTxID(),
+		CarId:         carId,
+		InsurerId:     insurerId,
+		OwnerId:       car.Owner,
+		CoverageLimit: NewAmount(coverageLimit, currency),
+		Deductible:    NewAmount(deductible, currency),
+		Premium:       NewAmount(premium, currency),
+		Covered:       NewAmount(0, currency),
+		ValidFrom:     validFrom,
+		ValidUntil:    validFrom.AddDate(0, 0, validDays),
+		Status:        PolicyProposed,
+	}
+
+	err = putPolicy(ctx, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// AcceptPolicy lets the owner of the car pay the premium of a proposed policy
+// to the insurer, which puts the policy into effect
+func (s *SmartContract) AcceptPolicy(ctx contractapi.TransactionContextInterface, carId string, policyId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
+	}
+
+	policy, err := s.GetPolicy(ctx, carId, policyId)
+	if err != nil {
+		return err
+	}
+
+	if policy.Status != PolicyProposed {
+		return newError(CodeInvalidState, "policy %s is already %s", policyId, policy.Status)
+	}
+
+	if policy.OwnerId != car.Owner {
+		return newError(CodeInvalidState, "policy %s was proposed to a previous owner of car %s", policyId, carId)
+	}
+
+	owner, err := s.getOwner(ctx, ownerKey(car.Owner))
+	if err != nil {
+		return err
+	}
+
+	insurer, err := s.GetInsurer(ctx, policy.InsurerId)
+	if err != nil {
+		return err
+	}
+
+	insufficient, err := owner.Money.LessThan(policy.Premium)
+	if err != nil {
+		return err
+	}
+
+	if insufficient {
+		return newError(CodeInsufficientFunds, "owner does not have enough money to pay the premium of policy %s", policyId)
+	}
+
+	owner.Money, err = owner.Money.Sub(policy.Premium)
+	if err != nil {
+		return err
+	}
+
+	insurer.Money, err = insurer.Money.Add(policy.Premium)
+	if err != nil {
+		return err
+	}
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	err = putInsurer(ctx, insurer)
+	if err != nil {
+		return err
+	}
+
+	policy.Status = PolicyActive
+	return putPolicy(ctx, policy)
+}
+
+// GetPolicy returns the policy of the car with given id
+func (s *SmartContract) GetPolicy(ctx contractapi.TransactionContextInterface, carId string, policyId string) (*Policy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{carId, policyId})
+	if err != nil {
+		return nil, err
+	}
+
+	policyAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if policyAsBytes == nil {
+		return nil, newError(CodeNotFound, "policy %s for car %s does not exist", policyId, carId)
+	}
+
+	policy := new(Policy)
+	err = json.Unmarshal(policyAsBytes, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// GetPoliciesForCar returns all policies, proposed, active or expired, of the car
+func (s *SmartContract) GetPoliciesForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Policy, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(policyObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	policies := make([]*Policy, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var policy Policy
+		err = json.Unmarshal(queryResponse.Value, &policy)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, &policy)
+	}
+
+	return policies, nil
+}
+
+// FileClaim lets the owner of the car claim the repair of an unrepaired
+// malfunction from an active policy that is valid at the time of the
+// transaction
+func (s *SmartContract) FileClaim(ctx contractapi.TransactionContextInterface, carId string, policyId string, malfunctionId string) (*Claim, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.GetPolicy(ctx, carId, policyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.Status != PolicyActive {
+		return nil, newError(CodeInvalidState, "policy %s is %s, not %s", policyId, policy.Status, PolicyActive)
+	}
+
+	if policy.OwnerId != car.Owner {
+		return nil, newError(CodeInvalidState, "policy %s insures a previous owner of car %s", policyId, carId)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Before(policy.ValidFrom) || !now.Before(policy.ValidUntil) {
+		return nil, newError(CodeInvalidState, "policy %s is not valid at %s", policyId, now.Format(time.RFC3339))
+	}
+
+	malfunction, err := findMalfunction(car, malfunctionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if malfunction.Status == MalfunctionRepaired {
+		return nil, newError(CodeInvalidState, "malfunction %s of car %s is already repaired", malfunctionId, carId)
+	}
+
+	claims, err := s.GetClaimsForCar(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, claim := range claims {
+		if claim.MalfunctionId == malfunctionId && claim.Status != ClaimDenied {
+			return nil, newError(CodeInvalidState, "malfunction %s of car %s is already claimed with claim %s", malfunctionId, carId, claim.Id)
+		}
+	}
+
+	claim := Claim{
+		Id:            ctx.GetStub().GetTxID(),
+		CarId:         carId,
+		PolicyId:      policyId,
+		MalfunctionId: malfunctionId,
+		Payout:        NewAmount(0, policy.CoverageLimit.Currency),
+		Status:        ClaimFiled,
+	}
+
+	err = putClaim(ctx, &claim)
+	if err != nil {
+		return nil, err
+	}
+
+	return &claim, nil
+}
+
+// ApproveClaim lets the insurer of the policy accept a filed claim. The payout
+// is the price of the malfunction less the deductible, up to what is left of
+// the coverage limit, and is paid to the repair shop when the malfunction is
+// repaired.
+func (s *SmartContract) ApproveClaim(ctx contractapi.TransactionContextInterface, carId string, claimId string) (*Claim, error) {
+	claim, policy, err := s.decideClaim(ctx, carId, claimId)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	malfunction, err := findMalfunction(car, claim.MalfunctionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if malfunction.Status == MalfunctionRepaired {
+		return nil, newError(CodeInvalidState, "malfunction %s of car %s is already repaired and paid by the owner", claim.MalfunctionId, carId)
+	}
+
+	payout, err := malfunction.Price.Sub(policy.Deductible)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := policy.CoverageLimit.Sub(policy.Covered)
+	if err != nil {
+		return nil, err
+	}
+
+	exceeds, err := remaining.LessThan(payout)
+	if err != nil {
+		return nil, err
+	}
+
+	if exceeds {
+		payout = remaining
+	}
+
+	if !payout.IsPositive() {
+		return nil, newError(CodeInvalidState, "claim %s is not above the deductible or the coverage of policy %s is used up", claimId, policy.Id)
+	}
+
+	policy.Covered, err = policy.Covered.Add(payout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	claim.Payout = payout
+	claim.Status = ClaimApproved
+
+	err = putClaim(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
+
+	return claim, nil
+}
+
+// DenyClaim lets the insurer of the policy turn down a filed claim
+func (s *SmartContract) DenyClaim(ctx contractapi.TransactionContextInterface, carId string, claimId string) error {
+	claim, _, err := s.decideClaim(ctx, carId, claimId)
+	if err != nil {
+		return err
+	}
+
+	claim.Status = ClaimDenied
+	return putClaim(ctx, claim)
+}
+
+// GetClaimsForCar returns all claims, in any status, filed for the car
+func (s *SmartContract) GetClaimsForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Claim, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(claimObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	claims := make([]*Claim, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var claim Claim
+		err = json.Unmarshal(queryResponse.Value, &claim)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, &claim)
+	}
+
+	return claims, nil
+}
+
+// decideClaim returns a filed claim and its policy, after making sure that
+// the submitting client is an insurer of the policy
+func (s *SmartContract) decideClaim(ctx contractapi.TransactionContextInterface, carId string, claimId string) (*Claim, *Policy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(claimObjectType, []string{carId, claimId})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claimAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if claimAsBytes == nil {
+		return nil, nil, newError(CodeNotFound, "claim %s for car %s does not exist", claimId, carId)
+	}
+
+	claim := new(Claim)
+	err = json.Unmarshal(claimAsBytes, claim)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy, err := s.GetPolicy(ctx, carId, claim.PolicyId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	insurer, err := s.GetInsurer(ctx, policy.InsurerId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = authorizeInsurer(ctx, insurer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claim.Status != ClaimFiled {
+		return nil, nil, newError(CodeInvalidState, "claim %s is already %s", claimId, claim.Status)
+	}
+
+	return claim, policy, nil
+}
+
+// approvedClaims returns the approved claims of the car by malfunction id
+func (s *SmartContract) approvedClaims(ctx contractapi.TransactionContextInterface, carId string) (map[string]*Claim, error) {
+	claims, err := s.GetClaimsForCar(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	approved := make(map[string]*Claim)
+	for _, claim := range claims {
+		if claim.Status == ClaimApproved {
+			approved[claim.MalfunctionId] = claim
+		}
+	}
+
+	return approved, nil
+}
+
+// splitRepairCost returns the part of the price of repairing the malfunctions
+// that the owner pays, and the approved claims that pay for the rest
+func (s *SmartContract) splitRepairCost(ctx contractapi.TransactionContextInterface, car *Car, malfunctions []Malfunction) (Amount, []*Claim, error) {
+	approved, err := s.approvedClaims(ctx, strconv.Itoa(car.Id))
+	if err != nil {
+		return Amount{}, nil, err
+	}
+
+	ownerShare := NewAmount(0, car.Price.Currency)
+	var claims []*Claim
+	for _, malfunction := range malfunctions {
+		price := malfunction.Price
+		if claim, ok := approved[malfunction.Id]; ok {
+			price, err = price.Sub(claim.Payout)
+			if err != nil {
+				return Amount{}, nil, err
+			}
+			claims = append(claims, claim)
+		}
+
+		ownerShare, err = ownerShare.Add(price)
+		if err != nil {
+			return Amount{}, nil, err
+		}
+	}
+
+	return ownerShare, claims, nil
+}
+
+// payClaims moves the payouts of the claims from their insurers to the repair
+// shop and marks the claims as paid. A transaction does not read its own
+// writes, so each insurer is read once, debited for all of its claims and
+// written once.
+func (s *SmartContract) payClaims(ctx contractapi.TransactionContextInterface, claims []*Claim, shop *RepairShop) error {
+	var insurers []*Insurer
+	insurersById := make(map[string]*Insurer)
+	for _, claim := range claims {
+		policy, err := s.GetPolicy(ctx, claim.CarId, claim.PolicyId)
+		if err != nil {
+			return err
+		}
+
+		insurer, ok := insurersById[policy.InsurerId]
+		if !ok {
+			insurer, err = s.GetInsurer(ctx, policy.InsurerId)
+			if err != nil {
+				return err
+			}
+			insurersById[insurer.Id] = insurer
+			insurers = append(insurers, insurer)
+		}
+
+		insufficient, err := insurer.Money.LessThan(claim.Payout)
+		if err != nil {
+			return err
+		}
+
+		if insufficient {
+			return newError(CodeInsufficientFunds, "insurer %s does not have enough money to pay claim %s", insurer.Id, claim.Id)
+		}
+
+		insurer.Money, err = insurer.Money.Sub(claim.Payout)
+		if err != nil {
+			return err
+		}
+
+		shop.Money, err = shop.Money.Add(claim.Payout)
+		if err != nil {
+			return err
+		}
+
+		claim.Status = ClaimPaid
+		err = putClaim(ctx, claim)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, insurer := range insurers {
+		err := putInsurer(ctx, insurer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return putShop(ctx, shop)
+}
+
+// assertInsurer makes sure that the submitting client has the insurer role
+func assertInsurer(ctx contractapi.TransactionContextInterface) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(insurerAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized, does not have %s role", insurerAttribute)
+	}
+
+	return nil
+}
+
+// authorizeInsurer makes sure that the submitting client is an insurer of
+// the organization the insurer belongs to
+func authorizeInsurer(ctx contractapi.TransactionContextInterface, insurer *Insurer) error {
+	err := assertInsurer(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mspID != insurer.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not an insurer of %s", insurer.Id)
+	}
+
+	return nil
+}
+
+func putInsurer(ctx contractapi.TransactionContextInterface, insurer *Insurer) error {
+	insurer.DocType = insurerObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(insurerObjectType, []string{insurer.Id})
+	if err != nil {
+		return err
+	}
+
+	insurerAsBytes, err := json.Marshal(insurer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, insurerAsBytes)
+}
+
+func putPolicy(ctx contractapi.TransactionContextInterface, policy *Policy) error {
+	policy.DocType = policyObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{policy.CarId, policy.Id})
+	if err != nil {
+		return err
+	}
+
+	policyAsBytes, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, policyAsBytes)
+}
+
+func putClaim(ctx contractapi.TransactionContextInterface, claim *Claim) error {
+	claim.DocType = claimObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(claimObjectType, []string{claim.CarId, claim.Id})
+	if err != nil {
+		return err
+	}
+
+	claimAsBytes, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, claimAsBytes)
+}
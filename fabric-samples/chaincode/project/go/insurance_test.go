@@ -0,0 +1,135 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsuranceClaims(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	insurer := map[string]string{insurerAttribute: "true"}
+
+	_, err := s.RegisterInsurer(ctx, "ins1", "Osiguranje", 1000000, "EUR")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.insurer role")
+
+	_, err = s.RegisterInsurer(ctx.as("x509::CN=insurer", "Org3MSP", insurer), "ins1", "Osiguranje", 1000000, "EUR")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to give an insurer starting capital, does not have cars.admin role")
+
+	_, err = s.RegisterInsurer(ctx.as("x509::CN=insurer", "Org3MSP", map[string]string{insurerAttribute: "true", adminAttribute: "true"}), "ins1", "Osiguranje", 1000000, "EUR")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("policy1")
+	policy, err := s.IssuePolicy(ctx, "ins1", "1", 50000, 5000, 10000, 365)
+	require.NoError(t, err)
+	require.Equal(t, PolicyProposed, policy.Status)
+
+	_, err = s.FileClaim(ctx.asOwner(1), "1", policy.Id, "1")
+	requireError(t, err, CodeInvalidState, "policy policy1 is PROPOSED, not ACTIVE")
+
+	err = s.AcceptPolicy(ctx, "1", policy.Id)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("claim1")
+	claim, err := s.FileClaim(ctx, "1", policy.Id, "1")
+	require.NoError(t, err)
+
+	_, err = s.FileClaim(ctx, "1", policy.Id, "1")
+	requireError(t, err, CodeInvalidState, "malfunction 1 of car 1 is already claimed with claim claim1")
+
+	_, err = s.ApproveClaim(ctx, "1", claim.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.insurer role")
+
+	// the brake costs 200.00, less the deductible of 50.00
+	claim, err = s.ApproveClaim(ctx.as("x509::CN=insurer", "Org3MSP", insurer), "1", claim.Id)
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(15000, "EUR"), claim.Payout)
+
+	ctx.stub.MockTransactionStart("malfunction2")
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "1", "Broken mirror", 10000, SeverityLow)
+	require.NoError(t, err)
+
+	malfunctions, err := s.GetMalfunctions(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, malfunctions, 2)
+
+	ctx.stub.MockTransactionStart("claim2")
+	claim, err = s.FileClaim(ctx.asOwner(1), "1", policy.Id, malfunctions[1].Id)
+	require.NoError(t, err)
+
+	claim, err = s.ApproveClaim(ctx.as("x509::CN=insurer", "Org3MSP", insurer), "1", claim.Id)
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(5000, "EUR"), claim.Payout)
+
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx.asOwner(1), "1", "shop1")
+	require.NoError(t, err)
+
+	// both claims are paid by the insurer in a single repair
+	ctx.isolated(t, func() {
+		err = s.RepairCar(ctx.asMechanic("Org2MSP"), "1", "shop1")
+		require.NoError(t, err)
+	})
+
+	// the owner paid the premium and the deductibles, the insurer the rest
+	owner, err := s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(980000, "EUR"), owner.Money)
+
+	company, err := s.GetInsurer(ctx, "ins1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(990000, "EUR"), company.Money)
+
+	shop, err := s.GetRepairShop(ctx, "shop1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(30000, "EUR"), shop.Money)
+
+	claims, err := s.GetClaimsForCar(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, claims, 2)
+	for _, claim := range claims {
+		require.Equal(t, ClaimPaid, claim.Status)
+	}
+}
+
+func TestStolenCarCanNotAcceptPolicy(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	insurer := func() *testContext {
+		return ctx.as("x509::CN=insurer", "Org3MSP", map[string]string{insurerAttribute: "true"})
+	}
+	police := func() *testContext { return ctx.as("x509::CN=officer", "PoliceMSP", nil) }
+
+	_, err := s.RegisterInsurer(insurer(), "ins1", "Osiguranje", 0, "EUR")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("policy1")
+	policy, err := s.IssuePolicy(insurer(), "ins1", "4", 50000, 5000, 10000, 365)
+	require.NoError(t, err)
+
+	err = s.SetLawEnforcementOrg(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}), "PoliceMSP")
+	require.NoError(t, err)
+
+	_, err = s.ReportStolen(police(), "4", "case-17")
+	require.NoError(t, err)
+
+	err = s.AcceptPolicy(ctx.asOwner(2), "4", policy.Id)
+	requireError(t, err, CodeInvalidState, "car 4 is reported stolen and can not be changed until it is recovered")
+
+	_, err = s.ReportRecovered(police(), "4", "case-17")
+	require.NoError(t, err)
+
+	err = s.AcceptPolicy(ctx.asOwner(2), "4", policy.Id)
+	require.NoError(t, err)
+
+	owner, err := s.GetOwnerById(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(490000, "EUR"), owner.Money)
+}
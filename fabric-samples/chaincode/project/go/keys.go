@@ -0,0 +1,158 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Document types stored in the docType field of every record, so that a
+// record can be told apart without knowing the key it was read from
+const (
+	carDocType   = "car"
+	ownerDocType = "owner"
+)
+
+const carKeyPrefix = "CAR"
+
+// carKey returns the world state key of the car with the given numeric id
+func carKey(id string) string {
+	return carKeyPrefix + id
+}
+
+// MigrateKeys moves cars stored under their bare numeric id to the CAR key
+// namespace and adds the docType field to records written before it existed.
+// Cars written without an id get the id of their key. It returns
+// the number of rewritten records and can only be called by identities
+// carrying the cars.admin attribute.
+func (s *SmartContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return 0, newError(CodeUnauthorized, "submitting client not authorized to migrate keys, does not have %s role", adminAttribute)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		key := queryResponse.Key
+		_, numericErr := strconv.Atoi(key)
+
+		changed := false
+		if numericErr == nil {
+			changed, err = migrateLegacyCar(ctx, key, queryResponse.Value)
+		} else if strings.HasPrefix(key, ownerKeyPrefix) {
+			changed, err = migrateDocType(ctx, key, queryResponse.Value, ownerDocType)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			migrated++
+		}
+	}
+
+	for _, objectType := range []string{listingObjectType, offerObjectType, shopObjectType} {
+		count, err := migrateObjectDocTypes(ctx, objectType)
+		if err != nil {
+			return 0, err
+		}
+		migrated += count
+	}
+
+	return migrated, nil
+}
+
+// migrateLegacyCar rewrites the car stored under a bare numeric id to its
+// CAR key and removes the old record
+func migrateLegacyCar(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	var record map[string]json.RawMessage
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return false, newError(CodeInternal, "failed to migrate car %s: %v", key, err)
+	}
+
+	var id int
+	if json.Unmarshal(record["id"], &id) != nil || id == 0 {
+		record["id"] = json.RawMessage(key)
+	}
+	record["docType"], _ = json.Marshal(carDocType)
+
+	carAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+
+	err = ctx.GetStub().PutState(carKey(key), carAsBytes)
+	if err != nil {
+		return false, errLedger("failed to put car to world state. %v", err)
+	}
+
+	return true, ctx.GetStub().DelState(key)
+}
+
+// migrateObjectDocTypes adds the docType field to every record stored under
+// the composite key object type
+func migrateObjectDocTypes(ctx contractapi.TransactionContextInterface, objectType string) (int, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer resultIter.Close()
+
+	migrated := 0
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		changed, err := migrateDocType(ctx, queryResponse.Key, queryResponse.Value, objectType)
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateDocType stores the record back with the docType field when it is
+// missing or empty
+func migrateDocType(ctx contractapi.TransactionContextInterface, key string, value []byte, docType string) (bool, error) {
+	var record map[string]json.RawMessage
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return false, newError(CodeInternal, "failed to migrate %s: %v", key, err)
+	}
+
+	var current string
+	if json.Unmarshal(record["docType"], &current) == nil && current != "" {
+		return false, nil
+	}
+	record["docType"], _ = json.Marshal(docType)
+
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+
+	return true, ctx.GetStub().PutState(key, recordAsBytes)
+}
@@ -0,0 +1,63 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	cars, err := s.GetAllCars(ctx)
+	require.NoError(t, err)
+	require.Len(t, cars, 6)
+	for _, car := range cars {
+		require.Equal(t, carDocType, car.DocType)
+	}
+
+	ctx.stub.MockTransactionStart("legacy")
+	require.NoError(t, ctx.stub.PutState("7", []byte(`{"make":"Fiat","model":"Punto","color":"white","owner":"1","malfunctions":[]}`)))
+	require.NoError(t, ctx.stub.PutState("OWNER9", []byte(`{"id":9,"name":"Ana"}`)))
+
+	_, err = s.MigrateKeys(ctx)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to migrate keys, does not have cars.admin role")
+
+	ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+	migrated, err := s.MigrateKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, migrated)
+
+	exists, err := s.CarExists(ctx, "7")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	legacy, err := ctx.stub.GetState("7")
+	require.NoError(t, err)
+	require.Nil(t, legacy)
+
+	car, err := s.GetCarById(ctx, "7")
+	require.NoError(t, err)
+	require.Equal(t, 7, car.Id)
+	require.Equal(t, carDocType, car.DocType)
+
+	_, err = s.MigrateOwnerPrivateData(ctx.withSalt())
+	require.NoError(t, err)
+
+	owner, err := s.GetOwnerById(ctx, "OWNER9")
+	require.NoError(t, err)
+	require.Equal(t, ownerDocType, owner.DocType)
+
+	cars, err = s.GetAllCars(ctx)
+	require.NoError(t, err)
+	require.Len(t, cars, 7)
+
+	migrated, err = s.MigrateKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
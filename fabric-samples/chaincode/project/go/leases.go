@@ -0,0 +1,584 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	leaseObjectType = "lease"
+
+	// leaseDateLayout is the layout of the start and end dates of leases
+	leaseDateLayout = "2006-01-02"
+)
+
+// Lease statuses. A lease that is ACTIVE past its end date has expired, which
+// GetLease reports as EXPIRED without a transaction having to close it.
+const (
+	LeaseProposed   = "PROPOSED"
+	LeaseActive     = "ACTIVE"
+	LeaseReturned   = "RETURNED"
+	LeaseExpired    = "EXPIRED"
+	LeaseTerminated = "TERMINATED"
+)
+
+// Lease rents a car out to a lessee from the start date to the end date, for
+// a rate per day paid to the owner of the car. Once the lease is accepted the
+// owner can no longer sell the car, and while it is in force, from its start
+// date, the lessee has custody of the car and can report its malfunctions and
+// the owner can not recolor it. The lessee pays the rent of the whole term
+// into Escrow when accepting the lease, and is Charged only for the days they
+// had the car once the lease ends. The charge is paid to the co-owners by
+// the Shares they held when the lease was accepted, the rest is refunded.
+type Lease struct {
+	DocType    string       `json:"docType"`
+	Id         string       `json:"id"`
+	CarId      string       `json:"carId"`
+	Lessee     string       `json:"lessee"`
+	Lessor     string       `json:"lessor"`
+	StartDate  time.Time    `json:"startDate"`
+	EndDate    time.Time    `json:"endDate"`
+	DailyRate  Amount       `json:"dailyRate"`
+	Rent       Amount       `json:"rent"`
+	Status     string       `json:"status"`
+	ReturnedAt time.Time    `json:"returnedAt"`
+	Shares     []OwnerShare `json:"shares,omitempty"`
+	Escrow     Amount       `json:"escrow"`
+	Charged    Amount       `json:"charged"`
+}
+
+// OfferLease proposes to lease the car to the lessee, given by key such as
+// OWNER1, from startDate to endDate, both formatted as 2006-01-02, for the
+// daily rate in cents of the car's currency. It is called by the owner of the
+// car, and the lease takes effect once the lessee pays the rent for the whole
+// term into escrow with AcceptLease.
+func (s *SmartContract) OfferLease(ctx contractapi.TransactionContextInterface, carId string, lesseeId string, startDate string, endDate string, dailyRate int64) (*Lease, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, CarActive)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	lessee, err := s.GetOwnerRecord(ctx, lesseeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerPercent(car, strconv.Itoa(lessee.Id)) > 0 {
+		return nil, newError(CodeInvalidState, "%s owns car %s and can not lease it", lesseeId, carId)
+	}
+
+	start, err := time.Parse(leaseDateLayout, startDate)
+	if err != nil {
+		return nil, newError(CodeInvalidArgument, "start date %s is not formatted as %s", startDate, leaseDateLayout)
+	}
+
+	end, err := time.Parse(leaseDateLayout, endDate)
+	if err != nil {
+		return nil, newError(CodeInvalidArgument, "end date %s is not formatted as %s", endDate, leaseDateLayout)
+	}
+
+	if !end.After(start) {
+		return nil, newError(CodeInvalidArgument, "lease must end after it starts")
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !end.After(now) {
+		return nil, newError(CodeInvalidArgument, "lease can not end in the past")
+	}
+
+	if dailyRate <= 0 {
+		return nil, newError(CodeInvalidArgument, "daily rate of lease must be positive")
+	}
+
+	days := int64(end.Sub(start).Hours() / 24)
+	if dailyRate > math.MaxInt64/days {
+		return nil, newError(CodeInvalidAmount, "rent of %d days at daily rate %d overflows", days, dailyRate)
+	}
+
+	currency := car.Price.Currency
+	lease := Lease{
+		Id:        ctx.GetStub().GetTxID(),
+		CarId:     carId,
+		Lessee:    strconv.Itoa(lessee.Id),
+		Lessor:    car.Owner,
+		StartDate: start,
+		EndDate:   end,
+		DailyRate: NewAmount(dailyRate, currency),
+		Rent:      NewAmount(dailyRate*days, currency),
+		Status:    LeaseProposed,
+	}
+
+	err = putLease(ctx, &lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+// AcceptLease lets the lessee pay the rent of a proposed lease into escrow,
+// which puts the lease into force. When the lease ends the rent of the days
+// the lessee had the car is paid to the owner, or split among the co-owners
+// of a co-owned car by their shares, who collect it with CollectPayments.
+func (s *SmartContract) AcceptLease(ctx contractapi.TransactionContextInterface, carId string, leaseId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	lease, err := s.GetLease(ctx, carId, leaseId)
+	if err != nil {
+		return err
+	}
+
+	lessee, err := s.getOwner(ctx, ownerKey(lease.Lessee))
+	if err != nil {
+		return err
+	}
+
+	err = authorizeOwner(ctx, lessee)
+	if err != nil {
+		return err
+	}
+
+	if lease.Status != LeaseProposed {
+		return newError(CodeInvalidState, "lease %s is already %s", leaseId, lease.Status)
+	}
+
+	if lease.Lessor != car.Owner {
+		return newError(CodeInvalidState, "lease %s was proposed by a previous owner of car %s", leaseId, carId)
+	}
+
+	// the lessee may have become a co-owner since the lease was offered, and
+	// would then be paid part of their own rent
+	if ownerPercent(car, lease.Lessee) > 0 {
+		return newError(CodeInvalidState, "%s owns car %s and can not lease it", ownerKey(lease.Lessee), carId)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !now.Before(lease.EndDate) {
+		return newError(CodeInvalidState, "lease %s ended on %s", leaseId, lease.EndDate.Format(leaseDateLayout))
+	}
+
+	err = checkCarStatus(car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	insufficient, err := lessee.Money.LessThan(lease.Rent)
+	if err != nil {
+		return err
+	}
+
+	if insufficient {
+		return newError(CodeInsufficientFunds, "lessee does not have enough money to pay the rent %s of lease %s", lease.Rent, leaseId)
+	}
+
+	lessee.Money, err = lessee.Money.Sub(lease.Rent)
+	if err != nil {
+		return err
+	}
+
+	err = putOwner(ctx, lessee)
+	if err != nil {
+		return err
+	}
+
+	lease.Shares = carShares(car)
+	lease.Escrow = lease.Rent
+	lease.Status = LeaseActive
+	err = putLease(ctx, lease)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventCarLeased, carId, car.Owner, lease.Lessee, lease.Rent)
+}
+
+// ReturnCar lets the lessee hand the car back before the lease expires. The
+// lessee is charged for every started day since the lease started, and the
+// rent of the unused days is refunded to them.
+func (s *SmartContract) ReturnCar(ctx contractapi.TransactionContextInterface, carId string) (*Lease, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	lease, err := s.leaseInForce(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	if lease == nil {
+		return nil, newError(CodeNotFound, "car %s is not leased", carId)
+	}
+
+	err = s.authorizeLessee(ctx, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	lease.ReturnedAt, err = txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.settleLease(ctx, lease, lease.ReturnedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	lease.Status = LeaseReturned
+	err = putLease(ctx, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitCarEvent(ctx, EventCarReturned, carId, lease.Lessee, car.Owner, lease.Charged)
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// SettleLease pays the rent held in escrow for an expired lease to the
+// co-owners of the car. It is called by one of them.
+func (s *SmartContract) SettleLease(ctx contractapi.TransactionContextInterface, carId string, leaseId string) (*Lease, error) {
+	lease, err := s.GetLease(ctx, carId, leaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	if lease.Status != LeaseExpired {
+		return nil, newError(CodeInvalidState, "lease %s is %s, not %s", leaseId, lease.Status, LeaseExpired)
+	}
+
+	if !lease.Escrow.IsPositive() {
+		return nil, newError(CodeInvalidState, "lease %s is already settled", leaseId)
+	}
+
+	authorized := false
+	for _, share := range lease.Shares {
+		if _, err := s.submittingOwner(ctx, share.Owner); err == nil {
+			authorized = true
+			break
+		}
+	}
+
+	if !authorized {
+		return nil, newError(CodeUnauthorized, "submitting client not authorized to settle lease %s, did not own car %s", leaseId, carId)
+	}
+
+	err = s.settleLease(ctx, lease, lease.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putLease(ctx, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// GetLease returns the lease of the car with given id. An active lease past
+// its end date is returned as EXPIRED.
+func (s *SmartContract) GetLease(ctx contractapi.TransactionContextInterface, carId string, leaseId string) (*Lease, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(leaseObjectType, []string{carId, leaseId})
+	if err != nil {
+		return nil, err
+	}
+
+	leaseAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if leaseAsBytes == nil {
+		return nil, newError(CodeNotFound, "lease %s of car %s does not exist", leaseId, carId)
+	}
+
+	lease := new(Lease)
+	err = json.Unmarshal(leaseAsBytes, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	err = expireLease(ctx, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// GetLeasesForCar returns all leases of the car, in any status
+func (s *SmartContract) GetLeasesForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Lease, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	leases := make([]*Lease, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var lease Lease
+		err = json.Unmarshal(queryResponse.Value, &lease)
+		if err != nil {
+			return nil, err
+		}
+
+		err = expireLease(ctx, &lease)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, &lease)
+	}
+
+	return leases, nil
+}
+
+// activeLease returns the accepted lease of the car that has not expired
+// yet, whether or not its term has started, or nil if there is none
+func (s *SmartContract) activeLease(ctx contractapi.TransactionContextInterface, car *Car) (*Lease, error) {
+	leases, err := s.GetLeasesForCar(ctx, strconv.Itoa(car.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lease := range leases {
+		if lease.Status == LeaseActive {
+			return lease, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// leaseInForce returns the active lease of the car whose term has started,
+// or nil if the car is not in the custody of a lessee
+func (s *SmartContract) leaseInForce(ctx contractapi.TransactionContextInterface, car *Car) (*Lease, error) {
+	lease, err := s.activeLease(ctx, car)
+	if err != nil || lease == nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Before(lease.StartDate) {
+		return nil, nil
+	}
+
+	return lease, nil
+}
+
+// checkNotLeased refuses cars that are in the custody of a lessee
+func (s *SmartContract) checkNotLeased(ctx contractapi.TransactionContextInterface, car *Car) error {
+	lease, err := s.leaseInForce(ctx, car)
+	if err != nil || lease == nil {
+		return err
+	}
+
+	return errCarLeased(ctx, lease)
+}
+
+// checkNoActiveLease refuses cars that are leased, or booked by a lessee who
+// already paid the rent, so that they are not sold, split or leased twice
+func (s *SmartContract) checkNoActiveLease(ctx contractapi.TransactionContextInterface, car *Car) error {
+	lease, err := s.activeLease(ctx, car)
+	if err != nil || lease == nil {
+		return err
+	}
+
+	return errCarLeased(ctx, lease)
+}
+
+func errCarLeased(ctx contractapi.TransactionContextInterface, lease *Lease) error {
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	var carErr *CarError
+	if now.Before(lease.StartDate) {
+		carErr = newError(CodeCarLeased, "car %s is booked by %s from %s until %s", lease.CarId, ownerKey(lease.Lessee), lease.StartDate.Format(leaseDateLayout), lease.EndDate.Format(leaseDateLayout))
+	} else {
+		carErr = newError(CodeCarLeased, "car %s is leased to %s until %s", lease.CarId, ownerKey(lease.Lessee), lease.EndDate.Format(leaseDateLayout))
+	}
+
+	return carErr.with("carId", lease.CarId).with("leaseId", lease.Id).with("endDate", lease.EndDate.Format(leaseDateLayout))
+}
+
+// authorizeLessee makes sure that the submitting client is the lessee of the
+// lease
+func (s *SmartContract) authorizeLessee(ctx contractapi.TransactionContextInterface, lease *Lease) error {
+	_, err := s.submittingOwner(ctx, lease.Lessee)
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client is not the lessee of car %s", lease.CarId)
+	}
+
+	return nil
+}
+
+// terminateLease ends the active lease of a car that is taken off the road,
+// charging the lessee only for the days they had the car
+func (s *SmartContract) terminateLease(ctx contractapi.TransactionContextInterface, car *Car) error {
+	lease, err := s.activeLease(ctx, car)
+	if err != nil || lease == nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.settleLease(ctx, lease, now)
+	if err != nil {
+		return err
+	}
+
+	lease.Status = LeaseTerminated
+	return putLease(ctx, lease)
+}
+
+// settleLease releases the rent held in escrow for a lease that ends at the
+// given time. The co-owners are paid the daily rate for every started day the
+// lessee had the car, and the rest is refunded to the lessee. Leases accepted
+// before the rent was held in escrow were paid upfront and have nothing to
+// settle.
+func (s *SmartContract) settleLease(ctx contractapi.TransactionContextInterface, lease *Lease, endedAt time.Time) error {
+	if !lease.Escrow.IsPositive() {
+		return nil
+	}
+
+	charged := NewAmount(lease.DailyRate.Value*leaseDays(lease, endedAt), lease.Escrow.Currency)
+	exceeds, err := lease.Escrow.LessThan(charged)
+	if err != nil {
+		return err
+	}
+
+	if exceeds {
+		charged = lease.Escrow
+	}
+
+	refund, err := lease.Escrow.Sub(charged)
+	if err != nil {
+		return err
+	}
+
+	err = s.paySellers(ctx, lease.Shares, charged, PaymentRent)
+	if err != nil {
+		return err
+	}
+
+	err = s.payOwner(ctx, lease.Lessee, refund, PaymentRefund)
+	if err != nil {
+		return err
+	}
+
+	lease.Charged = charged
+	lease.Escrow = NewAmount(0, lease.Escrow.Currency)
+
+	return nil
+}
+
+// leaseDays returns the number of started days the lessee had the car when
+// the lease ends at the given time, which is at least one day once the lease
+// started and at most its whole term
+func leaseDays(lease *Lease, endedAt time.Time) int64 {
+	if endedAt.Before(lease.StartDate) {
+		return 0
+	}
+
+	if endedAt.After(lease.EndDate) {
+		endedAt = lease.EndDate
+	}
+
+	days := int64(math.Ceil(endedAt.Sub(lease.StartDate).Hours() / 24))
+	if days == 0 {
+		days = 1
+	}
+
+	return days
+}
+
+// expireLease marks an active lease as expired once the time of the
+// transaction reaches its end date
+func expireLease(ctx contractapi.TransactionContextInterface, lease *Lease) error {
+	if lease.Status != LeaseActive {
+		return nil
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !now.Before(lease.EndDate) {
+		lease.Status = LeaseExpired
+	}
+
+	return nil
+}
+
+func putLease(ctx contractapi.TransactionContextInterface, lease *Lease) error {
+	lease.DocType = leaseObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(leaseObjectType, []string{lease.CarId, lease.Id})
+	if err != nil {
+		return err
+	}
+
+	leaseAsBytes, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, leaseAsBytes)
+}
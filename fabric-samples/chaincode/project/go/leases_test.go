@@ -0,0 +1,260 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeases(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.Format(leaseDateLayout)
+	end := today.AddDate(0, 0, 3).Format(leaseDateLayout)
+
+	_, err := s.OfferLease(ctx.asOwner(1), "4", "OWNER1", start, end, 10000)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 4, does not own it")
+
+	_, err = s.OfferLease(ctx.asOwner(2), "4", "OWNER1", end, start, 10000)
+	requireError(t, err, CodeInvalidArgument, "lease must end after it starts")
+
+	lease, err := s.OfferLease(ctx.asOwner(2), "4", "OWNER1", start, end, 10000)
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(30000, "EUR"), lease.Rent)
+
+	err = s.AcceptLease(ctx.asOwner(3), "4", lease.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	ctx.isolated(t, func() {
+		err = s.AcceptLease(ctx.asOwner(1), "4", lease.Id)
+		require.NoError(t, err)
+	})
+
+	// the rent of the whole term is held in escrow until the lease ends
+	lessee, err := s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(970000, "EUR"), lessee.Money)
+
+	payments, err := s.GetPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Empty(t, payments)
+
+	// the owner can neither sell nor recolor the leased car
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "red")
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER1 until "+end)
+
+	err = s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER1 until "+end)
+
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER3")
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER1 until "+end)
+
+	// while the lessee can report malfunctions
+	err = s.AddMalfunction(ctx.asOwner(3), "4", "Flat tire", 5000, SeverityLow)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.mechanic role")
+
+	err = s.AddMalfunction(ctx.asOwner(1), "4", "Flat tire", 5000, SeverityLow)
+	require.NoError(t, err)
+
+	_, err = s.ReturnCar(ctx.asOwner(2), "4")
+	requireError(t, err, CodeUnauthorized, "submitting client is not the lessee of car 4")
+
+	// returned on its first day, the car is charged one day of the three
+	lease, err = s.ReturnCar(ctx.asOwner(1), "4")
+	require.NoError(t, err)
+	require.Equal(t, LeaseReturned, lease.Status)
+	require.Equal(t, NewAmount(10000, "EUR"), lease.Charged)
+	require.Equal(t, NewAmount(0, "EUR"), lease.Escrow)
+
+	owner, err := s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(510000, "EUR"), owner.Money)
+
+	lessee, err = s.CollectPayments(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(990000, "EUR"), lessee.Money)
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "red")
+	require.NoError(t, err)
+
+	// a lease expires with the first transaction past its end date
+	lease, err = s.OfferLease(ctx.asOwner(2), "4", "OWNER1", start, end, 10000)
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.AcceptLease(ctx.asOwner(1), "4", lease.Id)
+		require.NoError(t, err)
+	})
+
+	ctx.stub.TxTimestamp, err = ptypes.TimestampProto(today.AddDate(0, 0, 3))
+	require.NoError(t, err)
+
+	lease, err = s.GetLease(ctx, "4", lease.Id)
+	require.NoError(t, err)
+	require.Equal(t, LeaseExpired, lease.Status)
+
+	_, err = s.ReturnCar(ctx.asOwner(1), "4")
+	requireError(t, err, CodeNotFound, "car 4 is not leased")
+
+	// the owner settles the expired lease for its whole term
+	_, err = s.SettleLease(ctx.asOwner(1), "4", lease.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to settle lease "+lease.Id+", did not own car 4")
+
+	ctx.isolated(t, func() {
+		lease, err = s.SettleLease(ctx.asOwner(2), "4", lease.Id)
+		require.NoError(t, err)
+		require.Equal(t, NewAmount(30000, "EUR"), lease.Charged)
+	})
+
+	_, err = s.SettleLease(ctx.asOwner(2), "4", lease.Id)
+	requireError(t, err, CodeInvalidState, "lease "+lease.Id+" is already settled")
+
+	owner, err = s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(540000, "EUR"), owner.Money)
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "green")
+	require.NoError(t, err)
+
+	// a lease accepted for later leaves the car with its owner until it starts
+	ctx.stub.MockTransactionStart("lease2")
+	ctx.stub.TxTimestamp, err = ptypes.TimestampProto(today.AddDate(0, 0, 3))
+	require.NoError(t, err)
+
+	later := today.AddDate(0, 0, 10)
+	laterStart := later.Format(leaseDateLayout)
+	laterEnd := later.AddDate(0, 0, 2).Format(leaseDateLayout)
+	lease, err = s.OfferLease(ctx.asOwner(2), "4", "OWNER1", laterStart, laterEnd, 10000)
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.AcceptLease(ctx.asOwner(1), "4", lease.Id)
+		require.NoError(t, err)
+	})
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "white")
+	require.NoError(t, err)
+
+	err = s.ListCarForSale(ctx, "4", 650000)
+	requireError(t, err, CodeCarLeased, "car 4 is booked by OWNER1 from "+laterStart+" until "+laterEnd)
+
+	err = s.AddMalfunction(ctx.asOwner(1), "4", "Flat tire", 5000, SeverityLow)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.mechanic role")
+
+	ctx.stub.TxTimestamp, err = ptypes.TimestampProto(later)
+	require.NoError(t, err)
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "black")
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER1 until "+laterEnd)
+
+	// a lessee who became a co-owner after the offer can not accept it
+	ctx.stub.MockTransactionStart("lease3")
+	lease, err = s.OfferLease(ctx.asOwner(3), "5", "OWNER1", start, end, 10000)
+	require.NoError(t, err)
+
+	err = s.SetCarShares(ctx, "5", []OwnerShare{{Owner: "OWNER3", Percent: 60}, {Owner: "OWNER1", Percent: 40}}, 100)
+	require.NoError(t, err)
+
+	err = s.AcceptCarShares(ctx.asOwner(1), "5")
+	require.NoError(t, err)
+
+	err = s.AcceptLease(ctx.asOwner(1), "5", lease.Id)
+	requireError(t, err, CodeInvalidState, "OWNER1 owns car 5 and can not lease it")
+}
+
+func TestEarlyReturnRefundsUnusedDays(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.Format(leaseDateLayout)
+	end := today.AddDate(0, 0, 5).Format(leaseDateLayout)
+
+	ctx.stub.MockTransactionStart("lease1")
+	lease, err := s.OfferLease(ctx.asOwner(3), "6", "OWNER2", start, end, 10000)
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(50000, "EUR"), lease.Rent)
+
+	ctx.isolated(t, func() {
+		err = s.AcceptLease(ctx.asOwner(2), "6", lease.Id)
+		require.NoError(t, err)
+	})
+
+	// handed back an hour into the third day of five
+	ctx.stub.MockTransactionStart("return1")
+	ctx.stub.TxTimestamp, err = ptypes.TimestampProto(today.AddDate(0, 0, 2).Add(time.Hour))
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		lease, err = s.ReturnCar(ctx.asOwner(2), "6")
+		require.NoError(t, err)
+		require.Equal(t, NewAmount(30000, "EUR"), lease.Charged)
+	})
+
+	payments, err := s.GetPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, PaymentRefund, payments[0].Reason)
+	require.Equal(t, NewAmount(20000, "EUR"), payments[0].Amount)
+
+	lessee, err := s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(470000, "EUR"), lessee.Money)
+
+	owner, err := s.CollectPayments(ctx.asOwner(3), "OWNER3")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(530000, "EUR"), owner.Money)
+
+	lease, err = s.GetLease(ctx, "6", lease.Id)
+	require.NoError(t, err)
+	require.Equal(t, LeaseReturned, lease.Status)
+}
+
+func TestLesseeOfOtherOrganization(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.Format(leaseDateLayout)
+	end := today.AddDate(0, 0, 5).Format(leaseDateLayout)
+
+	_, err := s.CreateOwner(ctx.as("x509::CN=lessee", "Org2MSP", map[string]string{adminAttribute: "true"}).withOwnerDetails("Ana", "Anic", "ana@example.com", 100000), "")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("lease1")
+	lease, err := s.OfferLease(ctx.asOwner(3), "6", "OWNER4", start, end, 10000)
+	require.NoError(t, err)
+
+	err = ctx.endorsed(t, func() error {
+		return s.AcceptLease(ctx.as("x509::CN=lessee", "Org2MSP", nil), "6", lease.Id)
+	}, "Org2MSP")
+	require.NoError(t, err)
+
+	// the rent goes to the owner of Org1 and the refund to the lessee of Org2
+	// in payments, so that the peers of either organization endorse the return
+	ctx.stub.MockTransactionStart("return1")
+	ctx.stub.TxTimestamp, err = ptypes.TimestampProto(today.AddDate(0, 0, 2).Add(time.Hour))
+	require.NoError(t, err)
+
+	err = ctx.endorsed(t, func() error {
+		_, err := s.ReturnCar(ctx.as("x509::CN=lessee", "Org2MSP", nil), "6")
+		return err
+	}, "Org1MSP", "Org2MSP")
+	require.NoError(t, err)
+
+	refunded, err := s.CollectPayments(ctx.as("x509::CN=lessee", "Org2MSP", nil), "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(70000, "EUR"), refunded.Money)
+
+	owner, err := s.CollectPayments(ctx.asOwner(3), "OWNER3")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(530000, "EUR"), owner.Money)
+}
@@ -0,0 +1,574 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	lenderObjectType = "lender"
+	lienObjectType   = "lien"
+	lenderLienIndex  = "lender~car~lien"
+
+	// lenderAttribute marks identities that may register and release liens
+	// for their organization
+	lenderAttribute = "cars.lender"
+)
+
+// Lien statuses
+const (
+	LienProposed = "PROPOSED"
+	LienActive   = "ACTIVE"
+	LienReleased = "RELEASED"
+)
+
+// Lender belongs to a bank organization that finances cars
+type Lender struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// Lien secures the loan of a lender on a car. A lien is proposed by the
+// lender and takes effect once the owner of the car accepts it. A car with an
+// active lien can only be sold when the price pays off the outstanding
+// balance.
+type Lien struct {
+	DocType      string    `json:"docType"`
+	Id           string    `json:"id"`
+	CarId        string    `json:"carId"`
+	LenderId     string    `json:"lenderId"`
+	OwnerId      string    `json:"ownerId"`
+	Principal    Amount    `json:"principal"`
+	Outstanding  Amount    `json:"outstanding"`
+	Status       string    `json:"status"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// RegisterLender adds a lender of the submitting client's organization. Only
+// lenders can register lenders.
+func (s *SmartContract) RegisterLender(ctx contractapi.TransactionContextInterface, lenderId string, name string) (*Lender, error) {
+	err := assertLender(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if lenderId == "" {
+		return nil, newError(CodeInvalidArgument, "lender id must not be empty")
+	}
+
+	_, err = s.GetLender(ctx, lenderId)
+	if err == nil {
+		return nil, newError(CodeAlreadyExists, "lender %s already exists", lenderId)
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lender := Lender{
+		Id:    lenderId,
+		Name:  name,
+		MSPID: mspID,
+	}
+
+	err = putLender(ctx, &lender)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lender, nil
+}
+
+// GetLender returns the lender with given id
+func (s *SmartContract) GetLender(ctx contractapi.TransactionContextInterface, lenderId string) (*Lender, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(lenderObjectType, []string{lenderId})
+	if err != nil {
+		return nil, err
+	}
+
+	lenderAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if lenderAsBytes == nil {
+		return nil, newError(CodeNotFound, "lender %s does not exist", lenderId)
+	}
+
+	lender := new(Lender)
+	err = json.Unmarshal(lenderAsBytes, lender)
+	if err != nil {
+		return nil, err
+	}
+
+	return lender, nil
+}
+
+// RegisterLien proposes a lien for the loan of the lender on the car, with its
+// principal and outstanding balance in cents of the car's currency. It is
+// called by a lender of the organization the lender belongs to, and the lien
+// only takes effect once the owner of the car accepts it with AcceptLien.
+func (s *SmartContract) RegisterLien(ctx contractapi.TransactionContextInterface, lenderId string, carId string, principal int64, outstanding int64) (*Lien, error) {
+	lender, err := s.GetLender(ctx, lenderId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeLender(ctx, lender)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	if principal <= 0 || outstanding <= 0 {
+		return nil, newError(CodeInvalidArgument, "principal and outstanding balance of loan must be positive")
+	}
+
+	if outstanding > principal {
+		return nil, newError(CodeInvalidArgument, "outstanding balance of loan can not exceed its principal")
+	}
+
+	registeredAt, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lien := Lien{
+		Id:           ctx.GetStub().GetTxID(),
+		CarId:        carId,
+		LenderId:     lenderId,
+		OwnerId:      car.Owner,
+		Principal:    NewAmount(principal, car.Price.Currency),
+		Outstanding:  NewAmount(outstanding, car.Price.Currency),
+		Status:       LienProposed,
+		RegisteredAt: registeredAt,
+	}
+
+	err = putLien(ctx, &lien)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(lenderLienIndex, []string{lenderId, carId, lien.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	value := []byte{0x00}
+	err = ctx.GetStub().PutState(key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lien, nil
+}
+
+// AcceptLien lets the owner of the car agree to a proposed lien, which from
+// then on blocks scrapping the car and is paid off by its sale
+func (s *SmartContract) AcceptLien(ctx contractapi.TransactionContextInterface, carId string, lienId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	lien, err := s.GetLien(ctx, carId, lienId)
+	if err != nil {
+		return err
+	}
+
+	if lien.Status != LienProposed {
+		return newError(CodeInvalidState, "lien %s is already %s", lienId, lien.Status)
+	}
+
+	if lien.OwnerId != car.Owner {
+		return newError(CodeInvalidState, "lien %s was proposed to a previous owner of car %s", lienId, carId)
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
+	}
+
+	lien.Status = LienActive
+	return putLien(ctx, lien)
+}
+
+// RepayLien lets the owner of the car pay an amount in cents off the loan.
+// The lien is released once the outstanding balance is paid off.
+func (s *SmartContract) RepayLien(ctx contractapi.TransactionContextInterface, carId string, lienId string, amount int64) (*Lien, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	lien, err := s.GetLien(ctx, carId, lienId)
+	if err != nil {
+		return nil, err
+	}
+
+	if lien.Status != LienActive {
+		return nil, newError(CodeInvalidState, "lien %s is %s, not %s", lienId, lien.Status, LienActive)
+	}
+
+	if amount <= 0 {
+		return nil, newError(CodeInvalidArgument, "repaid amount must be positive")
+	}
+
+	repayment := NewAmount(amount, lien.Outstanding.Currency)
+	exceeds, err := lien.Outstanding.LessThan(repayment)
+	if err != nil {
+		return nil, err
+	}
+
+	if exceeds {
+		return nil, newError(CodeInvalidArgument, "repaid amount %s exceeds outstanding balance %s of lien %s", repayment, lien.Outstanding, lienId)
+	}
+
+	owner, err := s.getOwner(ctx, ownerKey(car.Owner))
+	if err != nil {
+		return nil, err
+	}
+
+	insufficient, err := owner.Money.LessThan(repayment)
+	if err != nil {
+		return nil, err
+	}
+
+	if insufficient {
+		return nil, newError(CodeInsufficientFunds, "owner does not have enough money to repay %s", repayment)
+	}
+
+	owner.Money, err = owner.Money.Sub(repayment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	lender, err := s.GetLender(ctx, lien.LenderId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = payLien(ctx, lender, lien, repayment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putLender(ctx, lender)
+	if err != nil {
+		return nil, err
+	}
+
+	return lien, nil
+}
+
+// ReleaseLien lets the lender withdraw a proposed lien or give up its active
+// lien on the car, whether or not the loan was paid off
+func (s *SmartContract) ReleaseLien(ctx contractapi.TransactionContextInterface, carId string, lienId string) error {
+	lien, err := s.GetLien(ctx, carId, lienId)
+	if err != nil {
+		return err
+	}
+
+	lender, err := s.GetLender(ctx, lien.LenderId)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeLender(ctx, lender)
+	if err != nil {
+		return err
+	}
+
+	if lien.Status == LienReleased {
+		return newError(CodeInvalidState, "lien %s is already %s", lienId, lien.Status)
+	}
+
+	lien.Status = LienReleased
+	return putLien(ctx, lien)
+}
+
+// GetLien returns the lien on the car with given id
+func (s *SmartContract) GetLien(ctx contractapi.TransactionContextInterface, carId string, lienId string) (*Lien, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(lienObjectType, []string{carId, lienId})
+	if err != nil {
+		return nil, err
+	}
+
+	lienAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if lienAsBytes == nil {
+		return nil, newError(CodeNotFound, "lien %s on car %s does not exist", lienId, carId)
+	}
+
+	lien := new(Lien)
+	err = json.Unmarshal(lienAsBytes, lien)
+	if err != nil {
+		return nil, err
+	}
+
+	return lien, nil
+}
+
+// GetLiensForCar returns all liens, in any status, on the car
+func (s *SmartContract) GetLiensForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Lien, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(lienObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	liens := make([]*Lien, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var lien Lien
+		err = json.Unmarshal(queryResponse.Value, &lien)
+		if err != nil {
+			return nil, err
+		}
+		liens = append(liens, &lien)
+	}
+
+	return liens, nil
+}
+
+// GetLiensByLender returns all liens, in any status, held by the lender
+func (s *SmartContract) GetLiensByLender(ctx contractapi.TransactionContextInterface, lenderId string) ([]*Lien, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(lenderLienIndex, []string{lenderId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	liens := make([]*Lien, 0)
+	for resultIter.HasNext() {
+		responseRange, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		lien, err := s.GetLien(ctx, compositeKeyParts[1], compositeKeyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		liens = append(liens, lien)
+	}
+
+	return liens, nil
+}
+
+// activeLiens returns the liens on the car that were accepted and are not
+// released
+func (s *SmartContract) activeLiens(ctx contractapi.TransactionContextInterface, carId string) ([]*Lien, error) {
+	liens, err := s.GetLiensForCar(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*Lien
+	for _, lien := range liens {
+		if lien.Status == LienActive {
+			active = append(active, lien)
+		}
+	}
+
+	return active, nil
+}
+
+// checkNoActiveLiens refuses cars that still secure a loan
+func (s *SmartContract) checkNoActiveLiens(ctx contractapi.TransactionContextInterface, carId string) error {
+	liens, err := s.activeLiens(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	if len(liens) > 0 {
+		return newError(CodeActiveLien, "car %s has an active lien %s of lender %s", carId, liens[0].Id, liens[0].LenderId).
+			with("carId", carId).with("lienId", liens[0].Id)
+	}
+
+	return nil
+}
+
+// settleLiens pays the outstanding balances of the active liens on the car
+// out of the price of its sale and releases them. It returns what is left of
+// the price for the seller, and fails when the price does not cover the
+// outstanding balances. A transaction does not read its own writes, so each
+// lender is read once, credited for all of its liens and written once.
+func (s *SmartContract) settleLiens(ctx contractapi.TransactionContextInterface, carId string, price Amount) (Amount, error) {
+	liens, err := s.activeLiens(ctx, carId)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	proceeds := price
+	var lenders []*Lender
+	lendersById := make(map[string]*Lender)
+	for _, lien := range liens {
+		insufficient, err := proceeds.LessThan(lien.Outstanding)
+		if err != nil {
+			return Amount{}, err
+		}
+
+		if insufficient {
+			return Amount{}, newError(CodeActiveLien, "price %s of car %s does not pay off lien %s of lender %s", price, carId, lien.Id, lien.LenderId).
+				with("carId", carId).with("lienId", lien.Id)
+		}
+
+		proceeds, err = proceeds.Sub(lien.Outstanding)
+		if err != nil {
+			return Amount{}, err
+		}
+
+		lender, ok := lendersById[lien.LenderId]
+		if !ok {
+			lender, err = s.GetLender(ctx, lien.LenderId)
+			if err != nil {
+				return Amount{}, err
+			}
+			lendersById[lender.Id] = lender
+			lenders = append(lenders, lender)
+		}
+
+		err = payLien(ctx, lender, lien, lien.Outstanding)
+		if err != nil {
+			return Amount{}, err
+		}
+	}
+
+	for _, lender := range lenders {
+		err = putLender(ctx, lender)
+		if err != nil {
+			return Amount{}, err
+		}
+	}
+
+	return proceeds, nil
+}
+
+// payLien credits the amount to the lender and reduces the outstanding
+// balance of the lien, releasing it once it is paid off. The caller writes
+// the lender.
+func payLien(ctx contractapi.TransactionContextInterface, lender *Lender, lien *Lien, amount Amount) error {
+	var err error
+	lender.Money, err = lender.Money.Add(amount)
+	if err != nil {
+		return err
+	}
+
+	lien.Outstanding, err = lien.Outstanding.Sub(amount)
+	if err != nil {
+		return err
+	}
+
+	if !lien.Outstanding.IsPositive() {
+		lien.Status = LienReleased
+	}
+
+	return putLien(ctx, lien)
+}
+
+// assertLender makes sure that the submitting client has the lender role
+func assertLender(ctx contractapi.TransactionContextInterface) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(lenderAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized, does not have %s role", lenderAttribute)
+	}
+
+	return nil
+}
+
+// authorizeLender makes sure that the submitting client is a lender of the
+// organization the lender belongs to
+func authorizeLender(ctx contractapi.TransactionContextInterface, lender *Lender) error {
+	err := assertLender(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mspID != lender.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not a lender of %s", lender.Id)
+	}
+
+	return nil
+}
+
+func putLender(ctx contractapi.TransactionContextInterface, lender *Lender) error {
+	lender.DocType = lenderObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(lenderObjectType, []string{lender.Id})
+	if err != nil {
+		return err
+	}
+
+	lenderAsBytes, err := json.Marshal(lender)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, lenderAsBytes)
+}
+
+func putLien(ctx contractapi.TransactionContextInterface, lien *Lien) error {
+	lien.DocType = lienObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(lienObjectType, []string{lien.CarId, lien.Id})
+	if err != nil {
+		return err
+	}
+
+	lienAsBytes, err := json.Marshal(lien)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, lienAsBytes)
+}
@@ -0,0 +1,138 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiens(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	bank := map[string]string{lenderAttribute: "true"}
+
+	_, err := s.RegisterLender(ctx.as("x509::CN=bank", "Org4MSP", bank), "bank1", "Banka")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("lien1")
+	lien, err := s.RegisterLien(ctx, "bank1", "4", 500000, 400000)
+	require.NoError(t, err)
+	require.Equal(t, LienProposed, lien.Status)
+	require.Equal(t, "2", lien.OwnerId)
+
+	// a lien only takes effect once the owner accepts it
+	_, err = s.RepayLien(ctx.asOwner(2), "4", lien.Id, 100000)
+	requireError(t, err, CodeInvalidState, "lien lien1 is PROPOSED, not ACTIVE")
+
+	err = s.AcceptLien(ctx.as("x509::CN=bank", "Org4MSP", bank), "4", lien.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 4, does not own it")
+
+	err = s.AcceptLien(ctx.asOwner(2), "4", lien.Id)
+	require.NoError(t, err)
+
+	err = s.AcceptLien(ctx, "4", lien.Id)
+	requireError(t, err, CodeInvalidState, "lien lien1 is already ACTIVE")
+
+	err = s.DeleteCar(ctx, "4")
+	requireError(t, err, CodeActiveLien, "car 4 has an active lien lien1 of lender bank1")
+
+	lien, err = s.RepayLien(ctx, "4", lien.Id, 100000)
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(300000, "EUR"), lien.Outstanding)
+	require.Equal(t, LienActive, lien.Status)
+
+	ctx.stub.MockTransactionStart("lien3")
+	_, err = s.RegisterLien(ctx.as("x509::CN=bank", "Org4MSP", bank), "bank1", "4", 100000, 100000)
+	require.NoError(t, err)
+
+	err = s.AcceptLien(ctx.asOwner(2), "4", "lien3")
+	require.NoError(t, err)
+
+	// the price of the car pays off both liens before the seller is paid
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+		require.NoError(t, err)
+	})
+
+	seller, err := s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(700000, "EUR"), seller.Money)
+
+	lender, err := s.GetLender(ctx, "bank1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(500000, "EUR"), lender.Money)
+
+	liens, err := s.GetLiensByLender(ctx, "bank1")
+	require.NoError(t, err)
+	require.Len(t, liens, 2)
+	for _, lien := range liens {
+		require.Equal(t, LienReleased, lien.Status)
+		require.Equal(t, NewAmount(0, "EUR"), lien.Outstanding)
+	}
+
+	// a lien bigger than the price blocks the sale
+	ctx.stub.MockTransactionStart("lien2")
+	_, err = s.RegisterLien(ctx.as("x509::CN=bank", "Org4MSP", bank), "bank1", "6", 500000, 500000)
+	require.NoError(t, err)
+
+	err = s.AcceptLien(ctx.asOwner(3), "6", "lien2")
+	require.NoError(t, err)
+
+	err = s.ApproveTransfer(ctx, "6", "OWNER1")
+	require.NoError(t, err)
+
+	err = s.TransferOwnership(ctx.asOwner(1), "6", "OWNER1", false)
+	requireError(t, err, CodeActiveLien, "price 2000.00 EUR of car 6 does not pay off lien lien2 of lender bank1")
+
+	err = s.ReleaseLien(ctx, "6", "lien2")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.lender role")
+
+	err = s.ReleaseLien(ctx.as("x509::CN=bank", "Org4MSP", bank), "6", "lien2")
+	require.NoError(t, err)
+
+	liens, err = s.GetLiensByLender(ctx, "bank1")
+	require.NoError(t, err)
+	require.Len(t, liens, 3)
+}
+
+func TestLienBlocksScrappingByMalfunctions(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	bank := map[string]string{lenderAttribute: "true"}
+
+	_, err := s.RegisterLender(ctx.as("x509::CN=bank", "Org4MSP", bank), "bank1", "Banka")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("lien1")
+	_, err = s.RegisterLien(ctx, "bank1", "6", 100000, 100000)
+	require.NoError(t, err)
+
+	err = s.AcceptLien(ctx.asOwner(3), "6", "lien1")
+	require.NoError(t, err)
+
+	// the malfunction would cost more than the car is worth
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "6", "Engine failure", 250000, SeverityCritical)
+	requireError(t, err, CodeActiveLien, "car 6 has an active lien lien1 of lender bank1")
+
+	car, err := s.GetCarById(ctx, "6")
+	require.NoError(t, err)
+	require.Equal(t, CarActive, car.Status)
+	require.Empty(t, car.Malfunctions)
+
+	err = s.ReleaseLien(ctx.as("x509::CN=bank", "Org4MSP", bank), "6", "lien1")
+	require.NoError(t, err)
+
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "6", "Engine failure", 250000, SeverityCritical)
+	require.NoError(t, err)
+
+	car, err = s.GetCarById(ctx, "6")
+	require.NoError(t, err)
+	require.Equal(t, CarScrapped, car.Status)
+}
@@ -0,0 +1,311 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Malfunction severities
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+// Malfunction statuses
+const (
+	MalfunctionOpen     = "OPEN"
+	MalfunctionInRepair = "IN_REPAIR"
+	MalfunctionRepaired = "REPAIRED"
+)
+
+// StartMalfunctionRepair lets the owner hand an open malfunction of the car
+// over to the chosen repair shop
+func (s *SmartContract) StartMalfunctionRepair(ctx contractapi.TransactionContextInterface, carId string, malfunctionId string, shopId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.GetRepairShop(ctx, shopId)
+	if err != nil {
+		return err
+	}
+
+	malfunction, err := findMalfunction(car, malfunctionId)
+	if err != nil {
+		return err
+	}
+
+	if malfunction.Status != MalfunctionOpen {
+		return newError(CodeInvalidState, "malfunction %s of car %s is %s, not %s", malfunctionId, carId, malfunction.Status, MalfunctionOpen)
+	}
+
+	err = changeCarStatus(ctx, car, CarInRepair)
+	if err != nil {
+		return err
+	}
+
+	malfunction.Status = MalfunctionInRepair
+	malfunction.ShopId = shopId
+
+	return putCar(ctx, car)
+}
+
+// RepairMalfunction marks a single malfunction in repair as repaired. It is
+// called by a mechanic of the chosen repair shop, which is paid only the
+// price of that malfunction, by the insurer of an approved claim for it and
+// by the co-owners, by their shares, for the part the claim does not cover.
+func (s *SmartContract) RepairMalfunction(ctx contractapi.TransactionContextInterface, carId string, malfunctionId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	malfunction, err := findMalfunction(car, malfunctionId)
+	if err != nil {
+		return err
+	}
+
+	if malfunction.Status == MalfunctionRepaired {
+		return newError(CodeInvalidState, "malfunction %s of car %s is already repaired", malfunctionId, carId)
+	}
+
+	if malfunction.Status != MalfunctionInRepair {
+		return newError(CodeInvalidState, "malfunction %s of car %s was not sent to a repair shop", malfunctionId, carId)
+	}
+
+	shop, err := s.GetRepairShop(ctx, malfunction.ShopId)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeMechanic(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
+	}
+
+	ownerShare, claims, err := s.splitRepairCost(ctx, car, []Malfunction{*malfunction})
+	if err != nil {
+		return err
+	}
+
+	parts, err := s.coOwnerParts(ctx, carShares(car), ownerShare)
+	if err != nil {
+		return err
+	}
+
+	payers, affordable, err := s.canAfford(ctx, car, parts)
+	if err != nil {
+		return err
+	}
+
+	if !affordable {
+		return newError(CodeInsufficientFunds, "owner does not have enough money to repair malfunction %s", malfunctionId)
+	}
+
+	repairedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	markRepaired(malfunction, repairedAt)
+
+	err = leaveRepair(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = payRepair(ctx, payers, parts, shop)
+	if err != nil {
+		return err
+	}
+
+	err = s.payClaims(ctx, claims, shop)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventCarRepaired, carId, malfunctionId, shop.Id, malfunction.Price)
+}
+
+// putCarWithMalfunctions stores the car after malfunctions were added to it.
+// A car whose open malfunctions cost more than the car itself is scrapped
+// instead, unless it secures a loan, as DeleteCar refuses it as well. The
+// malfunctions are kept on the scrapped car, which stays in world state to
+// explain why it was taken off the road. It tells whether the car was
+// scrapped and returns the price of its open malfunctions.
+func (s *SmartContract) putCarWithMalfunctions(ctx contractapi.TransactionContextInterface, car *Car) (bool, Amount, error) {
+	tooExpensive, totalPrice, err := openMalfunctionsExceedPrice(car)
+	if err != nil {
+		return false, Amount{}, err
+	}
+
+	if !tooExpensive {
+		return false, totalPrice, putCar(ctx, car)
+	}
+
+	err = s.checkNoActiveLiens(ctx, strconv.Itoa(car.Id))
+	if err != nil {
+		return false, Amount{}, err
+	}
+
+	return true, totalPrice, s.scrapCar(ctx, car)
+}
+
+// openMalfunctionsExceedPrice tells whether the open malfunctions of the car
+// cost more than the car, and returns their price
+func openMalfunctionsExceedPrice(car *Car) (bool, Amount, error) {
+	totalPrice, err := sumMalfunctionPrices(car.Price.Currency, openMalfunctions(car))
+	if err != nil {
+		return false, Amount{}, err
+	}
+
+	tooExpensive, err := car.Price.LessThan(totalPrice)
+	if err != nil {
+		return false, Amount{}, err
+	}
+
+	return tooExpensive, totalPrice, nil
+}
+
+// GetMalfunctions returns the malfunctions of the car, including repaired ones
+func (s *SmartContract) GetMalfunctions(ctx contractapi.TransactionContextInterface, carId string) ([]Malfunction, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if car.Malfunctions == nil {
+		return []Malfunction{}, nil
+	}
+
+	return car.Malfunctions, nil
+}
+
+// newMalfunction builds an open malfunction for the car, reported by the
+// submitting client at the time of the transaction
+func newMalfunction(ctx contractapi.TransactionContextInterface, car *Car, description string, price Amount, severity string) (Malfunction, error) {
+	err := checkSeverity(severity)
+	if err != nil {
+		return Malfunction{}, err
+	}
+
+	if price.IsNegative() {
+		return Malfunction{}, newError(CodeInvalidArgument, "price of malfunction can not be negative")
+	}
+
+	reportedAt, err := txTime(ctx)
+	if err != nil {
+		return Malfunction{}, err
+	}
+
+	reportedBy, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return Malfunction{}, err
+	}
+
+	return Malfunction{
+		Id:          strconv.Itoa(len(car.Malfunctions) + 1),
+		Description: description,
+		Price:       price,
+		Severity:    severity,
+		Status:      MalfunctionOpen,
+		ReportedAt:  reportedAt,
+		ReportedBy:  reportedBy,
+	}, nil
+}
+
+func checkSeverity(severity string) error {
+	switch severity {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return nil
+	default:
+		return newError(CodeInvalidArgument, "severity %s is not one of %s, %s, %s or %s", severity, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical)
+	}
+}
+
+// findMalfunction returns a pointer into the malfunctions of the car, so that
+// changes to it are stored together with the car
+func findMalfunction(car *Car, malfunctionId string) (*Malfunction, error) {
+	for i := range car.Malfunctions {
+		if car.Malfunctions[i].Id == malfunctionId {
+			return &car.Malfunctions[i], nil
+		}
+	}
+
+	return nil, newError(CodeNotFound, "malfunction %s of car %d does not exist", malfunctionId, car.Id)
+}
+
+// malfunctionsInRepairAt returns the malfunctions of the car that are being
+// repaired by the given shop
+func malfunctionsInRepairAt(car *Car, shopId string) []Malfunction {
+	var malfunctions []Malfunction
+	for _, malfunction := range car.Malfunctions {
+		if malfunction.Status == MalfunctionInRepair && malfunction.ShopId == shopId {
+			malfunctions = append(malfunctions, malfunction)
+		}
+	}
+
+	return malfunctions
+}
+
+// openMalfunctions returns the malfunctions of the car that are not repaired yet
+func openMalfunctions(car *Car) []Malfunction {
+	var malfunctions []Malfunction
+	for _, malfunction := range car.Malfunctions {
+		if malfunction.Status != MalfunctionRepaired {
+			malfunctions = append(malfunctions, malfunction)
+		}
+	}
+
+	return malfunctions
+}
+
+func sumMalfunctionPrices(currency string, malfunctions []Malfunction) (Amount, error) {
+	prices := make([]Amount, 0, len(malfunctions))
+	for _, malfunction := range malfunctions {
+		prices = append(prices, malfunction.Price)
+	}
+
+	return sumAmounts(currency, prices...)
+}
+
+func markRepaired(malfunction *Malfunction, repairedAt time.Time) {
+	malfunction.Status = MalfunctionRepaired
+	malfunction.RepairedAt = repairedAt
+}
+
+// txTime returns the timestamp of the transaction proposal, which is the same
+// on every endorsing peer
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errLedger("failed to read transaction timestamp: %v", err)
+	}
+
+	return ptypes.Timestamp(timestamp)
+}
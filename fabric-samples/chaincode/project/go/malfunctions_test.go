@@ -0,0 +1,98 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMalfunctionLifecycle(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.RegisterRepairShop(ctx, "shop1", "Auto Servis")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.mechanic role")
+
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.AddMalfunction(ctx.asOwner(1), "1", "Flat tire", 5000, SeverityLow)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.mechanic role")
+
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "1", "Flat tire", 5000, "SEVERE")
+	requireError(t, err, CodeInvalidArgument, "severity SEVERE is not one of LOW, MEDIUM, HIGH or CRITICAL")
+
+	ctx.stub.MockTransactionStart("tx2")
+	err = s.AddMalfunction(ctx, "1", "Flat tire", 5000, SeverityLow)
+	require.NoError(t, err)
+
+	malfunctions, err := s.GetMalfunctions(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, malfunctions, 2)
+	require.Equal(t, "2", malfunctions[1].Id)
+	require.Equal(t, MalfunctionOpen, malfunctions[1].Status)
+	require.Equal(t, "x509::CN=mechanic::Org2MSP", malfunctions[1].ReportedBy)
+	require.False(t, malfunctions[1].ReportedAt.IsZero())
+
+	err = s.RepairMalfunction(ctx, "1", "2")
+	requireError(t, err, CodeInvalidState, "malfunction 2 of car 1 was not sent to a repair shop")
+
+	err = s.StartMalfunctionRepair(ctx.asOwner(1), "1", "2", "shop1")
+	require.NoError(t, err)
+
+	err = s.StartMalfunctionRepair(ctx, "1", "2", "shop1")
+	requireError(t, err, CodeInvalidState, "malfunction 2 of car 1 is IN_REPAIR, not OPEN")
+
+	err = s.RepairMalfunction(ctx.asMechanic("Org3MSP"), "1", "2")
+	requireError(t, err, CodeUnauthorized, "submitting client is not a mechanic of repair shop shop1")
+
+	ctx.isolated(t, func() {
+		err = s.RepairMalfunction(ctx.asMechanic("Org2MSP"), "1", "2")
+		require.NoError(t, err)
+	})
+
+	err = s.RepairMalfunction(ctx, "1", "2")
+	requireError(t, err, CodeInvalidState, "malfunction 2 of car 1 is already repaired")
+
+	err = s.RepairMalfunction(ctx, "1", "3")
+	requireError(t, err, CodeNotFound, "malfunction 3 of car 1 does not exist")
+
+	car, err := s.GetCarById(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, MalfunctionOpen, car.Malfunctions[0].Status)
+	require.Equal(t, MalfunctionRepaired, car.Malfunctions[1].Status)
+	require.False(t, car.Malfunctions[1].RepairedAt.IsZero())
+
+	owner, err := s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(995000, "EUR"), owner.Money)
+
+	// repairing the whole car only charges what the owner sent to the shop
+	err = s.RepairCar(ctx.asMechanic("Org2MSP"), "1", "shop1")
+	requireError(t, err, CodeInvalidState, "car 1 has no malfunctions in repair at shop shop1")
+
+	err = s.SendCarToRepairShop(ctx.asOwner(1), "1", "shop1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.RepairCar(ctx.asMechanic("Org2MSP"), "1", "shop1")
+		require.NoError(t, err)
+	})
+
+	owner, err = s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(975000, "EUR"), owner.Money)
+
+	shop, err := s.GetRepairShop(ctx, "shop1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(25000, "EUR"), shop.Money)
+
+	car, err = s.GetCarById(ctx, "1")
+	require.NoError(t, err)
+	require.Empty(t, openMalfunctions(car))
+	require.Len(t, car.Malfunctions, 2)
+}
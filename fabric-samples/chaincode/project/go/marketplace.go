@@ -0,0 +1,550 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	listingObjectType = "listing"
+	offerObjectType   = "offer"
+)
+
+// Offer statuses
+const (
+	OfferOpen      = "OPEN"
+	OfferAccepted  = "ACCEPTED"
+	OfferRejected  = "REJECTED"
+	OfferWithdrawn = "WITHDRAWN"
+)
+
+// Listing puts a car up for sale at the asking price of its owner
+type Listing struct {
+	DocType     string `json:"docType"`
+	CarId       string `json:"carId"`
+	Seller      string `json:"seller"`
+	AskingPrice Amount `json:"askingPrice"`
+}
+
+// Offer is a bid of a buyer for a listed car. The offered amount is held in
+// escrow, taken from the buyer's money, for as long as the offer is open.
+type Offer struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	CarId   string `json:"carId"`
+	Buyer   string `json:"buyer"`
+	Amount  Amount `json:"amount"`
+	Status  string `json:"status"`
+}
+
+// ListCarForSale puts the car up for sale at the asking price in cents of the
+// car's currency. Only the owner of the car can list it, and listing an
+// already listed car changes the asking price.
+func (s *SmartContract) ListCarForSale(ctx contractapi.TransactionContextInterface, carId string, askingPrice int64) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if askingPrice <= 0 {
+		return newError(CodeInvalidArgument, "asking price must be positive")
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	err = changeCarStatus(ctx, car, CarForSale)
+	if err != nil {
+		return err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	listing := Listing{
+		CarId:       carId,
+		Seller:      car.Owner,
+		AskingPrice: NewAmount(askingPrice, car.Price.Currency),
+	}
+
+	return putListing(ctx, &listing)
+}
+
+// CancelListing takes the car off the market and refunds all open offers
+func (s *SmartContract) CancelListing(ctx contractapi.TransactionContextInterface, carId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.GetListing(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.closeOpenOffers(ctx, carId, "", OfferRejected)
+	if err != nil {
+		return err
+	}
+
+	err = changeCarStatus(ctx, car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return deleteListing(ctx, carId)
+}
+
+// MakeOffer places a bid of the buyer, in cents of the listing's currency, on
+// a listed car. The submitting client must be the buyer, whose money is held
+// in escrow until the offer is closed. The car must be for sale and not
+// leased.
+func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface, carId string, buyerId string, amount int64) (*Offer, error) {
+	listing, err := s.GetListing(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	buyer, err := s.getOwner(ctx, buyerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeOwner(ctx, buyer)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerPercent(car, strconv.Itoa(buyer.Id)) > 0 {
+		return nil, newError(CodeInvalidState, "owner can not make an offer for their own car")
+	}
+
+	err = checkCarStatus(car, CarForSale)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 {
+		return nil, newError(CodeInvalidArgument, "offered amount must be positive")
+	}
+	offered := NewAmount(amount, listing.AskingPrice.Currency)
+
+	insufficient, err := buyer.Money.LessThan(offered)
+	if err != nil {
+		return nil, err
+	}
+
+	if insufficient {
+		return nil, newError(CodeInsufficientFunds, "buyer does not have enough money for this offer")
+	}
+
+	buyer.Money, err = buyer.Money.Sub(offered)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putOwner(ctx, buyer)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := Offer{
+		Id:     ctx.GetStub().GetTxID(),
+		CarId:  carId,
+		Buyer:  strconv.Itoa(buyer.Id),
+		Amount: offered,
+		Status: OfferOpen,
+	}
+
+	err = putOffer(ctx, &offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offer, nil
+}
+
+// AcceptOffer sells the car to the buyer of the offer. The escrowed amount
+// pays off the liens on the car, the rest is paid to the seller, or split
+// among the co-owners by their shares, and every other open offer for the
+// car is refunded, in payments for the owners to collect. Co-owners holding
+// the sale majority of a co-owned car must have approved the buyer with
+// ApproveTransfer, and the car needs a valid inspection certificate if the
+// contract requires one. As with MakeOffer, the car must still be for sale
+// and not leased.
+func (s *SmartContract) AcceptOffer(ctx contractapi.TransactionContextInterface, carId string, offerId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	offer, err := s.getOpenOffer(ctx, carId, offerId)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, CarForSale)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkNoActiveLease(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	if len(car.Shares) > 0 {
+		if car.ApprovedBuyer != offer.Buyer {
+			return newError(CodeNotApproved, "co-owners of car %s did not approve the sale to %s", carId, ownerKey(offer.Buyer))
+		}
+
+		err = checkSaleConsent(car)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.checkInspection(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	oldOwnerId := car.Owner
+	proceeds, err := s.settleLiens(ctx, carId, offer.Amount)
+	if err != nil {
+		return err
+	}
+
+	err = s.paySellers(ctx, carShares(car), proceeds, PaymentSale)
+	if err != nil {
+		return err
+	}
+
+	err = changeCarStatus(ctx, car, CarActive)
+	if err != nil {
+		return err
+	}
+
+	err = s.setCarOwner(ctx, car, offer.Buyer)
+	if err != nil {
+		return err
+	}
+
+	offer.Status = OfferAccepted
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return err
+	}
+
+	err = s.closeOpenOffers(ctx, carId, offerId, OfferRejected)
+	if err != nil {
+		return err
+	}
+
+	err = deleteListing(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventOwnershipTransfer, carId, oldOwnerId, offer.Buyer, offer.Amount)
+}
+
+// RejectOffer lets the owner of the car turn down an offer, refunding the buyer
+func (s *SmartContract) RejectOffer(ctx contractapi.TransactionContextInterface, carId string, offerId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	offer, err := s.getOpenOffer(ctx, carId, offerId)
+	if err != nil {
+		return err
+	}
+
+	return s.closeOffer(ctx, offer, OfferRejected)
+}
+
+// WithdrawOffer lets the buyer take back their own offer and the escrowed money
+func (s *SmartContract) WithdrawOffer(ctx contractapi.TransactionContextInterface, carId string, offerId string) error {
+	offer, err := s.getOpenOffer(ctx, carId, offerId)
+	if err != nil {
+		return err
+	}
+
+	buyer, err := s.getOwner(ctx, ownerKey(offer.Buyer))
+	if err != nil {
+		return err
+	}
+
+	err = authorizeOwner(ctx, buyer)
+	if err != nil {
+		return err
+	}
+
+	return s.closeOffer(ctx, offer, OfferWithdrawn)
+}
+
+// GetListing returns the listing of the car with given id
+func (s *SmartContract) GetListing(ctx contractapi.TransactionContextInterface, carId string) (*Listing, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+
+	listingAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if listingAsBytes == nil {
+		return nil, newError(CodeNotFound, "car %s is not listed for sale", carId)
+	}
+
+	listing := new(Listing)
+	err = json.Unmarshal(listingAsBytes, listing)
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// GetAllListings returns all cars that are currently for sale
+func (s *SmartContract) GetAllListings(ctx contractapi.TransactionContextInterface) ([]*Listing, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(listingObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	listings := make([]*Listing, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var listing Listing
+		err = json.Unmarshal(queryResponse.Value, &listing)
+		if err != nil {
+			return nil, err
+		}
+		listings = append(listings, &listing)
+	}
+
+	return listings, nil
+}
+
+// GetOffersForCar returns all offers, in any status, made for the car
+func (s *SmartContract) GetOffersForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Offer, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(offerObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	offers := make([]*Offer, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var offer Offer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, &offer)
+	}
+
+	return offers, nil
+}
+
+// isListed reports whether the car is currently listed for sale
+func isListed(ctx contractapi.TransactionContextInterface, carId string) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{carId})
+	if err != nil {
+		return false, err
+	}
+
+	listingAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, errLedger("failed to read from world state: %v", err)
+	}
+
+	return listingAsBytes != nil, nil
+}
+
+func (s *SmartContract) getOpenOffer(ctx contractapi.TransactionContextInterface, carId string, offerId string) (*Offer, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{carId, offerId})
+	if err != nil {
+		return nil, err
+	}
+
+	offerAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if offerAsBytes == nil {
+		return nil, newError(CodeNotFound, "offer %s for car %s does not exist", offerId, carId)
+	}
+
+	offer := new(Offer)
+	err = json.Unmarshal(offerAsBytes, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	if offer.Status != OfferOpen {
+		return nil, newError(CodeInvalidState, "offer %s is already %s", offerId, offer.Status)
+	}
+
+	return offer, nil
+}
+
+// closeOffer refunds the escrowed amount to the buyer and stores the offer
+// with its final status
+func (s *SmartContract) closeOffer(ctx contractapi.TransactionContextInterface, offer *Offer, status string) error {
+	return s.closeOffers(ctx, []*Offer{offer}, status)
+}
+
+// closeOpenOffers closes every open offer for the car except the one with
+// the given id
+func (s *SmartContract) closeOpenOffers(ctx contractapi.TransactionContextInterface, carId string, exceptOfferId string, status string) error {
+	offers, err := s.GetOffersForCar(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	open := make([]*Offer, 0, len(offers))
+	for _, offer := range offers {
+		if offer.Status != OfferOpen || offer.Id == exceptOfferId {
+			continue
+		}
+		open = append(open, offer)
+	}
+
+	return s.closeOffers(ctx, open, status)
+}
+
+// closeOffers stores the offers with their final status and refunds their
+// escrowed amounts as payments for the buyers to collect. A transaction pays
+// an owner once for each reason, so the refunds of a buyer holding several
+// offers are added up and paid at once.
+func (s *SmartContract) closeOffers(ctx contractapi.TransactionContextInterface, offers []*Offer, status string) error {
+	var buyers []string
+	refunds := make(map[string]Amount)
+	for _, offer := range offers {
+		refund, ok := refunds[offer.Buyer]
+		if !ok {
+			buyers = append(buyers, offer.Buyer)
+		}
+
+		refund, err := refund.Add(offer.Amount)
+		if err != nil {
+			return err
+		}
+		refunds[offer.Buyer] = refund
+
+		offer.Status = status
+		err = putOffer(ctx, offer)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, buyerId := range buyers {
+		err := s.payOwner(ctx, buyerId, refunds[buyerId], PaymentRefund)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func putListing(ctx contractapi.TransactionContextInterface, listing *Listing) error {
+	listing.DocType = listingObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{listing.CarId})
+	if err != nil {
+		return err
+	}
+
+	listingAsBytes, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, listingAsBytes)
+}
+
+func deleteListing(ctx contractapi.TransactionContextInterface, carId string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{carId})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+func putOffer(ctx contractapi.TransactionContextInterface, offer *Offer) error {
+	offer.DocType = offerObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offer.CarId, offer.Id})
+	if err != nil {
+		return err
+	}
+
+	offerAsBytes, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, offerAsBytes)
+}
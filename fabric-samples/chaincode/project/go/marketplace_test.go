@@ -0,0 +1,224 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarketplace(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 600000)
+	requireError(t, err, CodeNotFound, "car 4 is not listed for sale")
+
+	err = s.ListCarForSale(ctx, "4", 650000)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 4, does not own it")
+
+	err = s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	_, err = s.MakeOffer(ctx, "4", "OWNER2", 600000)
+	requireError(t, err, CodeInvalidState, "owner can not make an offer for their own car")
+
+	ctx.stub.MockTransactionStart("offer1")
+	first, err := s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 600000)
+	require.NoError(t, err)
+	require.Equal(t, "offer1", first.Id)
+
+	ctx.stub.MockTransactionStart("offer3")
+	_, err = s.MakeOffer(ctx.asOwner(3), "4", "OWNER3", 600000)
+	requireError(t, err, CodeInsufficientFunds, "buyer does not have enough money for this offer")
+
+	second, err := s.MakeOffer(ctx, "4", "OWNER3", 500000)
+	require.NoError(t, err)
+
+	// the offered amounts are held in escrow
+	buyer, err := s.GetOwnerById(ctx, "OWNER3")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(0, "EUR"), buyer.Money)
+
+	err = s.WithdrawOffer(ctx, "4", first.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	err = s.AcceptOffer(ctx, "4", first.Id)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 4, does not own it")
+
+	ctx.isolated(t, func() {
+		err = s.AcceptOffer(ctx.asOwner(2), "4", first.Id)
+		require.NoError(t, err)
+	})
+
+	err = s.RejectOffer(ctx.asOwner(1), "4", second.Id)
+	requireError(t, err, CodeInvalidState, "offer offer3 is already REJECTED")
+
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, "1", car.Owner)
+
+	cars, err := s.GetCarsByColorAndOwner(ctx, "blue", "1")
+	require.NoError(t, err)
+	require.Len(t, cars, 2)
+
+	// the seller and the refunded buyer collect their payments
+	expectedMoney := map[int]int64{1: 400000, 2: 1100000, 3: 500000}
+	for ownerId, money := range expectedMoney {
+		owner, err := s.CollectPayments(ctx.asOwner(ownerId), ownerKey(strconv.Itoa(ownerId)))
+		require.NoError(t, err)
+		require.Equal(t, NewAmount(money, "EUR"), owner.Money, ownerId)
+	}
+
+	listings, err := s.GetAllListings(ctx)
+	require.NoError(t, err)
+	require.Empty(t, listings)
+
+	offers, err := s.GetOffersForCar(ctx, "4")
+	require.NoError(t, err)
+	require.Len(t, offers, 2)
+}
+
+func TestMarketplaceRefundsEveryOfferOfABuyer(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer1")
+	_, err = s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 300000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer2")
+	_, err = s.MakeOffer(ctx, "4", "OWNER1", 200000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer3")
+	accepted, err := s.MakeOffer(ctx.asOwner(3), "4", "OWNER3", 400000)
+	require.NoError(t, err)
+
+	buyer, err := s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(500000, "EUR"), buyer.Money)
+
+	ctx.isolated(t, func() {
+		err = s.AcceptOffer(ctx.asOwner(2), "4", accepted.Id)
+		require.NoError(t, err)
+	})
+
+	payments, err := s.GetPayments(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, PaymentRefund, payments[0].Reason)
+	require.Equal(t, NewAmount(500000, "EUR"), payments[0].Amount)
+
+	buyer, err = s.CollectPayments(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1000000, "EUR"), buyer.Money)
+
+	// the same holds when the listing is cancelled
+	err = s.ListCarForSale(ctx.asOwner(3), "4", 650000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer4")
+	_, err = s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 300000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer5")
+	_, err = s.MakeOffer(ctx, "4", "OWNER1", 200000)
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.CancelListing(ctx.asOwner(3), "4")
+		require.NoError(t, err)
+	})
+
+	buyer, err = s.CollectPayments(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1000000, "EUR"), buyer.Money)
+}
+
+func TestOffersNeedACarForSale(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer1")
+	_, err = s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 600000)
+	require.NoError(t, err)
+
+	// a lease accepted behind the listing's back blocks the sale
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	end := today.AddDate(0, 0, 3)
+	lease := &Lease{Id: "lease1", CarId: "4", Lessee: "3", Lessor: "2", StartDate: today, EndDate: end, Status: LeaseActive}
+	err = putLease(ctx, lease)
+	require.NoError(t, err)
+
+	_, err = s.MakeOffer(ctx.asOwner(3), "4", "OWNER3", 100000)
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER3 until "+end.Format(leaseDateLayout))
+
+	err = s.AcceptOffer(ctx.asOwner(2), "4", "offer1")
+	requireError(t, err, CodeCarLeased, "car 4 is leased to OWNER3 until "+end.Format(leaseDateLayout))
+
+	lease.Status = LeaseReturned
+	err = putLease(ctx, lease)
+	require.NoError(t, err)
+
+	// and so does a car that is no longer for sale
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	car.Status = CarInRepair
+	err = putCar(ctx, car)
+	require.NoError(t, err)
+
+	_, err = s.MakeOffer(ctx.asOwner(3), "4", "OWNER3", 100000)
+	requireError(t, err, CodeInvalidState, "car 4 is IN_REPAIR")
+
+	err = s.AcceptOffer(ctx.asOwner(2), "4", "offer1")
+	requireError(t, err, CodeInvalidState, "car 4 is IN_REPAIR")
+}
+
+func TestOffersOfOtherOrganizations(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.CreateOwner(ctx.as("x509::CN=buyer", "Org2MSP", map[string]string{adminAttribute: "true"}).withOwnerDetails("Ana", "Anic", "ana@example.com", 1000000), "")
+	require.NoError(t, err)
+
+	err = s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer1")
+	err = ctx.endorsed(t, func() error {
+		_, err := s.MakeOffer(ctx.as("x509::CN=buyer", "Org2MSP", nil), "4", "OWNER4", 600000)
+		return err
+	}, "Org2MSP")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("offer2")
+	accepted, err := s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 500000)
+	require.NoError(t, err)
+
+	// the peers of the seller's organization refund the buyer of Org2
+	err = ctx.endorsed(t, func() error {
+		return s.AcceptOffer(ctx.asOwner(2), "4", accepted.Id)
+	}, "Org1MSP")
+	require.NoError(t, err)
+
+	payments, err := s.GetPayments(ctx, "OWNER4")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, PaymentRefund, payments[0].Reason)
+
+	buyer, err := s.CollectPayments(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1000000, "EUR"), buyer.Money)
+}
@@ -0,0 +1,95 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OdometerReading is the mileage of a car as submitted by its owner or a
+// mechanic at the time of the transaction
+type OdometerReading struct {
+	Mileage    int       `json:"mileage"`
+	RecordedAt time.Time `json:"recordedAt"`
+	RecordedBy string    `json:"recordedBy"`
+	Suspicious bool      `json:"suspicious"`
+}
+
+// RecordMileage adds an odometer reading to the car. The owner can only
+// record readings that are not lower than the mileage of the car. A mechanic
+// who finds the odometer below that mileage records the reading anyway, and
+// the car is flagged as suspected of odometer tampering.
+func (s *SmartContract) RecordMileage(ctx contractapi.TransactionContextInterface, carId string, mileage int) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return err
+	}
+
+	if mileage < 0 {
+		return newError(CodeInvalidArgument, "mileage of car can not be negative")
+	}
+
+	mechanic := assertMechanic(ctx) == nil
+	if !mechanic {
+		err = s.authorizeCarOwner(ctx, car)
+		if err != nil {
+			return err
+		}
+	}
+
+	suspicious := mileage < car.Mileage
+	if suspicious && !mechanic {
+		return newError(CodeInvalidArgument, "mileage %d of car %s is lower than the recorded mileage %d", mileage, carId, car.Mileage)
+	}
+
+	reading, err := newOdometerReading(ctx, mileage)
+	if err != nil {
+		return err
+	}
+	reading.Suspicious = suspicious
+
+	oldMileage := car.Mileage
+	car.OdometerReadings = append(car.OdometerReadings, reading)
+	if suspicious {
+		car.MileageTampered = true
+	} else {
+		car.Mileage = mileage
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	return emitCarEvent(ctx, EventMileageRecorded, carId, strconv.Itoa(oldMileage), strconv.Itoa(mileage), Amount{})
+}
+
+// newOdometerReading builds a reading submitted by the client of the
+// transaction at the time of the transaction
+func newOdometerReading(ctx contractapi.TransactionContextInterface, mileage int) (OdometerReading, error) {
+	recordedAt, err := txTime(ctx)
+	if err != nil {
+		return OdometerReading{}, err
+	}
+
+	recordedBy, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return OdometerReading{}, err
+	}
+
+	return OdometerReading{
+		Mileage:    mileage,
+		RecordedAt: recordedAt,
+		RecordedBy: recordedBy,
+	}, nil
+}
@@ -0,0 +1,52 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordMileage(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.RecordMileage(ctx, "1", 190000)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 1, does not own it")
+
+	err = s.RecordMileage(ctx.asOwner(1), "1", 190000)
+	require.NoError(t, err)
+
+	name, event := ctx.lastEvent(t)
+	require.Equal(t, EventMileageRecorded, name)
+	require.Equal(t, "182000", event.OldValue)
+	require.Equal(t, "190000", event.NewValue)
+
+	err = s.RecordMileage(ctx, "1", 150000)
+	requireError(t, err, CodeInvalidArgument, "mileage 150000 of car 1 is lower than the recorded mileage 190000")
+
+	car, err := s.GetCarById(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, 190000, car.Mileage)
+	require.False(t, car.MileageTampered)
+	require.Len(t, car.OdometerReadings, 2)
+	require.Equal(t, "x509::CN=owner1", car.OdometerReadings[1].RecordedBy)
+
+	// a mechanic finding a rolled back odometer records it as evidence
+	err = s.RecordMileage(ctx.asMechanic("Org2MSP"), "1", 150000)
+	require.NoError(t, err)
+
+	name, event = ctx.lastEvent(t)
+	require.Equal(t, EventMileageRecorded, name)
+	require.Equal(t, "190000", event.OldValue)
+	require.Equal(t, "150000", event.NewValue)
+
+	car, err = s.GetCarById(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, 190000, car.Mileage)
+	require.True(t, car.MileageTampered)
+	require.True(t, car.OdometerReadings[2].Suspicious)
+}
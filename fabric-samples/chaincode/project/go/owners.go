@@ -0,0 +1,373 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	ownerKeyPrefix = "OWNER"
+	emailIndex     = "email~id"
+)
+
+// ownerKey returns the world state key of the owner with the given numeric id
+func ownerKey(id string) string {
+	return ownerKeyPrefix + id
+}
+
+// CreateOwner adds a new owner bound to the submitting client identity. The
+// name, surname, email and money in cents of the given currency are passed in
+// the "owner" field of the transient map and kept in the private collection,
+// together with the random salt of their hash passed in the "salt" field.
+// Owners start without money, only clients with the cars.admin role may give
+// them a starting balance. When id is empty the next free owner id is
+// generated, otherwise it must be a positive number that is not used by
+// another owner.
+func (s *SmartContract) CreateOwner(ctx contractapi.TransactionContextInterface, id string) (*Owner, error) {
+	details, err := readOwnerDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := readSalt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		nextId, err := s.nextOwnerId(ctx)
+		if err != nil {
+			return nil, err
+		}
+		id = strconv.Itoa(nextId)
+	}
+
+	ownerId, err := strconv.Atoi(id)
+	if err != nil || ownerId <= 0 {
+		return nil, newError(CodeInvalidArgument, "owner id %s must be a positive number", id)
+	}
+
+	exists, err := s.OwnerExists(ctx, ownerKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, newError(CodeAlreadyExists, "owner %s already exists", ownerKey(id))
+	}
+
+	if details.Money < 0 {
+		return nil, newError(CodeInvalidArgument, "money of owner can not be negative")
+	}
+
+	if details.Currency == "" {
+		return nil, newError(CodeInvalidArgument, "currency of owner's money must not be empty")
+	}
+
+	if details.Money > 0 {
+		err = ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+		if err != nil {
+			return nil, newError(CodeUnauthorized, "submitting client not authorized to give an owner starting money, does not have %s role", adminAttribute)
+		}
+	}
+
+	email, err := s.checkOwnerEmail(ctx, details.Email, "")
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkIdentityUnused(ctx, clientID, mspID, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := Owner{
+		Id:       ownerId,
+		Name:     details.Name,
+		Surname:  details.Surname,
+		Email:    email,
+		Money:    NewAmount(details.Money, details.Currency),
+		ClientId: clientID,
+		MSPID:    mspID,
+		Salt:     hex.EncodeToString(salt),
+	}
+
+	err = putOwner(ctx, &owner)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putEmailIndex(ctx, &owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return &owner, nil
+}
+
+// UpdateOwner changes the personal details of the owner stored under ownerId
+// to the name, surname and email passed in the transient map. The details are
+// hashed with the new random salt passed in the "salt" field.
+func (s *SmartContract) UpdateOwner(ctx contractapi.TransactionContextInterface, ownerId string) (*Owner, error) {
+	details, err := readOwnerDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := readSalt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := s.checkOwnerEmail(ctx, details.Email, strconv.Itoa(owner.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	if email != owner.Email {
+		err = deleteEmailIndex(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		owner.Email = email
+		err = putEmailIndex(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	owner.Name = details.Name
+	owner.Surname = details.Surname
+	owner.Salt = hex.EncodeToString(salt)
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+// DeleteOwner removes the owner stored under ownerId. Owners that still own
+// cars or have payments to collect can not be deleted.
+func (s *SmartContract) DeleteOwner(ctx contractapi.TransactionContextInterface, ownerId string) error {
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+
+	err = authorizeOwner(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	payments, err := pendingPayments(ctx, strconv.Itoa(owner.Id))
+	if err != nil {
+		return err
+	}
+	if len(payments) > 0 {
+		return newError(CodeInvalidState, "owner %s has payments to collect and can not be deleted", ownerId)
+	}
+
+	ownsCars, err := s.ownerHasCars(ctx, strconv.Itoa(owner.Id))
+	if err != nil {
+		return err
+	}
+	if ownsCars {
+		return newError(CodeInvalidState, "owner %s still owns cars and can not be deleted", ownerId)
+	}
+
+	err = deleteEmailIndex(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	return deleteOwner(ctx, owner)
+}
+
+// GetAllOwners returns the owners that the submitting client may read, which
+// are the owners of its organization
+func (s *SmartContract) GetAllOwners(ctx contractapi.TransactionContextInterface) ([]*Owner, error) {
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orgOwners(ctx, mspID)
+}
+
+// orgOwners returns every owner of the organization with its private details.
+// Only clients of that organization can read them.
+func (s *SmartContract) orgOwners(ctx contractapi.TransactionContextInterface, mspID string) ([]*Owner, error) {
+	records, err := s.ownerRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make([]*Owner, 0)
+	for _, record := range records {
+		if record.MSPID != mspID {
+			continue
+		}
+
+		owner, err := s.getOwner(ctx, ownerKey(strconv.Itoa(record.Id)))
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+
+	return owners, nil
+}
+
+// ownerRecords returns the public records of all owners in world state
+func (s *SmartContract) ownerRecords(ctx contractapi.TransactionContextInterface) ([]*OwnerRecord, error) {
+	// every owner key starts with the OWNER prefix followed by digits,
+	// so the range below covers all of them and nothing else.
+	resultsIterator, err := ctx.GetStub().GetStateByRange(ownerKeyPrefix, ownerKeyPrefix+"~")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := make([]*OwnerRecord, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var record OwnerRecord
+		err = json.Unmarshal(queryResponse.Value, &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
+
+// nextOwnerId returns the id following the biggest owner id in world state
+func (s *SmartContract) nextOwnerId(ctx contractapi.TransactionContextInterface) (int, error) {
+	records, err := s.ownerRecords(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	maxId := 0
+	for _, record := range records {
+		if record.Id > maxId {
+			maxId = record.Id
+		}
+	}
+
+	return maxId + 1, nil
+}
+
+// checkOwnerEmail validates the email address and makes sure that no owner
+// other than ownerId, of the submitting client's organization, uses it. It
+// returns the address in normalized form.
+func (s *SmartContract) checkOwnerEmail(ctx contractapi.TransactionContextInterface, email string, ownerId string) (string, error) {
+	if !isValidEmail(email) {
+		return "", newError(CodeInvalidArgument, "%s is not a valid email address", email)
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	key, err := emailIndexKey(ctx, email)
+	if err != nil {
+		return "", err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	usedBy, err := ctx.GetStub().GetPrivateData(ownerCollection(mspID), key)
+	if err != nil {
+		return "", errLedger("failed to read email index from private data collection: %v", err)
+	}
+
+	if usedBy != nil && string(usedBy) != ownerId {
+		return "", newError(CodeAlreadyExists, "email %s is already used by owner %s", email, ownerKey(string(usedBy)))
+	}
+
+	// the collections of other organizations can not be read, but the hash
+	// of an index entry tells that the address is taken
+	records, err := s.ownerRecords(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	checked := map[string]bool{ownerCollection(mspID): true}
+	for _, record := range records {
+		if record.Collection == "" || checked[record.Collection] {
+			continue
+		}
+		checked[record.Collection] = true
+
+		hash, err := ctx.GetStub().GetPrivateDataHash(record.Collection, key)
+		if err != nil {
+			return "", errLedger("failed to read email index hash from private data collection: %v", err)
+		}
+
+		if hash != nil {
+			return "", newError(CodeAlreadyExists, "email %s is already used by an owner of %s", email, record.MSPID)
+		}
+	}
+
+	return email, nil
+}
+
+// isValidEmail accepts plain addresses such as name@example.com, without
+// display names and with a dot in the domain part
+func isValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != strings.TrimSpace(email) {
+		return false
+	}
+
+	domain := address.Address[strings.LastIndex(address.Address, "@")+1:]
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// ownerHasCars tells whether the given owner holds the whole car or a share
+// of any car that is not scrapped
+func (s *SmartContract) ownerHasCars(ctx contractapi.TransactionContextInterface, ownerId string) (bool, error) {
+	cars, err := s.carsByIndex(ctx, ownerIndex, ownerId)
+	if err != nil {
+		return false, err
+	}
+
+	return len(cars) > 0, nil
+}
@@ -0,0 +1,99 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOwner(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "Ana.Anic@example.com", 10000), "")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to give an owner starting money, does not have cars.admin role")
+
+	owner, err := s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "Ana.Anic@example.com", 0), "")
+	require.NoError(t, err)
+	require.Equal(t, 4, owner.Id)
+	require.Equal(t, "ana.anic@example.com", owner.Email)
+	require.Equal(t, NewAmount(0, "EUR"), owner.Money)
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "other@example.com", 0), "4")
+	requireError(t, err, CodeAlreadyExists, "owner OWNER4 already exists")
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "other@example.com", 0), "")
+	requireError(t, err, CodeAlreadyExists, "client identity is already bound to owner OWNER4")
+
+	ctx.as("x509::CN=User2", "Org1MSP", nil)
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "other@example.com", 10000), "x")
+	requireError(t, err, CodeInvalidArgument, "owner id x must be a positive number")
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "sarapoparic@gmail.com", 0), "")
+	requireError(t, err, CodeAlreadyExists, "email sarapoparic@gmail.com is already used by owner OWNER1")
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "Ana <ana@example.com>", 0), "")
+	requireError(t, err, CodeInvalidArgument, "Ana <ana@example.com> is not a valid email address")
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "ana@localhost", 0), "")
+	requireError(t, err, CodeInvalidArgument, "ana@localhost is not a valid email address")
+
+	// administrators may give the owners they create starting money
+	owner, err = s.CreateOwner(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}).withOwnerDetails("Ivo", "Ivic", "ivo@example.com", 10000), "")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(10000, "EUR"), owner.Money)
+
+	owners, err := s.GetAllOwners(ctx.as("x509::CN=User2", "Org1MSP", nil))
+	require.NoError(t, err)
+	require.Len(t, owners, 5)
+}
+
+func TestUpdateOwner(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.UpdateOwner(ctx.withOwnerDetails("Sara", "Peric", "sara@example.com", 0), "OWNER1")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	ctx.asOwner(1)
+	_, err = s.UpdateOwner(ctx.withOwnerDetails("Sara", "Poparic", "milapoparic@gmail.com", 0), "OWNER1")
+	requireError(t, err, CodeAlreadyExists, "email milapoparic@gmail.com is already used by owner OWNER2")
+
+	owner, err := s.UpdateOwner(ctx.withOwnerDetails("Sara", "Peric", "sara@example.com", 0), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, "Peric", owner.Surname)
+
+	// the old address is free again once the owner moved away from it
+	ctx.as("x509::CN=User1", "Org1MSP", nil)
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Sara", "Poparic", "sarapoparic@gmail.com", 0), "")
+	require.NoError(t, err)
+}
+
+func TestDeleteOwner(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	err := s.DeleteOwner(ctx, "OWNER1")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to act as owner OWNER1")
+
+	err = s.DeleteOwner(ctx.asOwner(1), "OWNER1")
+	requireError(t, err, CodeInvalidState, "owner OWNER1 still owns cars and can not be deleted")
+
+	_, err = s.CreateOwner(ctx.as("x509::CN=User1", "Org1MSP", nil).withOwnerDetails("Ana", "Anic", "ana@example.com", 0), "7")
+	require.NoError(t, err)
+
+	err = s.DeleteOwner(ctx, "OWNER7")
+	require.NoError(t, err)
+
+	exists, err := s.OwnerExists(ctx, "OWNER7")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "ana@example.com", 0), "")
+	require.NoError(t, err)
+}
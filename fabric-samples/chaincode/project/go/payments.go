@@ -0,0 +1,344 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const paymentObjectType = "payment"
+
+// Reasons of payments to owners
+const (
+	PaymentSale    = "SALE"
+	PaymentRefund  = "REFUND"
+	PaymentRent    = "RENT"
+	PaymentDeposit = "DEPOSIT"
+)
+
+// Payment is money held for an owner in world state, such as the proceeds of
+// a sale. Only the peers of the owner's organization can read and change the
+// owner's balance, so a transaction that the peers of other organizations
+// endorse pays the owner with a payment instead, which the owner adds to
+// their balance with CollectPayments. Such a transaction spends the
+// payments of an owner in turn, which the owner makes with DepositFunds.
+type Payment struct {
+	DocType string `json:"docType"`
+	Owner   string `json:"owner"`
+	TxId    string `json:"txId"`
+	Reason  string `json:"reason"`
+	Amount  Amount `json:"amount"`
+}
+
+// GetPayments returns the payments the owner stored under ownerId has not
+// collected yet
+func (s *SmartContract) GetPayments(ctx contractapi.TransactionContextInterface, ownerId string) ([]*Payment, error) {
+	record, err := s.GetOwnerRecord(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pendingPayments(ctx, strconv.Itoa(record.Id))
+}
+
+// CollectPayments adds the pending payments of the owner stored under
+// ownerId to the owner's balance. It is called by the owner.
+func (s *SmartContract) CollectPayments(ctx contractapi.TransactionContextInterface, ownerId string) (*Owner, error) {
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := pendingPayments(ctx, strconv.Itoa(owner.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payments) == 0 {
+		return owner, nil
+	}
+
+	for _, payment := range payments {
+		owner.Money, err = owner.Money.Add(payment.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		err = deletePayment(ctx, payment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+// DepositFunds moves the amount from the balance of the owner stored under
+// ownerId into a payment, with which the owner pays in transactions that the
+// peers of other organizations endorse, such as buying a car of another
+// organization. CollectPayments takes back what is left of it. It is called
+// by the owner.
+func (s *SmartContract) DepositFunds(ctx contractapi.TransactionContextInterface, ownerId string, amount int64) (*Owner, error) {
+	owner, err := s.getOwner(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 {
+		return nil, newError(CodeInvalidArgument, "amount to deposit must be positive")
+	}
+
+	deposit := NewAmount(amount, owner.Money.Currency)
+	insufficient, err := owner.Money.LessThan(deposit)
+	if err != nil {
+		return nil, err
+	}
+
+	if insufficient {
+		return nil, newError(CodeInsufficientFunds, "owner does not have enough money to deposit %s", deposit.String()).
+			with("ownerId", ownerId)
+	}
+
+	owner.Money, err = owner.Money.Sub(deposit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.payOwner(ctx, strconv.Itoa(owner.Id), deposit, PaymentDeposit)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+// payOwner writes a payment of the amount to the owner with the given
+// numeric id into world state, where the peers of any organization can
+// endorse it. A transaction pays an owner at most once for each reason.
+func (s *SmartContract) payOwner(ctx contractapi.TransactionContextInterface, ownerId string, amount Amount, reason string) error {
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	_, err := s.GetOwnerRecord(ctx, ownerKey(ownerId))
+	if err != nil {
+		return err
+	}
+
+	return putPayment(ctx, &Payment{
+		DocType: paymentObjectType,
+		Owner:   ownerId,
+		TxId:    ctx.GetStub().GetTxID(),
+		Reason:  reason,
+		Amount:  amount,
+	})
+}
+
+// ownerFunds is the money an owner pays with in a transaction. It is the
+// owner's balance when only the peers of the owner's organization endorse
+// the transaction, and the owner's payments otherwise.
+type ownerFunds struct {
+	ownerId  string
+	owner    *Owner
+	payments []*Payment
+}
+
+// fundsOf returns the funds of the owner with the given numeric id in a
+// transaction that the peers of the given organizations endorse. No
+// organizations stand for the endorsement policy of the chaincode, which
+// lets the peers of the owner's organization endorse alone.
+func (s *SmartContract) fundsOf(ctx contractapi.TransactionContextInterface, ownerId string, endorsingOrgs []string) (*ownerFunds, error) {
+	record, err := s.GetOwnerRecord(ctx, ownerKey(ownerId))
+	if err != nil {
+		return nil, err
+	}
+
+	funds := &ownerFunds{ownerId: ownerId}
+	for _, mspID := range endorsingOrgs {
+		if mspID != record.MSPID {
+			funds.payments, err = pendingPayments(ctx, ownerId)
+			if err != nil {
+				return nil, err
+			}
+
+			return funds, nil
+		}
+	}
+
+	funds.owner, err = readOwner(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return funds, nil
+}
+
+// covers tells whether the funds hold at least the amount
+func (f *ownerFunds) covers(amount Amount) (bool, error) {
+	if f.owner != nil {
+		insufficient, err := f.owner.Money.LessThan(amount)
+		return !insufficient, err
+	}
+
+	total := NewAmount(0, amount.Currency)
+	for _, payment := range f.payments {
+		if payment.Amount.checkCurrency(amount) != nil {
+			continue
+		}
+
+		var err error
+		total, err = total.Add(payment.Amount)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	insufficient, err := total.LessThan(amount)
+	return !insufficient, err
+}
+
+// spend takes the amount out of the funds. Payments are spent oldest key
+// first, and what is left of the last one stays with the owner.
+func (f *ownerFunds) spend(ctx contractapi.TransactionContextInterface, amount Amount) error {
+	if f.owner != nil {
+		var err error
+		f.owner.Money, err = f.owner.Money.Sub(amount)
+		if err != nil {
+			return err
+		}
+
+		return putOwner(ctx, f.owner)
+	}
+
+	for _, payment := range f.payments {
+		if !amount.IsPositive() {
+			break
+		}
+		if payment.Amount.checkCurrency(amount) != nil || !payment.Amount.IsPositive() {
+			continue
+		}
+
+		spent := payment.Amount
+		partly, err := amount.LessThan(payment.Amount)
+		if err != nil {
+			return err
+		}
+		if partly {
+			spent = amount
+		}
+
+		amount, err = amount.Sub(spent)
+		if err != nil {
+			return err
+		}
+
+		payment.Amount, err = payment.Amount.Sub(spent)
+		if err != nil {
+			return err
+		}
+
+		if payment.Amount.IsPositive() {
+			err = putPayment(ctx, payment)
+		} else {
+			err = deletePayment(ctx, payment)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if amount.IsPositive() {
+		return newError(CodeInsufficientFunds, "payments of owner %s do not cover %s", ownerKey(f.ownerId), amount.String())
+	}
+
+	return nil
+}
+
+// pendingPayments returns the payments to the owner with the given numeric
+// id that it did not collect
+func pendingPayments(ctx contractapi.TransactionContextInterface, ownerId string) ([]*Payment, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(paymentObjectType, []string{ownerId})
+	if err != nil {
+		return nil, errLedger("failed to read payments from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	payments := make([]*Payment, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var payment Payment
+		err = json.Unmarshal(queryResponse.Value, &payment)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, &payment)
+	}
+
+	return payments, nil
+}
+
+func putPayment(ctx contractapi.TransactionContextInterface, payment *Payment) error {
+	key, err := paymentKey(ctx, payment.Owner, payment.TxId, payment.Reason)
+	if err != nil {
+		return err
+	}
+
+	paymentAsBytes, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, paymentAsBytes)
+	if err != nil {
+		return errLedger("failed to put payment %s to world state: %v", key, err)
+	}
+
+	return nil
+}
+
+func deletePayment(ctx contractapi.TransactionContextInterface, payment *Payment) error {
+	key, err := paymentKey(ctx, payment.Owner, payment.TxId, payment.Reason)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return errLedger("failed to delete payment %s from world state: %v", key, err)
+	}
+
+	return nil
+}
+
+func paymentKey(ctx contractapi.TransactionContextInterface, ownerId string, txId string, reason string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(paymentObjectType, []string{ownerId, txId, reason})
+}
@@ -0,0 +1,402 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// legacyOwnerCollection is the collection shared by every organization
+	// that held all owners before each organization kept its own, from which
+	// MigrateOwnerPrivateData moves them
+	legacyOwnerCollection = "ownerPrivateCollection"
+
+	// ownerTransientKey is the transient field that carries the personal
+	// details of an owner into CreateOwner and UpdateOwner, so that they are
+	// not recorded in the transaction
+	ownerTransientKey = "owner"
+
+	// saltTransientKey is the transient field that carries the random salt of
+	// the hash of an owner's details. Without it the hash in world state
+	// would tell a guessed name and email apart from a wrong one.
+	saltTransientKey = "salt"
+
+	// minSaltLength is the number of random bytes a salt needs at least
+	minSaltLength = 16
+)
+
+// ownerCollection returns the collection, configured in
+// collections_config.json, that holds the personal details and balances of
+// the owners of the organization with the given MSP ID. Only the peers of
+// that organization are members of it and endorse every write to it, so
+// other organizations can neither read its owners nor change their balances.
+// Money that moves between organizations goes through payments in world
+// state instead, see Payment.
+func ownerCollection(mspID string) string {
+	return mspID + "OwnerCollection"
+}
+
+// OwnerRecord is the public part of an owner kept in world state. Only
+// clients of the organization in MSPID can read the private details, kept in
+// Collection. Hash is the SHA-256 hash of the stored details, which include
+// the owner's random salt. ClientIdHash is the SHA-256 hash of the client
+// identity bound to the owner, by which the peers of other organizations
+// tell that the owner submitted a transaction.
+type OwnerRecord struct {
+	DocType      string `json:"docType"`
+	Id           int    `json:"id"`
+	MSPID        string `json:"mspId"`
+	Collection   string `json:"collection"`
+	Hash         string `json:"hash"`
+	ClientIdHash string `json:"clientIdHash,omitempty"`
+}
+
+// ownerDetails is the transient input of CreateOwner and UpdateOwner. Money
+// and currency are only used when an owner is created.
+type ownerDetails struct {
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+	Email    string `json:"email"`
+	Money    int64  `json:"money"`
+	Currency string `json:"currency"`
+}
+
+// GetOwnerRecord returns the public part of the owner stored under ownerId,
+// which any client can read
+func (s *SmartContract) GetOwnerRecord(ctx contractapi.TransactionContextInterface, ownerId string) (*OwnerRecord, error) {
+	recordAsBytes, err := ctx.GetStub().GetState(ownerId)
+	if err != nil {
+		return nil, errLedger("failed to read from world state. %s", err.Error())
+	}
+
+	if recordAsBytes == nil {
+		return nil, newError(CodeOwnerNotFound, "%s does not exist", ownerId).with("ownerId", ownerId)
+	}
+
+	record := new(OwnerRecord)
+	err = json.Unmarshal(recordAsBytes, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// MigrateOwnerPrivateData moves the personal details and balances of owners
+// written to world state, or to the collection shared by every organization,
+// before each organization kept its owners in its own collection. Owners
+// that were not bound to an organization join the administrator's. The salts
+// of the owners' hashes are derived from the random salt in the "salt" field
+// of the transient map. It returns the number of migrated owners and can only
+// be called by identities carrying the cars.admin attribute.
+func (s *SmartContract) MigrateOwnerPrivateData(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return 0, newError(CodeUnauthorized, "submitting client not authorized to migrate owners, does not have %s role", adminAttribute)
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	salt, err := readSalt(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(ownerKeyPrefix, ownerKeyPrefix+"~")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		var record OwnerRecord
+		err = json.Unmarshal(queryResponse.Value, &record)
+		if err != nil {
+			return 0, err
+		}
+
+		if record.Collection != "" {
+			continue
+		}
+
+		// owners with a hash already moved to the shared collection
+		shared := record.Hash != ""
+		ownerAsBytes := queryResponse.Value
+		if shared {
+			ownerAsBytes, err = ctx.GetStub().GetPrivateData(legacyOwnerCollection, queryResponse.Key)
+			if err != nil {
+				return 0, errLedger("failed to read owner %s from private data collection: %v", queryResponse.Key, err)
+			}
+		}
+
+		var owner Owner
+		err = json.Unmarshal(ownerAsBytes, &owner)
+		if err != nil {
+			return 0, newError(CodeInternal, "failed to migrate %s: %v", queryResponse.Key, err)
+		}
+
+		if owner.MSPID == "" {
+			owner.MSPID = mspID
+		}
+
+		if owner.Salt == "" {
+			owner.Salt = deriveSalt(salt, queryResponse.Key)
+		}
+
+		err = putOwner(ctx, &owner)
+		if err != nil {
+			return 0, err
+		}
+
+		if shared {
+			err = ctx.GetStub().DelPrivateData(legacyOwnerCollection, queryResponse.Key)
+			if err != nil {
+				return 0, errLedger("failed to delete owner %s from private data collection: %v", queryResponse.Key, err)
+			}
+		}
+
+		if owner.Email != "" {
+			err = deleteLegacyEmailIndex(ctx, &owner, shared)
+			if err != nil {
+				return 0, err
+			}
+
+			err = putEmailIndex(ctx, &owner)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// getOwner reads the owner stored under ownerId from the collection of its
+// organization, which only the clients of that organization can read. Any
+// further check of who may act as the owner is up to the caller.
+func (s *SmartContract) getOwner(ctx contractapi.TransactionContextInterface, ownerId string) (*Owner, error) {
+	record, err := s.GetOwnerRecord(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeOwnerRead(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return readOwner(ctx, record)
+}
+
+// readOwner reads the private details of the owner with the public record
+// from its collection. Only the peers of the owner's organization hold them.
+func readOwner(ctx contractapi.TransactionContextInterface, record *OwnerRecord) (*Owner, error) {
+	ownerId := ownerKey(strconv.Itoa(record.Id))
+	ownerAsBytes, err := ctx.GetStub().GetPrivateData(record.Collection, ownerId)
+	if err != nil {
+		return nil, errLedger("failed to read owner %s from private data collection: %v", ownerId, err)
+	}
+
+	if ownerAsBytes == nil {
+		return nil, newError(CodeOwnerNotFound, "private details of owner %s are not available", ownerId)
+	}
+
+	owner := new(Owner)
+	err = json.Unmarshal(ownerAsBytes, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+// authorizeOwnerRead makes sure that the submitting client belongs to the
+// organization of the owner, whose collection its peers can read
+func authorizeOwnerRead(ctx contractapi.TransactionContextInterface, record *OwnerRecord) error {
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if record.Collection == "" || mspID != record.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not authorized to read owner %s", ownerKey(strconv.Itoa(record.Id)))
+	}
+
+	return nil
+}
+
+// readOwnerDetails returns the owner details passed in the transient map
+func readOwnerDetails(ctx contractapi.TransactionContextInterface) (*ownerDetails, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, errLedger("error getting transient: %v", err)
+	}
+
+	detailsAsBytes, ok := transientMap[ownerTransientKey]
+	if !ok {
+		return nil, newError(CodeInvalidArgument, "%s must be a key in the transient map", ownerTransientKey)
+	}
+
+	details := new(ownerDetails)
+	err = json.Unmarshal(detailsAsBytes, details)
+	if err != nil {
+		return nil, newError(CodeInvalidArgument, "failed to unmarshal owner details: %v", err)
+	}
+
+	return details, nil
+}
+
+// readSalt returns the random salt passed in the transient map
+func readSalt(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, errLedger("error getting transient: %v", err)
+	}
+
+	salt, ok := transientMap[saltTransientKey]
+	if !ok {
+		return nil, newError(CodeInvalidArgument, "%s must be a key in the transient map", saltTransientKey)
+	}
+
+	if len(salt) < minSaltLength {
+		return nil, newError(CodeInvalidArgument, "salt must have at least %d random bytes", minSaltLength)
+	}
+
+	return salt, nil
+}
+
+// deriveSalt returns a salt of its own for the owner stored under ownerId,
+// for transactions that store many owners with a single random salt
+func deriveSalt(salt []byte, ownerId string) string {
+	hash := sha256.Sum256(append(append([]byte{}, salt...), ownerId...))
+	return hex.EncodeToString(hash[:])
+}
+
+// putOwner stores the owner in the collection of its organization and its
+// public record, with the hash of the salted private details, in world state
+func putOwner(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	key := ownerKey(strconv.Itoa(owner.Id))
+	if owner.Salt == "" {
+		return newError(CodeInternal, "owner %s has no salt to hash its details with", key)
+	}
+
+	owner.DocType = ownerDocType
+	ownerAsBytes, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+
+	collection := ownerCollection(owner.MSPID)
+	err = ctx.GetStub().PutPrivateData(collection, key, ownerAsBytes)
+	if err != nil {
+		return errLedger("failed to put owner %s to private data collection: %v", key, err)
+	}
+
+	hash := sha256.Sum256(ownerAsBytes)
+	record := OwnerRecord{
+		DocType:    ownerDocType,
+		Id:         owner.Id,
+		MSPID:      owner.MSPID,
+		Collection: collection,
+		Hash:       hex.EncodeToString(hash[:]),
+	}
+	if owner.ClientId != "" {
+		record.ClientIdHash = clientIdHash(owner.ClientId)
+	}
+
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, recordAsBytes)
+	if err != nil {
+		return errLedger("failed to put to world state. %v", err)
+	}
+
+	return nil
+}
+
+// clientIdHash returns the hex encoded SHA-256 hash of a client identity
+func clientIdHash(clientId string) string {
+	hash := sha256.Sum256([]byte(clientId))
+	return hex.EncodeToString(hash[:])
+}
+
+// deleteOwner removes the owner from world state and its collection
+func deleteOwner(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	key := ownerKey(strconv.Itoa(owner.Id))
+	err := ctx.GetStub().DelPrivateData(ownerCollection(owner.MSPID), key)
+	if err != nil {
+		return errLedger("failed to delete owner %s from private data collection: %v", key, err)
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// The email index lives in the collection of the owner's organization as
+// well, with one entry per address that holds the id of the owner using it.
+// Other organizations tell that an address is taken by the hash of its
+// entry.
+func emailIndexKey(ctx contractapi.TransactionContextInterface, email string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(emailIndex, []string{strings.ToLower(email)})
+}
+
+func putEmailIndex(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	key, err := emailIndexKey(ctx, owner.Email)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(ownerCollection(owner.MSPID), key, []byte(strconv.Itoa(owner.Id)))
+}
+
+func deleteEmailIndex(ctx contractapi.TransactionContextInterface, owner *Owner) error {
+	key, err := emailIndexKey(ctx, owner.Email)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelPrivateData(ownerCollection(owner.MSPID), key)
+}
+
+// deleteLegacyEmailIndex removes the entry of the owner's email that was
+// kept in the shared collection or, before owners were kept in private
+// collections, the email~id entry in world state
+func deleteLegacyEmailIndex(ctx contractapi.TransactionContextInterface, owner *Owner, shared bool) error {
+	if shared {
+		key, err := emailIndexKey(ctx, owner.Email)
+		if err != nil {
+			return err
+		}
+
+		return ctx.GetStub().DelPrivateData(legacyOwnerCollection, key)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(emailIndex, []string{strings.ToLower(owner.Email), strconv.Itoa(owner.Id)})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
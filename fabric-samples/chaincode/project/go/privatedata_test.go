@@ -0,0 +1,228 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerPrivateData(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "ana@example.com", 0), "")
+	require.NoError(t, err)
+
+	// world state only holds the id, organization and hash of the owner
+	recordAsBytes, err := ctx.stub.GetState("OWNER4")
+	require.NoError(t, err)
+	require.NotContains(t, string(recordAsBytes), "ana@example.com")
+
+	ownerAsBytes, err := ctx.stub.GetPrivateData(ownerCollection("Org1MSP"), "OWNER4")
+	require.NoError(t, err)
+	hash := sha256.Sum256(ownerAsBytes)
+
+	record, err := s.GetOwnerRecord(ctx.as("x509::CN=User2", "Org2MSP", nil), "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(hash[:]), record.Hash)
+	require.Equal(t, "Org1MSP", record.MSPID)
+
+	_, err = s.GetOwnerById(ctx, "OWNER4")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to read owner OWNER4")
+
+	owners, err := s.GetAllOwners(ctx)
+	require.NoError(t, err)
+	require.Empty(t, owners)
+
+	owner, err := s.GetOwnerById(ctx.as("x509::CN=User3", "Org1MSP", nil), "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(0, "EUR"), owner.Money)
+
+	ctx.stub.transient = nil
+	_, err = s.CreateOwner(ctx, "")
+	requireError(t, err, CodeInvalidArgument, "owner must be a key in the transient map")
+
+	// the hash covers the salt, which changes with the details
+	ctx.withOwnerDetails("Ana", "Anic", "ana@example.com", 0)
+	ctx.stub.transient[saltTransientKey] = []byte("short")
+	_, err = s.UpdateOwner(ctx, "OWNER4")
+	requireError(t, err, CodeInvalidArgument, "salt must have at least 16 random bytes")
+
+	delete(ctx.stub.transient, saltTransientKey)
+	_, err = s.UpdateOwner(ctx, "OWNER4")
+	requireError(t, err, CodeInvalidArgument, "salt must be a key in the transient map")
+
+	_, err = s.UpdateOwner(ctx.as("x509::CN=User1", "Org1MSP", nil).withOwnerDetails("Ana", "Anic", "ana@example.com", 0), "OWNER4")
+	require.NoError(t, err)
+
+	updated, err := s.GetOwnerRecord(ctx, "OWNER4")
+	require.NoError(t, err)
+	require.NotEqual(t, record.Hash, updated.Hash)
+	require.NotEmpty(t, owner.Salt)
+}
+
+func TestInitLedgerWithoutSalt(t *testing.T) {
+	ctx := &testContext{
+		stub: &testStub{MockStub: shimtest.NewMockStub("cars", nil)},
+	}
+	ctx.stub.MockTransactionStart("init")
+	ctx.as("x509::CN=Admin@org1.example.com", "Org1MSP", nil)
+
+	s := SmartContract{}
+	err := s.InitLedger(ctx)
+	require.NoError(t, err)
+
+	owner, err := s.GetOwnerById(ctx, "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, deriveSalt([]byte("init"), "OWNER1"), owner.Salt)
+
+	other, err := s.GetOwnerById(ctx, "OWNER2")
+	require.NoError(t, err)
+	require.NotEqual(t, owner.Salt, other.Salt)
+}
+
+func TestOwnersOfOtherOrganizations(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	admin := map[string]string{adminAttribute: "true"}
+
+	// the address of an owner of Org1 is taken although Org2 can not read it
+	_, err := s.CreateOwner(ctx.as("x509::CN=buyer", "Org2MSP", admin).withOwnerDetails("Ana", "Anic", "sarapoparic@gmail.com", 1000000), "")
+	requireError(t, err, CodeAlreadyExists, "email sarapoparic@gmail.com is already used by an owner of Org1MSP")
+
+	_, err = s.CreateOwner(ctx.withOwnerDetails("Ana", "Anic", "ana@example.com", 1000000), "")
+	require.NoError(t, err)
+
+	record, err := s.GetOwnerRecord(ctx, "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, "Org2MSP", record.MSPID)
+	require.Equal(t, "Org2MSPOwnerCollection", record.Collection)
+
+	ownerAsBytes, err := ctx.stub.GetPrivateData(ownerCollection("Org1MSP"), "OWNER4")
+	require.NoError(t, err)
+	require.Nil(t, ownerAsBytes)
+
+	_, err = s.GetOwnerById(ctx.asOwner(1), "OWNER4")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to read owner OWNER4")
+
+	_, err = s.UpdateOwner(ctx.withOwnerDetails("Sara", "Poparic", "ana@example.com", 0), "OWNER1")
+	requireError(t, err, CodeAlreadyExists, "email ana@example.com is already used by an owner of Org2MSP")
+
+	owners, err := s.GetAllOwners(ctx.as("x509::CN=User2", "Org2MSP", nil))
+	require.NoError(t, err)
+	require.Len(t, owners, 1)
+
+	// owners stay with the organization that holds them
+	err = s.BindOwnerIdentity(ctx.as("x509::CN=admin", "Org1MSP", admin), "OWNER1", "x509::CN=buyer", "Org2MSP")
+	requireError(t, err, CodeInvalidArgument, "owner OWNER1 belongs to Org1MSP and can not be bound to an identity of Org2MSP")
+
+	err = s.BindOwnerIdentity(ctx.as("x509::CN=admin", "Org2MSP", admin), "OWNER1", "x509::CN=buyer", "Org2MSP")
+	requireError(t, err, CodeUnauthorized, "submitting client is not authorized to read owner OWNER1")
+
+	// the peers of Org2 can not read the owners of Org1
+	ctx.stub.peerMSP = "Org2MSP"
+	_, err = s.GetOwnerById(ctx.asOwner(1), "OWNER1")
+	requireError(t, err, CodeLedger, "failed to read owner OWNER1 from private data collection: private data matching public hash version is not available")
+	ctx.stub.peerMSP = ""
+
+	// so the buyer of Org2 pays the seller of Org1 with deposited funds, and
+	// the seller collects the payment
+	err = s.ApproveTransfer(ctx.asOwner(2), "4", "OWNER4")
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("deposit1")
+	_, err = s.DepositFunds(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4", 500000)
+	require.NoError(t, err)
+
+	transfer := func() error {
+		return s.TransferOwnership(ctx.as("x509::CN=buyer", "Org2MSP", nil), "4", "OWNER4", false)
+	}
+
+	ctx.stub.MockTransactionStart("sale1")
+	err = ctx.endorsed(t, transfer, "Org1MSP", "Org2MSP")
+	requireError(t, err, CodeInsufficientFunds, "new owner does not have enough money to buy this car")
+
+	ctx.stub.MockTransactionStart("deposit2")
+	_, err = s.DepositFunds(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4", 1000000)
+	requireError(t, err, CodeInsufficientFunds, "owner does not have enough money to deposit 10000.00 EUR")
+
+	_, err = s.DepositFunds(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4", 300000)
+	require.NoError(t, err)
+
+	ctx.stub.MockTransactionStart("sale1")
+	err = ctx.endorsed(t, transfer, "Org1MSP", "Org2MSP")
+	require.NoError(t, err)
+
+	// the first deposit is spent and the rest of the second one is left
+	payments, err := s.GetPayments(ctx, "OWNER4")
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, "deposit2", payments[0].TxId)
+	require.Equal(t, NewAmount(100000, "EUR"), payments[0].Amount)
+
+	buyer, err := s.CollectPayments(ctx.as("x509::CN=buyer", "Org2MSP", nil), "OWNER4")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(300000, "EUR"), buyer.Money)
+
+	paymentKey, err := ctx.stub.CreateCompositeKey(paymentObjectType, []string{"2", "sale1", PaymentSale})
+	require.NoError(t, err)
+	paymentAsBytes, err := ctx.stub.GetState(paymentKey)
+	require.NoError(t, err)
+	require.NotNil(t, paymentAsBytes)
+
+	err = s.DeleteOwner(ctx.asOwner(2), "OWNER2")
+	requireError(t, err, CodeInvalidState, "owner OWNER2 has payments to collect and can not be deleted")
+
+	seller, err := s.CollectPayments(ctx.asOwner(2), "OWNER2")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1200000, "EUR"), seller.Money)
+
+	err = s.DeleteOwner(ctx.asOwner(2), "OWNER2")
+	requireError(t, err, CodeInvalidState, "owner OWNER2 still owns cars and can not be deleted")
+}
+
+func TestMigrateOwnersFromSharedCollection(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	ctx.stub.MockTransactionStart("legacy")
+	ownerAsBytes := []byte(`{"docType":"owner","id":9,"name":"Ana","email":"ana@example.com","money":{"value":1000,"currency":"EUR"},"clientId":"x509::CN=ana","mspId":"Org2MSP"}`)
+	require.NoError(t, ctx.stub.PutState("OWNER9", []byte(`{"docType":"owner","id":9,"mspId":"Org2MSP","hash":"legacy"}`)))
+	require.NoError(t, ctx.stub.PutPrivateData(legacyOwnerCollection, "OWNER9", ownerAsBytes))
+	emailKey, err := emailIndexKey(ctx, "ana@example.com")
+	require.NoError(t, err)
+	require.NoError(t, ctx.stub.PutPrivateData(legacyOwnerCollection, emailKey, []byte("9")))
+
+	migrated, err := s.MigrateOwnerPrivateData(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}).withSalt())
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	record, err := s.GetOwnerRecord(ctx, "OWNER9")
+	require.NoError(t, err)
+	require.Equal(t, "Org2MSPOwnerCollection", record.Collection)
+
+	owner, err := s.GetOwnerById(ctx.as("x509::CN=ana", "Org2MSP", nil), "OWNER9")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1000, "EUR"), owner.Money)
+
+	usedBy, err := ctx.stub.GetPrivateData(ownerCollection("Org2MSP"), emailKey)
+	require.NoError(t, err)
+	require.Equal(t, "9", string(usedBy))
+
+	for _, key := range []string{"OWNER9", emailKey} {
+		legacy, err := ctx.stub.GetPrivateData(legacyOwnerCollection, key)
+		require.NoError(t, err)
+		require.Nil(t, legacy)
+	}
+
+	migrated, err = s.MigrateOwnerPrivateData(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}).withSalt())
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
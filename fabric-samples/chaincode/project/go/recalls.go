@@ -0,0 +1,371 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	recallObjectType = "recall"
+	recallCarIndex   = "recall~car"
+	carRecallIndex   = "car~recall"
+
+	// manufacturerAttribute marks identities that may issue recall campaigns
+	manufacturerAttribute = "cars.manufacturer"
+)
+
+// Recall is a campaign of a manufacturer to fix a defect of a model. Every
+// matching car gets a notice of the recall, which its owner turns into a
+// malfunction with ApplyRecall for repair shops to fix at no cost.
+type Recall struct {
+	DocType     string    `json:"docType"`
+	Id          string    `json:"id"`
+	Make        string    `json:"make"`
+	Model       string    `json:"model"`
+	YearFrom    int       `json:"yearFrom,omitempty"`
+	YearTo      int       `json:"yearTo,omitempty"`
+	Description string    `json:"description"`
+	Remedy      string    `json:"remedy"`
+	Severity    string    `json:"severity"`
+	MSPID       string    `json:"mspId"`
+	IssuedAt    time.Time `json:"issuedAt"`
+}
+
+// IssueRecall starts a recall campaign for the cars of the make and model
+// built from yearFrom to yearTo. A year of 0 leaves that end of the range
+// open. Each matching car gets a notice of the recall under the recall~car
+// and car~recall keys, rather than a change of the car itself, which only the
+// organizations of its owners could endorse. Only manufacturers can issue
+// recalls.
+func (s *SmartContract) IssueRecall(ctx contractapi.TransactionContextInterface, make string, model string, yearFrom int, yearTo int, description string, remedy string, severity string) (*Recall, error) {
+	err := ctx.GetClientIdentity().AssertAttributeValue(manufacturerAttribute, "true")
+	if err != nil {
+		return nil, newError(CodeUnauthorized, "submitting client not authorized, does not have %s role", manufacturerAttribute)
+	}
+
+	if make == "" || model == "" {
+		return nil, newError(CodeInvalidArgument, "make and model of recalled cars must not be empty")
+	}
+
+	if yearFrom < 0 || yearTo < 0 || (yearTo != 0 && yearTo < yearFrom) {
+		return nil, newError(CodeInvalidArgument, "years %d to %d are not a valid range", yearFrom, yearTo)
+	}
+
+	if description == "" || remedy == "" {
+		return nil, newError(CodeInvalidArgument, "description and remedy of recall must not be empty")
+	}
+
+	err = checkSeverity(severity)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	issuedAt, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recall := Recall{
+		Id:          ctx.GetStub().GetTxID(),
+		Make:        make,
+		Model:       model,
+		YearFrom:    yearFrom,
+		YearTo:      yearTo,
+		Description: description,
+		Remedy:      remedy,
+		Severity:    severity,
+		MSPID:       mspID,
+		IssuedAt:    issuedAt,
+	}
+
+	// the make~model~id index leaves scrapped cars out
+	cars, err := s.carsByIndex(ctx, makeModelIndex, strings.ToLower(make), strings.ToLower(model))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, car := range cars {
+		if !recall.matches(car) {
+			continue
+		}
+
+		err = putRecallNotice(ctx, recall.Id, strconv.Itoa(car.Id))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = putRecall(ctx, &recall)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recall, nil
+}
+
+// GetRecall returns the recall campaign with given id
+func (s *SmartContract) GetRecall(ctx contractapi.TransactionContextInterface, recallId string) (*Recall, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(recallObjectType, []string{recallId})
+	if err != nil {
+		return nil, err
+	}
+
+	recallAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if recallAsBytes == nil {
+		return nil, newError(CodeNotFound, "recall %s does not exist", recallId)
+	}
+
+	recall := new(Recall)
+	err = json.Unmarshal(recallAsBytes, recall)
+	if err != nil {
+		return nil, err
+	}
+
+	return recall, nil
+}
+
+// ApplyRecall lets the owner of the car turn the notice of the recall into
+// an open malfunction of the car, with the severity of the recall and no
+// price, which a repair shop then fixes like any other malfunction
+func (s *SmartContract) ApplyRecall(ctx contractapi.TransactionContextInterface, carId string, recallId string) (*Malfunction, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCarStatus(car, inServiceStatuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	recall, err := s.GetRecall(ctx, recallId)
+	if err != nil {
+		return nil, err
+	}
+
+	noticed, err := recallNoticed(ctx, recallId, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !noticed {
+		return nil, newError(CodeNotFound, "car %s is not recalled by recall %s", carId, recallId)
+	}
+
+	if recallMalfunction(car, recallId) != nil {
+		return nil, newError(CodeAlreadyExists, "recall %s is already applied to car %s", recallId, carId)
+	}
+
+	malfunction, err := newMalfunction(ctx, car, recall.Description, NewAmount(0, car.Price.Currency), recall.Severity)
+	if err != nil {
+		return nil, err
+	}
+	malfunction.RecallId = recall.Id
+	car.Malfunctions = append(car.Malfunctions, malfunction)
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	return &malfunction, nil
+}
+
+// GetOpenRecallsForCar returns the recalls the car is not repaired for yet,
+// whether or not they are applied to the car
+func (s *SmartContract) GetOpenRecallsForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*Recall, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(carRecallIndex, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	var recallIds []string
+	for resultIter.HasNext() {
+		responseRange, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		recallIds = append(recallIds, compositeKeyParts[1])
+	}
+
+	// recalls issued before the notices were kept apart from the car are
+	// only known from its malfunctions
+	for _, malfunction := range car.Malfunctions {
+		if malfunction.RecallId != "" && !containsKey(recallIds, malfunction.RecallId) {
+			recallIds = append(recallIds, malfunction.RecallId)
+		}
+	}
+
+	recalls := make([]*Recall, 0)
+	for _, recallId := range recallIds {
+		if recallRepaired(car, recallId) {
+			continue
+		}
+
+		recall, err := s.GetRecall(ctx, recallId)
+		if err != nil {
+			return nil, err
+		}
+		recalls = append(recalls, recall)
+	}
+
+	return recalls, nil
+}
+
+// GetOpenCarsForRecall returns the cars of the recall campaign that are not
+// repaired for it yet
+func (s *SmartContract) GetOpenCarsForRecall(ctx contractapi.TransactionContextInterface, recallId string) ([]*Car, error) {
+	_, err := s.GetRecall(ctx, recallId)
+	if err != nil {
+		return nil, err
+	}
+
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(recallCarIndex, []string{recallId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	cars := make([]*Car, 0)
+	for resultIter.HasNext() {
+		responseRange, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		car, err := s.GetCarById(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// cars archived as scrapped since the recall was issued are not open
+		if carStatus(car) == CarScrapped || recallRepaired(car, recallId) {
+			continue
+		}
+
+		cars = append(cars, car)
+	}
+
+	return cars, nil
+}
+
+// matches tells whether the car is of the recalled make, model and years
+func (r *Recall) matches(car *Car) bool {
+	if !strings.EqualFold(car.Make, r.Make) || !strings.EqualFold(car.Model, r.Model) {
+		return false
+	}
+
+	if r.YearFrom != 0 && car.Year < r.YearFrom {
+		return false
+	}
+
+	return r.YearTo == 0 || car.Year <= r.YearTo
+}
+
+// recallMalfunction returns the malfunction of the car for the recall, or nil
+// if the recall is not applied to the car
+func recallMalfunction(car *Car, recallId string) *Malfunction {
+	for i := range car.Malfunctions {
+		if car.Malfunctions[i].RecallId == recallId {
+			return &car.Malfunctions[i]
+		}
+	}
+
+	return nil
+}
+
+// recallRepaired tells whether the car is repaired for the recall
+func recallRepaired(car *Car, recallId string) bool {
+	malfunction := recallMalfunction(car, recallId)
+	return malfunction != nil && malfunction.Status == MalfunctionRepaired
+}
+
+// recallNoticed tells whether the recall has a notice for the car
+func recallNoticed(ctx contractapi.TransactionContextInterface, recallId string, carId string) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(recallCarIndex, []string{recallId, carId})
+	if err != nil {
+		return false, err
+	}
+
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, errLedger("failed to read from world state: %v", err)
+	}
+
+	return value != nil, nil
+}
+
+// putRecallNotice writes the notice of the recall for the car under the
+// recall~car and the car~recall key, so that it can be looked up from either
+func putRecallNotice(ctx contractapi.TransactionContextInterface, recallId string, carId string) error {
+	recallCarKey, err := ctx.GetStub().CreateCompositeKey(recallCarIndex, []string{recallId, carId})
+	if err != nil {
+		return err
+	}
+
+	carRecallKey, err := ctx.GetStub().CreateCompositeKey(carRecallIndex, []string{carId, recallId})
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	err = ctx.GetStub().PutState(recallCarKey, value)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(carRecallKey, value)
+}
+
+func putRecall(ctx contractapi.TransactionContextInterface, recall *Recall) error {
+	recall.DocType = recallObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(recallObjectType, []string{recall.Id})
+	if err != nil {
+		return err
+	}
+
+	recallAsBytes, err := json.Marshal(recall)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, recallAsBytes)
+}
@@ -0,0 +1,107 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecalls(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	_, err := s.IssueRecall(ctx, "Ford", "Mustang", 2015, 2018, "Airbag inflator may rupture", "Replace inflator", SeverityCritical)
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized, does not have cars.manufacturer role")
+
+	mustang, err := s.RegisterCar(ctx.asOwner(1), "", "1M8GDM9AXKP042788", "Ford", "Mustang", 2016, 40000, "red", 2500000, "OWNER1")
+	require.NoError(t, err)
+
+	ctx.as("x509::CN=ford", "Org2MSP", map[string]string{manufacturerAttribute: "true"})
+	_, err = s.IssueRecall(ctx, "Ford", "Mustang", 2018, 2015, "Airbag inflator may rupture", "Replace inflator", SeverityCritical)
+	requireError(t, err, CodeInvalidArgument, "years 2018 to 2015 are not a valid range")
+
+	ctx.stub.MockTransactionStart("recall1")
+	recall, err := s.IssueRecall(ctx, "ford", "mustang", 2015, 0, "Airbag inflator may rupture", "Replace inflator", SeverityCritical)
+	require.NoError(t, err)
+	require.Equal(t, "recall1", recall.Id)
+
+	// the recall leaves the cars, and so their endorsement policies, alone
+	car, err := s.GetCarById(ctx, "2")
+	require.NoError(t, err)
+	require.Empty(t, car.Malfunctions)
+
+	recalls, err := s.GetOpenRecallsForCar(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, recalls, 1)
+
+	cars, err := s.GetOpenCarsForRecall(ctx, "recall1")
+	require.NoError(t, err)
+	require.Len(t, cars, 2)
+
+	// cars archived since the recall was issued are not open
+	err = s.DeleteCar(ctx.asOwner(1), strconv.Itoa(mustang.Id))
+	require.NoError(t, err)
+
+	cars, err = s.GetOpenCarsForRecall(ctx, "recall1")
+	require.NoError(t, err)
+	require.Len(t, cars, 1)
+	require.Equal(t, 2, cars[0].Id)
+
+	_, err = s.ApplyRecall(ctx.asOwner(1), "2", "recall1")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to update car 2, does not own it")
+
+	_, err = s.ApplyRecall(ctx.asOwner(3), "5", "recall1")
+	requireError(t, err, CodeNotFound, "car 5 is not recalled by recall recall1")
+
+	ctx.isolated(t, func() {
+		malfunction, err := s.ApplyRecall(ctx.asOwner(3), "2", "recall1")
+		require.NoError(t, err)
+		require.Equal(t, "recall1", malfunction.RecallId)
+	})
+
+	_, err = s.ApplyRecall(ctx.asOwner(3), "2", "recall1")
+	requireError(t, err, CodeAlreadyExists, "recall recall1 is already applied to car 2")
+
+	car, err = s.GetCarById(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, car.Malfunctions, 1)
+	require.Equal(t, "recall1", car.Malfunctions[0].RecallId)
+	require.Equal(t, SeverityCritical, car.Malfunctions[0].Severity)
+	require.Equal(t, NewAmount(0, "EUR"), car.Malfunctions[0].Price)
+
+	recalls, err = s.GetOpenRecallsForCar(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, recalls, 1)
+
+	before, err := s.GetOwnerById(ctx.asOwner(3), "OWNER3")
+	require.NoError(t, err)
+
+	_, err = s.RegisterRepairShop(ctx.asMechanic("Org2MSP"), "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.StartMalfunctionRepair(ctx.asOwner(3), "2", car.Malfunctions[0].Id, "shop1")
+	require.NoError(t, err)
+
+	ctx.isolated(t, func() {
+		err = s.RepairMalfunction(ctx.asMechanic("Org2MSP"), "2", car.Malfunctions[0].Id)
+		require.NoError(t, err)
+	})
+
+	// the recall is fixed at no cost to the owner
+	after, err := s.GetOwnerById(ctx.asOwner(3), "OWNER3")
+	require.NoError(t, err)
+	require.Equal(t, before.Money, after.Money)
+
+	recalls, err = s.GetOpenRecallsForCar(ctx, "2")
+	require.NoError(t, err)
+	require.Empty(t, recalls)
+
+	cars, err = s.GetOpenCarsForRecall(ctx, "recall1")
+	require.NoError(t, err)
+	require.Empty(t, cars)
+}
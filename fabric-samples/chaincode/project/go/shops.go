@@ -0,0 +1,225 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	shopObjectType = "shop"
+
+	// mechanicAttribute marks identities that may report and repair malfunctions
+	mechanicAttribute = "cars.mechanic"
+)
+
+// RepairShop belongs to a mechanic organization and is paid for the repairs
+// its mechanics perform
+type RepairShop struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// RegisterRepairShop adds a repair shop of the submitting client's organization.
+// Only mechanics can register shops.
+func (s *SmartContract) RegisterRepairShop(ctx contractapi.TransactionContextInterface, shopId string, name string) (*RepairShop, error) {
+	err := assertMechanic(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if shopId == "" {
+		return nil, newError(CodeInvalidArgument, "repair shop id must not be empty")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(shopObjectType, []string{shopId})
+	if err != nil {
+		return nil, err
+	}
+
+	shopAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+	if shopAsBytes != nil {
+		return nil, newError(CodeAlreadyExists, "repair shop %s already exists", shopId)
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shop := RepairShop{
+		Id:    shopId,
+		Name:  name,
+		MSPID: mspID,
+	}
+
+	err = putShop(ctx, &shop)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shop, nil
+}
+
+// GetRepairShop returns the repair shop with given id
+func (s *SmartContract) GetRepairShop(ctx contractapi.TransactionContextInterface, shopId string) (*RepairShop, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(shopObjectType, []string{shopId})
+	if err != nil {
+		return nil, err
+	}
+
+	shopAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if shopAsBytes == nil {
+		return nil, newError(CodeNotFound, "repair shop %s does not exist", shopId)
+	}
+
+	shop := new(RepairShop)
+	err = json.Unmarshal(shopAsBytes, shop)
+	if err != nil {
+		return nil, err
+	}
+
+	return shop, nil
+}
+
+// GetAllRepairShops returns all registered repair shops
+func (s *SmartContract) GetAllRepairShops(ctx contractapi.TransactionContextInterface) ([]*RepairShop, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(shopObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	shops := make([]*RepairShop, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var shop RepairShop
+		err = json.Unmarshal(queryResponse.Value, &shop)
+		if err != nil {
+			return nil, err
+		}
+		shops = append(shops, &shop)
+	}
+
+	return shops, nil
+}
+
+// SendCarToRepairShop lets the owner hand every open malfunction of the car
+// over to the chosen repair shop
+func (s *SmartContract) SendCarToRepairShop(ctx contractapi.TransactionContextInterface, carId string, shopId string) error {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return err
+	}
+
+	err = s.authorizeCarOwner(ctx, car)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.GetRepairShop(ctx, shopId)
+	if err != nil {
+		return err
+	}
+
+	err = changeCarStatus(ctx, car, CarInRepair)
+	if err != nil {
+		return err
+	}
+
+	sent := false
+	for i := range car.Malfunctions {
+		if car.Malfunctions[i].Status == MalfunctionOpen {
+			car.Malfunctions[i].Status = MalfunctionInRepair
+			car.Malfunctions[i].ShopId = shopId
+			sent = true
+		}
+	}
+
+	if !sent {
+		return newError(CodeInvalidState, "car %s has no open malfunctions", carId)
+	}
+
+	return putCar(ctx, car)
+}
+
+// assertMechanic makes sure that the submitting client has the mechanic role
+func assertMechanic(ctx contractapi.TransactionContextInterface) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(mechanicAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized, does not have %s role", mechanicAttribute)
+	}
+
+	return nil
+}
+
+// authorizeMechanic makes sure that the submitting client is a mechanic of
+// the organization the repair shop belongs to
+func authorizeMechanic(ctx contractapi.TransactionContextInterface, shop *RepairShop) error {
+	err := assertMechanic(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return err
+	}
+
+	if mspID != shop.MSPID {
+		return newError(CodeUnauthorized, "submitting client is not a mechanic of repair shop %s", shop.Id)
+	}
+
+	return nil
+}
+
+// payRepair moves the parts of the price of a repair from the funds of the
+// co-owners to the repair shop
+func payRepair(ctx contractapi.TransactionContextInterface, payers []*ownerFunds, parts []coOwnerPart, shop *RepairShop) error {
+	for i, part := range parts {
+		err := payers[i].spend(ctx, part.amount)
+		if err != nil {
+			return err
+		}
+
+		shop.Money, err = shop.Money.Add(part.amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return putShop(ctx, shop)
+}
+
+func putShop(ctx contractapi.TransactionContextInterface, shop *RepairShop) error {
+	shop.DocType = shopObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(shopObjectType, []string{shop.Id})
+	if err != nil {
+		return err
+	}
+
+	shopAsBytes, err := json.Marshal(shop)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, shopAsBytes)
+}
@@ -0,0 +1,115 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const statusIndex = "status~id"
+
+// Car statuses
+const (
+	CarActive   = "ACTIVE"
+	CarForSale  = "FOR_SALE"
+	CarInRepair = "IN_REPAIR"
+	CarStolen   = "STOLEN"
+	CarScrapped = "SCRAPPED"
+)
+
+// carTransitions lists the statuses a car can move to from each status.
+// Scrapped cars are archived and never change again.
+var carTransitions = map[string][]string{
+	CarActive:   {CarForSale, CarInRepair, CarStolen, CarScrapped},
+	CarForSale:  {CarActive, CarStolen, CarScrapped},
+	CarInRepair: {CarActive, CarStolen, CarScrapped},
+	CarStolen:   {CarActive, CarScrapped},
+	CarScrapped: {},
+}
+
+// inServiceStatuses are the statuses of cars that are neither stolen nor
+// scrapped
+var inServiceStatuses = []string{CarActive, CarForSale, CarInRepair}
+
+// GetCarsByStatus returns the cars in the given status, using the status~id
+// index
+func (s *SmartContract) GetCarsByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*Car, error) {
+	if _, ok := carTransitions[status]; !ok {
+		return nil, newError(CodeInvalidArgument, "status %s is not one of %s, %s, %s, %s or %s", status, CarActive, CarForSale, CarInRepair, CarStolen, CarScrapped)
+	}
+
+	return s.carsByIndex(ctx, statusIndex, status)
+}
+
+// carStatus returns the status of the car. Cars stored before they had a
+// status are active.
+func carStatus(car *Car) string {
+	if car.Status == "" {
+		return CarActive
+	}
+
+	return car.Status
+}
+
+// checkCarStatus makes sure that the car is in one of the allowed statuses
+func checkCarStatus(car *Car, allowed ...string) error {
+	status := carStatus(car)
+	for _, s := range allowed {
+		if status == s {
+			return nil
+		}
+	}
+
+	carId := strconv.Itoa(car.Id)
+	if status == CarStolen {
+		return newError(CodeInvalidState, "car %d is reported stolen and can not be changed until it is recovered", car.Id).
+			with("carId", carId).with("status", status)
+	}
+
+	return newError(CodeInvalidState, "car %d is %s", car.Id, status).with("carId", carId).with("status", status)
+}
+
+// changeCarStatus moves the car to the given status, if the transition is
+// allowed. Staying in the same status is always allowed. The caller stores
+// the car, which moves its status~id index entry along.
+func changeCarStatus(ctx contractapi.TransactionContextInterface, car *Car, status string) error {
+	current := carStatus(car)
+	if current == status {
+		car.Status = status
+		return nil
+	}
+
+	allowed := false
+	for _, next := range carTransitions[current] {
+		if next == status {
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return newError(CodeInvalidState, "car %d can not change from %s to %s", car.Id, current, status)
+	}
+
+	car.Status = status
+	return nil
+}
+
+// leaveRepair makes a car in repair active again once none of its
+// malfunctions are in repair any more
+func leaveRepair(ctx contractapi.TransactionContextInterface, car *Car) error {
+	if carStatus(car) != CarInRepair {
+		return nil
+	}
+
+	for _, malfunction := range car.Malfunctions {
+		if malfunction.Status == MalfunctionInRepair {
+			return nil
+		}
+	}
+
+	return changeCarStatus(ctx, car, CarActive)
+}
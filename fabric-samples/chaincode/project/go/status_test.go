@@ -0,0 +1,68 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarStatus(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+
+	cars, err := s.GetCarsByStatus(ctx, CarActive)
+	require.NoError(t, err)
+	require.Len(t, cars, 6)
+
+	err = s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	cars, err = s.GetCarsByStatus(ctx, CarForSale)
+	require.NoError(t, err)
+	require.Len(t, cars, 1)
+	require.Equal(t, 4, cars[0].Id)
+
+	err = s.AddMalfunction(ctx.asMechanic("Org2MSP"), "4", "Worn brakes", 10000, SeverityMedium)
+	require.NoError(t, err)
+
+	_, err = s.RegisterRepairShop(ctx, "shop1", "Auto Servis")
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx.asOwner(2), "4", "shop1")
+	requireError(t, err, CodeInvalidState, "car 4 can not change from FOR_SALE to IN_REPAIR")
+
+	err = s.CancelListing(ctx, "4")
+	require.NoError(t, err)
+
+	err = s.SendCarToRepairShop(ctx, "4", "shop1")
+	require.NoError(t, err)
+
+	err = s.ApproveTransfer(ctx, "4", "OWNER1")
+	requireError(t, err, CodeInvalidState, "car 4 is IN_REPAIR")
+
+	ctx.isolated(t, func() {
+		err = s.RepairCar(ctx.asMechanic("Org2MSP"), "4", "shop1")
+		require.NoError(t, err)
+	})
+
+	car, err := s.GetCarById(ctx, "4")
+	require.NoError(t, err)
+	require.Equal(t, CarActive, car.Status)
+
+	err = s.DeleteCar(ctx.asOwner(2), "4")
+	require.NoError(t, err)
+
+	err = s.ChangeCarColor(ctx, "4", "red")
+	requireError(t, err, CodeInvalidState, "car 4 is SCRAPPED")
+
+	cars, err = s.GetCarsByStatus(ctx, CarScrapped)
+	require.NoError(t, err)
+	require.Len(t, cars, 1)
+
+	_, err = s.GetCarsByStatus(ctx, "PARKED")
+	requireError(t, err, CodeInvalidArgument, "status PARKED is not one of ACTIVE, FOR_SALE, IN_REPAIR, STOLEN or SCRAPPED")
+}
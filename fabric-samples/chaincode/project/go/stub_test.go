@@ -0,0 +1,337 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdentity is a client identity that can be switched between calls
+type testIdentity struct {
+	id         string
+	mspID      string
+	attributes map[string]string
+}
+
+func (i *testIdentity) GetID() (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(i.id)), nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := i.attributes[attrName]
+	return value, found, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	if i.attributes[attrName] != attrValue {
+		return fmt.Errorf("attribute %s does not have value %s", attrName, attrValue)
+	}
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// testStub adds the transient map, deleting, hashing and querying private
+// data, which MockStub does not implement, to MockStub. While isolated,
+// writes are held back until the transaction commits, so that like on a peer
+// a transaction does not read its own writes. With peerMSP set, private data
+// can only be read from the owner collection of that organization, as on a
+// peer that is a member of no other collection.
+type testStub struct {
+	*shimtest.MockStub
+	transient map[string][]byte
+	isolated  bool
+	pending   []func() error
+	peerMSP   string
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	return s.write(func() error {
+		return s.MockStub.PutState(key, value)
+	})
+}
+
+func (s *testStub) DelState(key string) error {
+	return s.write(func() error {
+		return s.MockStub.DelState(key)
+	})
+}
+
+func (s *testStub) SetStateValidationParameter(key string, ep []byte) error {
+	return s.write(func() error {
+		return s.MockStub.SetStateValidationParameter(key, ep)
+	})
+}
+
+func (s *testStub) PutPrivateData(collection string, key string, value []byte) error {
+	return s.write(func() error {
+		return s.MockStub.PutPrivateData(collection, key, value)
+	})
+}
+
+func (s *testStub) DelPrivateData(collection string, key string) error {
+	return s.write(func() error {
+		delete(s.PvtState[collection], key)
+		return nil
+	})
+}
+
+func (s *testStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	err := s.checkMember(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.MockStub.GetPrivateData(collection, key)
+}
+
+func (s *testStub) GetPrivateDataHash(collection string, key string) ([]byte, error) {
+	value := s.PvtState[collection][key]
+	if value == nil {
+		return nil, nil
+	}
+
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *testStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	err := s.checkMember(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0)
+	for key := range s.PvtState[collection] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	results := make([]*queryresult.KV, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, &queryresult.KV{Namespace: s.Name, Key: key, Value: s.PvtState[collection][key]})
+	}
+
+	return &testIterator{results: results}, nil
+}
+
+// checkMember fails the way a peer does that does not hold the private data
+// of the collection
+func (s *testStub) checkMember(collection string) error {
+	if s.peerMSP != "" && collection != ownerCollection(s.peerMSP) {
+		return fmt.Errorf("private data matching public hash version is not available")
+	}
+
+	return nil
+}
+
+func (s *testStub) write(write func() error) error {
+	if s.isolated {
+		s.pending = append(s.pending, write)
+		return nil
+	}
+
+	return write()
+}
+
+// testIterator iterates over query results collected up front
+type testIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *testIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	if len(i.results) == 0 {
+		return nil, fmt.Errorf("no more query results")
+	}
+
+	next := i.results[0]
+	i.results = i.results[1:]
+	return next, nil
+}
+
+func (i *testIterator) Close() error {
+	return nil
+}
+
+// testContext runs transactions against an in-memory MockStub
+type testContext struct {
+	stub     *testStub
+	identity *testIdentity
+}
+
+func (c *testContext) GetStub() shim.ChaincodeStubInterface {
+	return c.stub
+}
+
+func (c *testContext) GetClientIdentity() cid.ClientIdentity {
+	return c.identity
+}
+
+// as switches the submitting client to the given identity
+func (c *testContext) as(id string, mspID string, attributes map[string]string) *testContext {
+	c.identity = &testIdentity{id: id, mspID: mspID, attributes: attributes}
+	return c
+}
+
+// withOwnerDetails passes the personal details of an owner in the transient
+// map, as clients of CreateOwner and UpdateOwner do
+func (c *testContext) withOwnerDetails(name string, surname string, email string, money int64) *testContext {
+	details, _ := json.Marshal(ownerDetails{Name: name, Surname: surname, Email: email, Money: money, Currency: "EUR"})
+	c.stub.transient = map[string][]byte{ownerTransientKey: details, saltTransientKey: testSalt()}
+	return c
+}
+
+// withSalt passes only a salt in the transient map, as clients of InitLedger
+// and MigrateOwnerPrivateData do
+func (c *testContext) withSalt() *testContext {
+	c.stub.transient = map[string][]byte{saltTransientKey: testSalt()}
+	return c
+}
+
+// testSalt returns a salt that differs for every call, as a random one would
+func testSalt() []byte {
+	saltCounter++
+	return []byte(fmt.Sprintf("test-salt-%06d", saltCounter))
+}
+
+var saltCounter int
+
+// asOwner switches the submitting client to the identity bound to an owner
+// of the initial ledger
+func (c *testContext) asOwner(ownerId int) *testContext {
+	return c.as(fmt.Sprintf("x509::CN=owner%d", ownerId), "Org1MSP", nil)
+}
+
+// asMechanic switches the submitting client to a mechanic of the given org
+func (c *testContext) asMechanic(mspID string) *testContext {
+	return c.as("x509::CN=mechanic::"+mspID, mspID, map[string]string{mechanicAttribute: "true"})
+}
+
+// newTestContext returns a context with the initial ledger in place and its
+// owners bound to client identities
+func newTestContext(t *testing.T) *testContext {
+	ctx := &testContext{
+		stub: &testStub{MockStub: shimtest.NewMockStub("cars", nil)},
+	}
+	ctx.stub.MockTransactionStart("tx1")
+	ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"})
+
+	s := SmartContract{}
+	err := s.InitLedger(ctx.withSalt())
+	require.NoError(t, err)
+
+	for id := 1; id <= 3; id++ {
+		err = s.BindOwnerIdentity(ctx, fmt.Sprintf("OWNER%d", id), fmt.Sprintf("x509::CN=owner%d", id), "Org1MSP")
+		require.NoError(t, err)
+	}
+
+	return ctx.as("x509::CN=User1", "Org1MSP", nil)
+}
+
+// isolated runs fn as a single transaction that does not read its own
+// writes, and commits the writes afterwards
+func (c *testContext) isolated(t *testing.T, fn func()) {
+	c.stub.isolated = true
+	fn()
+	c.stub.isolated = false
+
+	for _, write := range c.stub.pending {
+		require.NoError(t, write())
+	}
+	c.stub.pending = nil
+}
+
+// endorsed runs fn as a single transaction on a peer of each of the given
+// organizations in turn, and commits the writes of the last one when every
+// peer endorsed it. It returns the error of the first peer that did not.
+func (c *testContext) endorsed(t *testing.T, fn func() error, mspIDs ...string) error {
+	defer func() {
+		c.stub.peerMSP = ""
+		c.stub.isolated = false
+		c.stub.pending = nil
+	}()
+
+	var pending []func() error
+	for _, mspID := range mspIDs {
+		c.stub.peerMSP = mspID
+		c.stub.isolated = true
+		c.stub.pending = nil
+
+		err := fn()
+		if err != nil {
+			return err
+		}
+		pending = c.stub.pending
+	}
+
+	c.stub.peerMSP = ""
+	c.stub.isolated = false
+	for _, write := range pending {
+		require.NoError(t, write())
+	}
+
+	return nil
+}
+
+// lastEvent drains the events emitted so far and returns the latest one,
+// which is the one Fabric would keep for the transaction
+func (c *testContext) lastEvent(t *testing.T) (string, CarEvent) {
+	var name string
+	var event CarEvent
+	for {
+		select {
+		case ccEvent := <-c.stub.ChaincodeEventsChannel:
+			name = ccEvent.EventName
+			require.NoError(t, json.Unmarshal(ccEvent.Payload, &event))
+		default:
+			return name, event
+		}
+	}
+}
+
+// requireError makes sure that err is a CarError with the code and message
+func requireError(t *testing.T, err error, code string, message string) {
+	t.Helper()
+	require.Error(t, err)
+
+	var carErr CarError
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &carErr), err.Error())
+	require.Equal(t, code, carErr.Code)
+	require.Equal(t, message, carErr.Message)
+}
@@ -0,0 +1,298 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	theftObjectType  = "theft"
+	configObjectType = "config"
+
+	// lawEnforcementConfig is the config entry that holds the MSP ID of the
+	// organization allowed to report cars stolen and recovered
+	lawEnforcementConfig = "lawEnforcementMSP"
+)
+
+// TheftReport is a police case about a stolen car. RecoveredAt is zero
+// while the car is still missing.
+type TheftReport struct {
+	DocType     string    `json:"docType"`
+	CarId       string    `json:"carId"`
+	CaseNumber  string    `json:"caseNumber"`
+	MSPID       string    `json:"mspId"`
+	ReportedAt  time.Time `json:"reportedAt"`
+	ReportedBy  string    `json:"reportedBy"`
+	RecoveredAt time.Time `json:"recoveredAt"`
+}
+
+// SetLawEnforcementOrg configures the organization whose clients report
+// stolen cars. It can only be called by identities carrying the cars.admin
+// attribute.
+func (s *SmartContract) SetLawEnforcementOrg(ctx contractapi.TransactionContextInterface, mspId string) error {
+	err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		return newError(CodeUnauthorized, "submitting client not authorized to configure the contract, does not have %s role", adminAttribute)
+	}
+
+	if mspId == "" {
+		return newError(CodeInvalidArgument, "MSP ID of law enforcement organization must not be empty")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{lawEnforcementConfig})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, []byte(mspId))
+}
+
+// ReportStolen marks the car as stolen under the police case number. A car
+// listed for sale is taken off the market and all open offers are refunded.
+// Only clients of the law enforcement organization can report stolen cars.
+func (s *SmartContract) ReportStolen(ctx contractapi.TransactionContextInterface, carId string, caseNumber string) (*TheftReport, error) {
+	mspID, err := authorizeLawEnforcement(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if caseNumber == "" {
+		return nil, newError(CodeInvalidArgument, "case number must not be empty")
+	}
+
+	_, err = s.GetTheftReport(ctx, carId, caseNumber)
+	if err == nil {
+		return nil, newError(CodeAlreadyExists, "case %s is already reported for car %s", caseNumber, carId)
+	}
+
+	err = changeCarStatus(ctx, car, CarStolen)
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := isListed(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	if listed {
+		err = s.closeOpenOffers(ctx, carId, "", OfferRejected)
+		if err != nil {
+			return nil, err
+		}
+
+		err = deleteListing(ctx, carId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a stolen car can not be sold to whoever its owner approved before
+	car.ApprovedBuyer = ""
+	err = putCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	reportedAt, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reportedBy, err := getSubmittingClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := TheftReport{
+		CarId:      carId,
+		CaseNumber: caseNumber,
+		MSPID:      mspID,
+		ReportedAt: reportedAt,
+		ReportedBy: reportedBy,
+	}
+
+	err = putTheftReport(ctx, &report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitCarEvent(ctx, EventCarStolen, carId, car.Owner, caseNumber, Amount{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ReportRecovered closes the police case of the stolen car and makes the car
+// active again. Only clients of the law enforcement organization can report
+// recovered cars.
+func (s *SmartContract) ReportRecovered(ctx contractapi.TransactionContextInterface, carId string, caseNumber string) (*TheftReport, error) {
+	_, err := authorizeLawEnforcement(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.GetTheftReport(ctx, carId, caseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if !report.RecoveredAt.IsZero() {
+		return nil, newError(CodeInvalidState, "car %s of case %s is already recovered", carId, caseNumber)
+	}
+
+	if carStatus(car) != CarStolen {
+		return nil, newError(CodeNotFound, "car %s is not reported stolen", carId)
+	}
+
+	err = changeCarStatus(ctx, car, CarActive)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+
+	report.RecoveredAt, err = txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putTheftReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitCarEvent(ctx, EventCarRecovered, carId, caseNumber, car.Owner, Amount{})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// IsStolen tells whether the car is currently reported stolen. Buyers can
+// call it before they make an offer for a car.
+func (s *SmartContract) IsStolen(ctx contractapi.TransactionContextInterface, carId string) (bool, error) {
+	car, err := s.GetCarById(ctx, carId)
+	if err != nil {
+		return false, err
+	}
+
+	return carStatus(car) == CarStolen, nil
+}
+
+// GetTheftReport returns the theft report of the car with given case number
+func (s *SmartContract) GetTheftReport(ctx contractapi.TransactionContextInterface, carId string, caseNumber string) (*TheftReport, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(theftObjectType, []string{carId, caseNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	reportAsBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, errLedger("failed to read from world state: %v", err)
+	}
+
+	if reportAsBytes == nil {
+		return nil, newError(CodeNotFound, "case %s of car %s does not exist", caseNumber, carId)
+	}
+
+	report := new(TheftReport)
+	err = json.Unmarshal(reportAsBytes, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// GetTheftReportsForCar returns all theft reports of the car, open or closed
+func (s *SmartContract) GetTheftReportsForCar(ctx contractapi.TransactionContextInterface, carId string) ([]*TheftReport, error) {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(theftObjectType, []string{carId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultIter.Close()
+
+	reports := make([]*TheftReport, 0)
+	for resultIter.HasNext() {
+		queryResponse, err := resultIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var report TheftReport
+		err = json.Unmarshal(queryResponse.Value, &report)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, &report)
+	}
+
+	return reports, nil
+}
+
+// authorizeLawEnforcement makes sure that the submitting client belongs to
+// the configured law enforcement organization and returns its MSP ID
+func authorizeLawEnforcement(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{lawEnforcementConfig})
+	if err != nil {
+		return "", err
+	}
+
+	policeMSP, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", errLedger("failed to read from world state: %v", err)
+	}
+
+	if policeMSP == nil {
+		return "", newError(CodeInvalidState, "no law enforcement organization is configured")
+	}
+
+	mspID, err := getSubmittingClientOrg(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if mspID != string(policeMSP) {
+		return "", newError(CodeUnauthorized, "submitting client is not a member of the law enforcement organization %s", policeMSP)
+	}
+
+	return mspID, nil
+}
+
+func putTheftReport(ctx contractapi.TransactionContextInterface, report *TheftReport) error {
+	report.DocType = theftObjectType
+	key, err := ctx.GetStub().CreateCompositeKey(theftObjectType, []string{report.CarId, report.CaseNumber})
+	if err != nil {
+		return err
+	}
+
+	reportAsBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, reportAsBytes)
+}
@@ -0,0 +1,79 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStolenCars(t *testing.T) {
+	ctx := newTestContext(t)
+	s := SmartContract{}
+	police := func() *testContext { return ctx.as("x509::CN=officer", "PoliceMSP", nil) }
+
+	_, err := s.ReportStolen(police(), "4", "case-17")
+	requireError(t, err, CodeInvalidState, "no law enforcement organization is configured")
+
+	err = s.SetLawEnforcementOrg(ctx, "PoliceMSP")
+	requireError(t, err, CodeUnauthorized, "submitting client not authorized to configure the contract, does not have cars.admin role")
+
+	err = s.SetLawEnforcementOrg(ctx.as("x509::CN=admin", "Org1MSP", map[string]string{adminAttribute: "true"}), "PoliceMSP")
+	require.NoError(t, err)
+
+	_, err = s.ReportStolen(ctx.asOwner(1), "4", "case-17")
+	requireError(t, err, CodeUnauthorized, "submitting client is not a member of the law enforcement organization PoliceMSP")
+
+	err = s.ListCarForSale(ctx.asOwner(2), "4", 650000)
+	require.NoError(t, err)
+
+	_, err = s.MakeOffer(ctx.asOwner(1), "4", "OWNER1", 600000)
+	require.NoError(t, err)
+
+	var report *TheftReport
+	ctx.isolated(t, func() {
+		report, err = s.ReportStolen(police(), "4", "case-17")
+		require.NoError(t, err)
+	})
+	require.Equal(t, "PoliceMSP", report.MSPID)
+	require.True(t, report.RecoveredAt.IsZero())
+
+	stolen, err := s.IsStolen(ctx, "4")
+	require.NoError(t, err)
+	require.True(t, stolen)
+
+	// the listing is cancelled and the offer refunded
+	listed, err := isListed(ctx, "4")
+	require.NoError(t, err)
+	require.False(t, listed)
+
+	buyer, err := s.CollectPayments(ctx.asOwner(1), "OWNER1")
+	require.NoError(t, err)
+	require.Equal(t, NewAmount(1000000, "EUR"), buyer.Money)
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "red")
+	requireError(t, err, CodeInvalidState, "car 4 is reported stolen and can not be changed until it is recovered")
+
+	err = s.DeleteCar(ctx, "4")
+	requireError(t, err, CodeInvalidState, "car 4 is reported stolen and can not be changed until it is recovered")
+
+	err = s.TransferOwnership(ctx.asOwner(1), "4", "OWNER1", false)
+	requireError(t, err, CodeInvalidState, "car 4 is reported stolen and can not be changed until it is recovered")
+
+	_, err = s.ReportRecovered(police(), "4", "case-18")
+	requireError(t, err, CodeNotFound, "case case-18 of car 4 does not exist")
+
+	report, err = s.ReportRecovered(police(), "4", "case-17")
+	require.NoError(t, err)
+	require.False(t, report.RecoveredAt.IsZero())
+
+	stolen, err = s.IsStolen(ctx, "4")
+	require.NoError(t, err)
+	require.False(t, stolen)
+
+	err = s.ChangeCarColor(ctx.asOwner(2), "4", "red")
+	require.NoError(t, err)
+}
@@ -0,0 +1,106 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	vinIndex  = "vin~id"
+	vinLength = 17
+
+	// vinCheckDigitPosition is the index of the check digit within the VIN
+	vinCheckDigitPosition = 8
+)
+
+// vinWeights are the weights of the VIN positions in the check digit sum
+var vinWeights = [vinLength]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// vinValues transliterates the letters allowed in a VIN to their numeric
+// value. I, O and Q are not used, since they are easily mistaken for 1 and 0.
+var vinValues = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+// normalizeVin upper cases the vehicle identification number and checks its
+// length, characters and check digit. It returns the VIN in normalized form.
+func normalizeVin(vin string) (string, error) {
+	vin = strings.ToUpper(strings.TrimSpace(vin))
+
+	if len(vin) != vinLength {
+		return "", newError(CodeInvalidArgument, "VIN %s must have %d characters", vin, vinLength)
+	}
+
+	sum := 0
+	for i, c := range vin {
+		value, ok := vinValues[c]
+		if c >= '0' && c <= '9' {
+			value, ok = int(c-'0'), true
+		}
+
+		if !ok {
+			return "", newError(CodeInvalidArgument, "VIN %s contains invalid character %c", vin, c)
+		}
+
+		sum += value * vinWeights[i]
+	}
+
+	checkDigit := byte('0' + sum%11)
+	if sum%11 == 10 {
+		checkDigit = 'X'
+	}
+
+	if vin[vinCheckDigitPosition] != checkDigit {
+		return "", newError(CodeInvalidArgument, "VIN %s has check digit %c, expected %c", vin, vin[vinCheckDigitPosition], checkDigit)
+	}
+
+	return vin, nil
+}
+
+// checkVinUnused makes sure that the VIN is not registered for another car.
+// Index entries of deleted cars are kept, so a VIN is never registered twice.
+func checkVinUnused(ctx contractapi.TransactionContextInterface, vin string) error {
+	resultIter, err := ctx.GetStub().GetStateByPartialCompositeKey(vinIndex, []string{vin})
+	if err != nil {
+		return err
+	}
+	defer resultIter.Close()
+
+	if resultIter.HasNext() {
+		responseRange, err := resultIter.Next()
+		if err != nil {
+			return err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return err
+		}
+
+		return newError(CodeAlreadyExists, "VIN %s is already registered for car %s", vin, compositeKeyParts[1])
+	}
+
+	return nil
+}
+
+func putVinIndex(ctx contractapi.TransactionContextInterface, car *Car) error {
+	if car.Vin == "" {
+		return nil
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(vinIndex, []string{car.Vin, strconv.Itoa(car.Id)})
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	return ctx.GetStub().PutState(key, value)
+}
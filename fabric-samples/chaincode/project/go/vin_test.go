@@ -0,0 +1,26 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeVin(t *testing.T) {
+	vin, err := normalizeVin(" 1m8gdm9axkp042788 ")
+	require.NoError(t, err)
+	require.Equal(t, "1M8GDM9AXKP042788", vin)
+
+	_, err = normalizeVin("1M8GDM9AXKP04278")
+	requireError(t, err, CodeInvalidArgument, "VIN 1M8GDM9AXKP04278 must have 17 characters")
+
+	_, err = normalizeVin("1M8GDM9AXKP0427O8")
+	requireError(t, err, CodeInvalidArgument, "VIN 1M8GDM9AXKP0427O8 contains invalid character O")
+
+	_, err = normalizeVin("1M8GDM9A1KP042788")
+	requireError(t, err, CodeInvalidArgument, "VIN 1M8GDM9A1KP042788 has check digit 1, expected X")
+}
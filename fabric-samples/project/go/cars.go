@@ -7,184 +7,214 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"fabcar/client"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 )
 
 func main() {
 	os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		fmt.Printf("Failed to create wallet: %s\n", err)
-		os.Exit(1)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the command line and returns the exit code
+func run(args []string) int {
+	g := newGlobalFlags()
+	g.set.Usage = func() {
+		printUsage(g.set)
 	}
 
-	if !wallet.Exists("appUser") {
-		err = populateWallet(wallet)
-		if err != nil {
-			fmt.Printf("Failed to populate wallet contents: %s\n", err)
-			os.Exit(1)
+	cfg, err := g.parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
 		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
 
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org4.example.com",
-		"connection-org4.yaml",
-	)
-
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
-	if err != nil {
-		fmt.Printf("Failed to connect to gateway: %s\n", err)
-		os.Exit(1)
+	args = g.set.Args()
+	if len(args) == 0 {
+		printUsage(g.set)
+		return 2
 	}
-	defer gw.Close()
 
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		fmt.Printf("Failed to get network: %s\n", err)
-		os.Exit(1)
+	if args[0] == "listen" {
+		return withContract(cfg, func(contract *gateway.Contract) int {
+			err := listen(contract)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to listen for events: %s\n", err)
+				return 1
+			}
+			return 0
+		})
 	}
 
-	contract := network.GetContract("cars")
+	cmd, ok := findCommand(args)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s, run cars -h for the list of commands\n", strings.Join(args, " "))
+		return 2
+	}
 
-	fmt.Println("-------------- GET CAR BY ID --------------")
-	result, err := contract.EvaluateTransaction("getCarById", "4")
-	if err != nil {
-		fmt.Printf("Failed to evaluate transaction: %s\n", err)
-		os.Exit(1)
-	}
-	fmt.Println(string(result))
-
-	// fmt.Println("-------------- GET CAR BY COLOR --------------")
-	// result, err = contract.EvaluateTransaction("getCarsByColor", "black")
-	// if err != nil {
-	// 	fmt.Printf("Failed to evaluate transaction: %s\n", err)
-	// 	os.Exit(1)
-	// }
-	// fmt.Println(string(result))
-
-	// fmt.Println("-------------- GET CAR BY COLOR AND OWNER --------------")
-	// result, err = contract.EvaluateTransaction("getCarsByColorAndOwner", "blue", "1")
-	// if err != nil {
-	// 	fmt.Printf("Failed to evaluate transaction: %s\n", err)
-	// 	os.Exit(1)
-	// }
-	// fmt.Println(string(result))
-
-	fmt.Println("-------------- GET OWNER BY ID --------------")
-	result, err = contract.EvaluateTransaction("getOwnerById", "OWNER1")
+	fs := flag.NewFlagSet(cmd.group+" "+cmd.name, flag.ContinueOnError)
+	runCommand := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: cars [flags] %s\n\n%s\n", cmd.usage(), cmd.help)
+		fs.PrintDefaults()
+	}
+	err = fs.Parse(args[2:])
 	if err != nil {
-		fmt.Printf("Failed to evaluate transaction: %s\n", err)
-		os.Exit(1)
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
 	}
-	fmt.Println(string(result))
 
-	fmt.Println("-------------- GET OWNER BY ID --------------")
-	result, err = contract.EvaluateTransaction("getOwnerById", "OWNER2")
+	err = cmd.checkArgs(fs.Args())
 	if err != nil {
-		fmt.Printf("Failed to evaluate transaction: %s\n", err)
-		os.Exit(1)
-	}
-	fmt.Println(string(result))
-
-	// fmt.Println("-------------- GET CARS BY COLOR --------------")
-	// result, err = contract.EvaluateTransaction("getCarsByColor", "blue")
-	// if err != nil {
-	// 	fmt.Println(fmt.Errorf("failed to evaluate transaction: %w", err))
-	// 	return
-	// }
-	// fmt.Println(string(result))
-
-	// fmt.Println("-------------- REPAIR CAR --------------")
-	// _, err = contract.SubmitTransaction("repairCar", "2")
-	// if err != nil {
-	// 	fmt.Println(fmt.Errorf("failed to submit transaction: %w", err))
-	// 	return
-	// }
-
-	// fmt.Println("-------------- ADD MALFUNCTION --------------")
-	// _, err = contract.SubmitTransaction("addMalfunction", "6", "Broken door", "2500")
-	// if err != nil {
-	// 	fmt.Println(fmt.Errorf("failed to submit transaction: %w", err))
-	// 	return
-	// }
-
-	// fmt.Println("-------------- TRANSFER OWNERSHIP --------------")
-	// _, err = contract.SubmitTransaction("transferOwnership", "4", "OWNER1", "false")
-	// if err != nil {
-	// 	fmt.Println(fmt.Errorf("failed to submit transaction: %w", err))
-	// 	return
-	// }
-
-	// fmt.Println("-------------- DELETE CAR --------------")
-	// _, err = contract.SubmitTransaction("deleteCar", "2")
-	// if err != nil {
-	// 	fmt.Println(fmt.Errorf("failed to submit transaction: %w", err))
-	// 	return
-	// }
-
-	// fmt.Println("-------------- CHANGE COLOR --------------")
-	// _, err = contract.SubmitTransaction("changeCarColor", "5", "pink")
-	// if err != nil {
-	// 	fmt.Printf("Failed to submit transaction: %s\n", err)
-	// 	os.Exit(1)
-	// }
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	return withContract(cfg, func(contract *gateway.Contract) int {
+		cars := client.New(client.NewGatewayContract(contract, cfg.Peers))
+		result, err := runCommand(cars, fs.Args(), g.callOptions())
+		if err != nil {
+			printError(cfg.Output, err)
+			return 1
+		}
+
+		err = printResult(os.Stdout, cfg.Output, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print result: %s\n", err)
+			return 1
+		}
+		return 0
+	})
 }
 
-func populateWallet(wallet *gateway.Wallet) error {
-	credPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org4.example.com",
-		"users",
-		"User1@org4.example.com",
-		"msp",
-	)
-
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
-	// read the certificate pem
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+// withContract connects to the network of the config and runs fn with the
+// cars contract
+func withContract(cfg Config, fn func(contract *gateway.Contract) int) int {
+	gw, err := connect(cfg)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	defer gw.Close()
 
-	keyDir := filepath.Join(credPath, "keystore")
-	// there's a single file in this dir containing the private key
-	files, err := ioutil.ReadDir(keyDir)
+	network, err := gw.GetNetwork(cfg.Channel)
 	if err != nil {
-		return err
+		fmt.Fprintf(os.Stderr, "Failed to get network: %s\n", err)
+		return 1
 	}
-	if len(files) != 1 {
-		return errors.New("keystore folder should have contain one file")
+
+	return fn(network.GetContract(cfg.Chaincode))
+}
+
+// findCommand looks up the command named by the first two arguments
+func findCommand(args []string) (command, bool) {
+	if len(args) < 2 {
+		return command{}, false
 	}
-	keyPath := filepath.Join(keyDir, files[0].Name())
-	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
-	if err != nil {
-		return err
+
+	for _, cmd := range commands() {
+		if cmd.group == args[0] && cmd.name == args[1] {
+			return cmd, true
+		}
 	}
 
-	identity := gateway.NewX509Identity("Org4MSP", string(cert), string(key))
+	return command{}, false
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: cars [flags] <group> <command> [command flags] [arguments]")
+	fmt.Fprintln(w, "       cars [flags] listen")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands, run one with -h for its flags:")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage(), cmd.help)
+	}
+	fmt.Fprintf(tw, "  listen\tprint the events of the cars chaincode until interrupted\n")
+	tw.Flush()
+}
 
-	err = wallet.Put("appUser", identity)
+// printError prints why a command failed, as the error of the chaincode when
+// the output is JSON
+func printError(output string, err error) {
+	if contractErr := client.DecodeError(err); contractErr != nil && output == outputJSON {
+		errAsBytes, marshalErr := json.MarshalIndent(contractErr, "", "  ")
+		if marshalErr == nil {
+			fmt.Fprintln(os.Stderr, string(errAsBytes))
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Failed to run transaction: %s\n", describeError(err))
+}
+
+// describeError explains why a transaction failed, by the code of the
+// chaincode error when there is one
+func describeError(err error) string {
+	contractErr := client.DecodeError(err)
+	if contractErr == nil {
+		return err.Error()
+	}
+
+	switch contractErr.Code {
+	case client.CodeCarNotFound, client.CodeOwnerNotFound, client.CodeNotFound:
+		return fmt.Sprintf("not found: %s", contractErr.Message)
+	case client.CodeUnauthorized:
+		return fmt.Sprintf("not allowed: %s", contractErr.Message)
+	case client.CodeInsufficientFunds:
+		return fmt.Sprintf("not enough money: %s", contractErr.Message)
+	case client.CodeLedger, client.CodeInternal:
+		return fmt.Sprintf("the network failed to process the transaction: %s", contractErr.Message)
+	default:
+		return contractErr.Message
+	}
+}
+
+// listen prints the events of the cars chaincode until the program is interrupted
+func listen(contract *gateway.Contract) error {
+	registration, events, err := contract.RegisterEvent(".*")
 	if err != nil {
 		return err
 	}
-	return nil
+	defer contract.Unregister(registration)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	fmt.Println("-------------- LISTENING FOR CAR EVENTS --------------")
+	for {
+		select {
+		case event := <-events:
+			var payload bytes.Buffer
+			if json.Indent(&payload, event.Payload, "", "  ") != nil {
+				payload.Reset()
+				payload.Write(event.Payload)
+			}
+			fmt.Printf("%s (block %d, tx %s)\n%s\n", event.EventName, event.BlockNumber, event.TxID, payload.String())
+		case <-interrupt:
+			return nil
+		}
+	}
 }
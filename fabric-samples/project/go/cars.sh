@@ -1,15 +1,15 @@
 
 ENV_DAL=`echo $DISCOVERY_AS_LOCALHOST`
 
-echo "ENV_DAL:"$DISCOVERY_AS_LOCALHOST
+echo "ENV_DAL:"$DISCOVERY_AS_LOCALHOST >&2
 
 if [ "$ENV_DAL" != "true" ]
 then
 	export DISCOVERY_AS_LOCALHOST=true
 fi
 
-echo "DISCOVERY_AS_LOCALHOST="$DISCOVERY_AS_LOCALHOST
+echo "DISCOVERY_AS_LOCALHOST="$DISCOVERY_AS_LOCALHOST >&2
 
-echo "run cars..."
+echo "run cars..." >&2
 
-go run cars.go
+go run . "$@"
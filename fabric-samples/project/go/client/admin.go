@@ -0,0 +1,73 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// MigrateAmounts converts amounts stored as floating point numbers into cents
+// of the currency, and returns the number of converted records
+func (c *CarsClient) MigrateAmounts(currency string, options ...CallOption) (int, error) {
+	var result int
+	err := c.submit(&result, options, "MigrateAmounts", currency)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// MigrateKeys moves cars stored under their bare numeric id to the CAR key
+// namespace, and returns the number of rewritten records
+func (c *CarsClient) MigrateKeys(options ...CallOption) (int, error) {
+	var result int
+	err := c.submit(&result, options, "MigrateKeys")
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// MigrateOwnerPrivateData moves the personal details of owners written to
+// world state, or to the collection shared by every organization, to the
+// collection of their organization, and returns the number of migrated
+// owners. Their hashes are salted from a random salt passed in the transient
+// map.
+func (c *CarsClient) MigrateOwnerPrivateData(options ...CallOption) (int, error) {
+	options, err := withSalt(options)
+	if err != nil {
+		return 0, err
+	}
+
+	var result int
+	err = c.submit(&result, options, "MigrateOwnerPrivateData")
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// VerifyIndexes reports the secondary index entries of cars that drifted from
+// world state
+func (c *CarsClient) VerifyIndexes(options ...CallOption) (*IndexReport, error) {
+	var result IndexReport
+	err := c.evaluate(&result, options, "VerifyIndexes")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RebuildIndexes repairs the secondary indexes of cars and reports what it
+// repaired
+func (c *CarsClient) RebuildIndexes(options ...CallOption) (*IndexReport, error) {
+	var result IndexReport
+	err := c.submit(&result, options, "RebuildIndexes")
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
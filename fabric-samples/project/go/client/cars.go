@@ -0,0 +1,189 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import "strconv"
+
+// InitLedger puts the sample owners and cars in world state. The owners are
+// hashed with salts derived from a random salt passed in the transient map.
+func (c *CarsClient) InitLedger(options ...CallOption) error {
+	options, err := withSalt(options)
+	if err != nil {
+		return err
+	}
+
+	return c.submit(nil, options, "InitLedger")
+}
+
+// RegisterCar registers a new car of the owner, given by key such as OWNER1.
+// An empty carId takes the next free id.
+func (c *CarsClient) RegisterCar(carId string, vin string, make string, model string, year int, mileage int, color string, price int64, ownerId string, options ...CallOption) (*Car, error) {
+	var result Car
+	err := c.submit(&result, options, "RegisterCar", carId, vin, make, model, itoa(year), itoa(mileage), color, formatInt64(price), ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CarExists tells whether a car with the id is in world state
+func (c *CarsClient) CarExists(carId string, options ...CallOption) (bool, error) {
+	var result bool
+	err := c.evaluate(&result, options, "CarExists", carId)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// GetCarById returns the car with the id
+func (c *CarsClient) GetCarById(carId string, options ...CallOption) (*Car, error) {
+	var result Car
+	err := c.evaluate(&result, options, "GetCarById", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllCars returns every car in world state
+func (c *CarsClient) GetAllCars(options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetAllCars")
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsByColor returns the cars of the color
+func (c *CarsClient) GetCarsByColor(color string, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetCarsByColor", color)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsByColorAndOwner returns the cars of the color that belong to the
+// owner with the numeric id
+func (c *CarsClient) GetCarsByColorAndOwner(color string, owner string, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetCarsByColorAndOwner", color, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsByMakeAndModel returns the cars of the make and model, ignoring case
+func (c *CarsClient) GetCarsByMakeAndModel(make string, model string, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetCarsByMakeAndModel", make, model)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsByOwner returns the cars the owner holds a share of, given by key
+// such as OWNER1
+func (c *CarsClient) GetCarsByOwner(ownerId string, options ...CallOption) ([]*OwnedCar, error) {
+	var result []*OwnedCar
+	err := c.evaluate(&result, options, "GetCarsByOwner", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsByStatus returns the cars in the status
+func (c *CarsClient) GetCarsByStatus(status string, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetCarsByStatus", status)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ChangeCarColor paints the car of the submitting owner
+func (c *CarsClient) ChangeCarColor(carId string, color string, options ...CallOption) error {
+	return c.submit(nil, options, "ChangeCarColor", carId, color)
+}
+
+// RecordMileage records an odometer reading of the car
+func (c *CarsClient) RecordMileage(carId string, mileage int, options ...CallOption) error {
+	return c.submit(nil, options, "RecordMileage", carId, itoa(mileage))
+}
+
+// ApproveTransfer lets the owner of the car approve the buyer that may take it
+// over
+func (c *CarsClient) ApproveTransfer(carId string, newOwner string, options ...CallOption) error {
+	return c.submit(nil, options, "ApproveTransfer", carId, newOwner)
+}
+
+// TransferOwnership lets the approved buyer take over the car and pay its
+// price. A car with open malfunctions is only taken if acceptsMalfunctions is
+// set, at its price less the malfunctions.
+func (c *CarsClient) TransferOwnership(carId string, newOwner string, acceptsMalfunctions bool, options ...CallOption) error {
+	return c.submit(nil, options, "TransferOwnership", carId, newOwner, strconv.FormatBool(acceptsMalfunctions))
+}
+
+// DeleteCar scraps the car of the submitting owner
+func (c *CarsClient) DeleteCar(carId string, options ...CallOption) error {
+	return c.submit(nil, options, "DeleteCar", carId)
+}
+
+// GetCarHistory returns the vehicle history report of the car
+func (c *CarsClient) GetCarHistory(carId string, options ...CallOption) (*CarHistory, error) {
+	var result CarHistory
+	err := c.evaluate(&result, options, "GetCarHistory", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCarEndorsingOrgs returns the MSP IDs of the organizations that must
+// endorse changes to the car, to be passed to WithEndorsingOrgs
+func (c *CarsClient) GetCarEndorsingOrgs(carId string, options ...CallOption) ([]string, error) {
+	var result []string
+	err := c.evaluate(&result, options, "GetCarEndorsingOrgs", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SetCarShares proposes to split the car among co-owners, given by key such
+// as OWNER2, which takes effect once each of them accepted it with
+// AcceptCarShares. The sale majority is the percentage of shares that must
+// consent to a sale.
+func (c *CarsClient) SetCarShares(carId string, shares []OwnerShare, saleMajority int, options ...CallOption) error {
+	sharesArg, err := jsonArg(shares)
+	if err != nil {
+		return err
+	}
+
+	return c.submit(nil, options, "SetCarShares", carId, sharesArg, itoa(saleMajority))
+}
+
+// AcceptCarShares lets a co-owner accept the share of the car proposed to it
+func (c *CarsClient) AcceptCarShares(carId string, options ...CallOption) error {
+	return c.submit(nil, options, "AcceptCarShares", carId)
+}
@@ -0,0 +1,187 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package client calls the transactions of the cars chaincode with typed
+// arguments and results.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// Call is a transaction of the cars chaincode to run, with its arguments as
+// the chaincode receives them
+type Call struct {
+	Name          string
+	Args          []string
+	Submit        bool
+	EndorsingOrgs []string
+	Transient     map[string][]byte
+}
+
+// Contract runs calls against the cars chaincode. GatewayContract runs them
+// on a Fabric network, tests can run them against anything else.
+type Contract interface {
+	Invoke(call Call) ([]byte, error)
+}
+
+// CallOption changes how a call is run
+type CallOption func(call *Call)
+
+// Submit sends the call to the orderer, so that it is committed to the
+// ledger. Transactions that change the ledger are submitted by default.
+func Submit() CallOption {
+	return func(call *Call) {
+		call.Submit = true
+	}
+}
+
+// Evaluate only runs the call on the endorsing peers, without committing it.
+// Queries are evaluated by default.
+func Evaluate() CallOption {
+	return func(call *Call) {
+		call.Submit = false
+	}
+}
+
+// WithEndorsingOrgs has the call endorsed by peers of the organizations with
+// the given MSP IDs, such as those GetCarEndorsingOrgs returns for a car
+func WithEndorsingOrgs(mspIDs ...string) CallOption {
+	return func(call *Call) {
+		call.EndorsingOrgs = mspIDs
+	}
+}
+
+// WithTransient passes data to the call that is not recorded in the
+// transaction
+func WithTransient(data map[string][]byte) CallOption {
+	return func(call *Call) {
+		if call.Transient == nil {
+			call.Transient = make(map[string][]byte)
+		}
+		for key, value := range data {
+			call.Transient[key] = value
+		}
+	}
+}
+
+// CarsClient has a method for every transaction of the cars chaincode. Each
+// method evaluates queries and submits updates unless told otherwise by its
+// options, and returns a *ContractError when the chaincode fails the
+// transaction.
+type CarsClient struct {
+	contract Contract
+}
+
+// New returns a client that runs its calls on the contract
+func New(contract Contract) *CarsClient {
+	return &CarsClient{contract: contract}
+}
+
+// GatewayContract runs calls on a contract of a Fabric gateway. Endorsing
+// organizations are mapped to the peers configured for them.
+type GatewayContract struct {
+	contract *gateway.Contract
+	orgPeers map[string][]string
+}
+
+// NewGatewayContract returns a contract that runs calls with the gateway
+// contract. orgPeers lists the peer endpoints of each organization by MSP ID,
+// for calls with endorsing organizations.
+func NewGatewayContract(contract *gateway.Contract, orgPeers map[string][]string) *GatewayContract {
+	return &GatewayContract{contract: contract, orgPeers: orgPeers}
+}
+
+func (g *GatewayContract) Invoke(call Call) ([]byte, error) {
+	var options []gateway.TransactionOption
+	if call.Transient != nil {
+		options = append(options, gateway.WithTransient(call.Transient))
+	}
+
+	if len(call.EndorsingOrgs) > 0 {
+		var peers []string
+		for _, mspID := range call.EndorsingOrgs {
+			orgPeers, ok := g.orgPeers[mspID]
+			if !ok || len(orgPeers) == 0 {
+				return nil, fmt.Errorf("no peers of organization %s are configured", mspID)
+			}
+			peers = append(peers, orgPeers...)
+		}
+		options = append(options, gateway.WithEndorsingPeers(peers...))
+	}
+
+	txn, err := g.contract.CreateTransaction(call.Name, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if call.Submit {
+		return txn.Submit(call.Args...)
+	}
+	return txn.Evaluate(call.Args...)
+}
+
+// evaluate runs a query and decodes its result into result
+func (c *CarsClient) evaluate(result interface{}, options []CallOption, name string, args ...string) error {
+	return c.invoke(Call{Name: name, Args: args}, options, result)
+}
+
+// submit runs an update and decodes its result into result, unless result is
+// nil
+func (c *CarsClient) submit(result interface{}, options []CallOption, name string, args ...string) error {
+	return c.invoke(Call{Name: name, Args: args, Submit: true}, options, result)
+}
+
+func (c *CarsClient) invoke(call Call, options []CallOption, result interface{}) error {
+	for _, option := range options {
+		option(&call)
+	}
+
+	payload, err := c.contract.Invoke(call)
+	if err != nil {
+		if contractErr := DecodeError(err); contractErr != nil {
+			return contractErr
+		}
+		return fmt.Errorf("failed to run %s: %w", call.Name, err)
+	}
+
+	if result == nil || len(payload) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(payload, result)
+	if err != nil {
+		return fmt.Errorf("failed to decode result of %s: %w", call.Name, err)
+	}
+
+	return nil
+}
+
+// jsonArg encodes a struct or slice argument the way the chaincode expects
+// it. A nil slice is passed as an empty one, which the chaincode accepts.
+func jsonArg(value interface{}) (string, error) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.IsNil() {
+		return "[]", nil
+	}
+
+	valueAsBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(valueAsBytes), nil
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
+
+func formatInt64(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
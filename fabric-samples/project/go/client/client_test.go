@@ -0,0 +1,110 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContract records the calls it is given and answers them with a fixed
+// payload or error
+type fakeContract struct {
+	calls   []Call
+	payload string
+	err     error
+}
+
+func (f *fakeContract) Invoke(call Call) ([]byte, error) {
+	f.calls = append(f.calls, call)
+	return []byte(f.payload), f.err
+}
+
+func TestQueriesAreEvaluated(t *testing.T) {
+	contract := &fakeContract{payload: `{"docType":"car","id":4,"make":"VW","model":"Passat","color":"blue","owner":"2","status":"ACTIVE","price":{"value":700000,"currency":"EUR"}}`}
+	cars := New(contract)
+
+	car, err := cars.GetCarById("4")
+	require.NoError(t, err)
+	require.Equal(t, 4, car.Id)
+	require.Equal(t, "Passat", car.Model)
+	require.Equal(t, "7000.00 EUR", car.Price.String())
+	require.Equal(t, []Call{{Name: "GetCarById", Args: []string{"4"}}}, contract.calls)
+
+	_, err = cars.GetCarById("4", Submit())
+	require.NoError(t, err)
+	require.True(t, contract.calls[1].Submit)
+}
+
+func TestUpdatesAreSubmitted(t *testing.T) {
+	contract := &fakeContract{}
+	cars := New(contract)
+
+	err := cars.TransferOwnership("4", "OWNER1", true, WithEndorsingOrgs("Org1MSP", "Org2MSP"))
+	require.NoError(t, err)
+	require.Equal(t, Call{
+		Name:          "TransferOwnership",
+		Args:          []string{"4", "OWNER1", "true"},
+		Submit:        true,
+		EndorsingOrgs: []string{"Org1MSP", "Org2MSP"},
+	}, contract.calls[0])
+
+	err = cars.SetCarShares("4", []OwnerShare{{Owner: "OWNER2", Percent: 60}, {Owner: "OWNER1", Percent: 40}}, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{"4", `[{"owner":"OWNER2","percent":60},{"owner":"OWNER1","percent":40}]`, "100"}, contract.calls[1].Args)
+
+	_, err = cars.IssueCertificate("station1", "4", true, nil, 365)
+	require.NoError(t, err)
+	require.Equal(t, []string{"station1", "4", "true", "[]", "365"}, contract.calls[2].Args)
+	require.True(t, contract.calls[2].Submit)
+
+	err = cars.AddMalfunction("6", "Broken door", 250000, SeverityMedium, Evaluate())
+	require.NoError(t, err)
+	require.Equal(t, []string{"6", "Broken door", "250000", "MEDIUM"}, contract.calls[3].Args)
+	require.False(t, contract.calls[3].Submit)
+}
+
+func TestOwnerDetailsAreTransient(t *testing.T) {
+	contract := &fakeContract{payload: `{"docType":"owner","id":4,"name":"Ana","surname":"Anic","email":"ana@example.com","money":{"value":1000000,"currency":"EUR"}}`}
+	cars := New(contract)
+
+	owner, err := cars.CreateOwner("", OwnerDetails{Name: "Ana", Surname: "Anic", Email: "ana@example.com", Money: 1000000, Currency: "EUR"})
+	require.NoError(t, err)
+	require.Equal(t, 4, owner.Id)
+	require.Equal(t, NewAmount(1000000, "EUR"), owner.Money)
+
+	call := contract.calls[0]
+	require.Equal(t, []string{""}, call.Args)
+	require.True(t, call.Submit)
+	require.JSONEq(t, `{"name":"Ana","surname":"Anic","email":"ana@example.com","money":1000000,"currency":"EUR"}`, string(call.Transient["owner"]))
+	require.Len(t, call.Transient["salt"], 32)
+
+	// every owner gets a salt of its own
+	_, err = cars.UpdateOwner("OWNER4", OwnerDetails{Name: "Ana", Surname: "Anic", Email: "ana@example.com"})
+	require.NoError(t, err)
+	require.Len(t, contract.calls[1].Transient["salt"], 32)
+	require.NotEqual(t, call.Transient["salt"], contract.calls[1].Transient["salt"])
+}
+
+func TestContractErrors(t *testing.T) {
+	contract := &fakeContract{err: errors.New(`Failed to evaluate: Multiple errors occurred: - Transaction processing for endorser [localhost:7051]: Chaincode status Code: (500) UNKNOWN. Description: {"code":"CAR_NOT_FOUND","message":"99 does not exist","details":{"carId":"99"}}`)}
+	cars := New(contract)
+
+	_, err := cars.GetCarById("99")
+	require.True(t, IsCode(err, CodeCarNotFound))
+
+	var contractErr *ContractError
+	require.True(t, errors.As(err, &contractErr))
+	require.Equal(t, "99 does not exist", contractErr.Message)
+	require.Equal(t, map[string]string{"carId": "99"}, contractErr.Details)
+
+	// failures outside the chaincode are returned as they are
+	contract.err = errors.New("Failed to evaluate: context deadline exceeded")
+	_, err = cars.GetCarById("99")
+	require.EqualError(t, err, "failed to run GetCarById: Failed to evaluate: context deadline exceeded")
+	require.Nil(t, DecodeError(err))
+}
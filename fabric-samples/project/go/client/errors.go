@@ -0,0 +1,76 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error codes the cars chaincode returns for failed transactions
+const (
+	CodeCarNotFound        = "CAR_NOT_FOUND"
+	CodeOwnerNotFound      = "OWNER_NOT_FOUND"
+	CodeNotFound           = "NOT_FOUND"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+	CodeInvalidArgument    = "INVALID_ARGUMENT"
+	CodeInvalidAmount      = "INVALID_AMOUNT"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeInsufficientFunds  = "INSUFFICIENT_FUNDS"
+	CodeHasMalfunctions    = "HAS_MALFUNCTIONS"
+	CodeInvalidState       = "INVALID_STATE"
+	CodeNotApproved        = "NOT_APPROVED"
+	CodeCarListed          = "CAR_LISTED"
+	CodeCarLeased          = "CAR_LEASED"
+	CodeActiveLien         = "ACTIVE_LIEN"
+	CodeInspectionRequired = "INSPECTION_REQUIRED"
+	CodeLedger             = "LEDGER_ERROR"
+	CodeInternal           = "INTERNAL"
+)
+
+// ContractError is the reason the cars chaincode gave for failing a
+// transaction
+type ContractError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// DecodeError finds the error of the chaincode in an error of the gateway,
+// which embeds the chaincode response in its own message. It returns nil if
+// the transaction did not fail in the chaincode, such as when the peers can
+// not be reached.
+func DecodeError(err error) *ContractError {
+	var contractErr *ContractError
+	if err == nil || errors.As(err, &contractErr) {
+		return contractErr
+	}
+
+	message := err.Error()
+	for {
+		start := strings.Index(message, `{"code"`)
+		if start < 0 {
+			return nil
+		}
+
+		decoded := new(ContractError)
+		if json.NewDecoder(strings.NewReader(message[start:])).Decode(decoded) == nil && decoded.Code != "" {
+			return decoded
+		}
+		message = message[start+1:]
+	}
+}
+
+// IsCode tells whether the chaincode failed the transaction with the code
+func IsCode(err error, code string) bool {
+	contractErr := DecodeError(err)
+	return contractErr != nil && contractErr.Code == code
+}
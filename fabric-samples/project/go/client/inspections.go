@@ -0,0 +1,87 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import "strconv"
+
+// RegisterInspectionStation adds an inspection station of the submitting
+// inspector's organization
+func (c *CarsClient) RegisterInspectionStation(stationId string, name string, options ...CallOption) (*InspectionStation, error) {
+	var result InspectionStation
+	err := c.submit(&result, options, "RegisterInspectionStation", stationId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetInspectionStation returns the inspection station with the id
+func (c *CarsClient) GetInspectionStation(stationId string, options ...CallOption) (*InspectionStation, error) {
+	var result InspectionStation
+	err := c.evaluate(&result, options, "GetInspectionStation", stationId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// IssueCertificate records an inspection of the car by the station, valid for
+// the number of days
+func (c *CarsClient) IssueCertificate(stationId string, carId string, passed bool, defects []Defect, validDays int, options ...CallOption) (*Certificate, error) {
+	defectsArg, err := jsonArg(defects)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Certificate
+	err = c.submit(&result, options, "IssueCertificate", stationId, carId, strconv.FormatBool(passed), defectsArg, itoa(validDays))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCertificate returns the inspection certificate of the car
+func (c *CarsClient) GetCertificate(carId string, certificateId string, options ...CallOption) (*Certificate, error) {
+	var result Certificate
+	err := c.evaluate(&result, options, "GetCertificate", carId, certificateId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCertificatesForCar returns every inspection certificate of the car
+func (c *CarsClient) GetCertificatesForCar(carId string, options ...CallOption) ([]*Certificate, error) {
+	var result []*Certificate
+	err := c.evaluate(&result, options, "GetCertificatesForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetCarsWithExpiringInspection returns the cars whose inspection expires
+// within the number of days
+func (c *CarsClient) GetCarsWithExpiringInspection(days int, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetCarsWithExpiringInspection", itoa(days))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SetInspectionRequired tells whether cars can only change owner with a valid
+// inspection certificate
+func (c *CarsClient) SetInspectionRequired(required bool, options ...CallOption) error {
+	return c.submit(nil, options, "SetInspectionRequired", strconv.FormatBool(required))
+}
@@ -0,0 +1,106 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// RegisterInsurer adds an insurer of the submitting client's organization with
+// its capital in minor units. A capital other than zero needs an identity with
+// the cars.admin role.
+func (c *CarsClient) RegisterInsurer(insurerId string, name string, capital int64, currency string, options ...CallOption) (*Insurer, error) {
+	var result Insurer
+	err := c.submit(&result, options, "RegisterInsurer", insurerId, name, formatInt64(capital), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetInsurer returns the insurer with the id
+func (c *CarsClient) GetInsurer(insurerId string, options ...CallOption) (*Insurer, error) {
+	var result Insurer
+	err := c.evaluate(&result, options, "GetInsurer", insurerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// IssuePolicy proposes a policy for the car to its owner. Amounts are in minor
+// units of the insurer's currency.
+func (c *CarsClient) IssuePolicy(insurerId string, carId string, coverageLimit int64, deductible int64, premium int64, validDays int, options ...CallOption) (*Policy, error) {
+	var result Policy
+	err := c.submit(&result, options, "IssuePolicy", insurerId, carId, formatInt64(coverageLimit), formatInt64(deductible), formatInt64(premium), itoa(validDays))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AcceptPolicy lets the owner of the car pay the premium of the policy
+func (c *CarsClient) AcceptPolicy(carId string, policyId string, options ...CallOption) error {
+	return c.submit(nil, options, "AcceptPolicy", carId, policyId)
+}
+
+// GetPolicy returns the policy of the car
+func (c *CarsClient) GetPolicy(carId string, policyId string, options ...CallOption) (*Policy, error) {
+	var result Policy
+	err := c.evaluate(&result, options, "GetPolicy", carId, policyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPoliciesForCar returns every policy of the car
+func (c *CarsClient) GetPoliciesForCar(carId string, options ...CallOption) ([]*Policy, error) {
+	var result []*Policy
+	err := c.evaluate(&result, options, "GetPoliciesForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// FileClaim claims the repair of the malfunction under the policy
+func (c *CarsClient) FileClaim(carId string, policyId string, malfunctionId string, options ...CallOption) (*Claim, error) {
+	var result Claim
+	err := c.submit(&result, options, "FileClaim", carId, policyId, malfunctionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ApproveClaim lets the insurer approve the claim
+func (c *CarsClient) ApproveClaim(carId string, claimId string, options ...CallOption) (*Claim, error) {
+	var result Claim
+	err := c.submit(&result, options, "ApproveClaim", carId, claimId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DenyClaim lets the insurer deny the claim
+func (c *CarsClient) DenyClaim(carId string, claimId string, options ...CallOption) error {
+	return c.submit(nil, options, "DenyClaim", carId, claimId)
+}
+
+// GetClaimsForCar returns every claim for the car
+func (c *CarsClient) GetClaimsForCar(carId string, options ...CallOption) ([]*Claim, error) {
+	var result []*Claim
+	err := c.evaluate(&result, options, "GetClaimsForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,69 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// OfferLease proposes to lease the car to the lessee, given by key such as
+// OWNER1, between dates formatted as 2006-01-02 for the daily rate in minor
+// units
+func (c *CarsClient) OfferLease(carId string, lesseeId string, startDate string, endDate string, dailyRate int64, options ...CallOption) (*Lease, error) {
+	var result Lease
+	err := c.submit(&result, options, "OfferLease", carId, lesseeId, startDate, endDate, formatInt64(dailyRate))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AcceptLease lets the lessee pay the rent of the lease into escrow
+func (c *CarsClient) AcceptLease(carId string, leaseId string, options ...CallOption) error {
+	return c.submit(nil, options, "AcceptLease", carId, leaseId)
+}
+
+// ReturnCar lets the lessee hand the car back early, paying only for the
+// started days and getting the rest of the rent refunded
+func (c *CarsClient) ReturnCar(carId string, options ...CallOption) (*Lease, error) {
+	var result Lease
+	err := c.submit(&result, options, "ReturnCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SettleLease lets a co-owner of the car release the rent of an expired lease
+// from escrow
+func (c *CarsClient) SettleLease(carId string, leaseId string, options ...CallOption) (*Lease, error) {
+	var result Lease
+	err := c.submit(&result, options, "SettleLease", carId, leaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLease returns the lease of the car
+func (c *CarsClient) GetLease(carId string, leaseId string, options ...CallOption) (*Lease, error) {
+	var result Lease
+	err := c.evaluate(&result, options, "GetLease", carId, leaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLeasesForCar returns every lease of the car
+func (c *CarsClient) GetLeasesForCar(carId string, options ...CallOption) ([]*Lease, error) {
+	var result []*Lease
+	err := c.evaluate(&result, options, "GetLeasesForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
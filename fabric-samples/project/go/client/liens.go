@@ -0,0 +1,94 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// RegisterLender adds a lender of the submitting client's organization
+func (c *CarsClient) RegisterLender(lenderId string, name string, options ...CallOption) (*Lender, error) {
+	var result Lender
+	err := c.submit(&result, options, "RegisterLender", lenderId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLender returns the lender with the id
+func (c *CarsClient) GetLender(lenderId string, options ...CallOption) (*Lender, error) {
+	var result Lender
+	err := c.evaluate(&result, options, "GetLender", lenderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RegisterLien proposes a lien for a loan of the lender on the car, in minor
+// units of the car's currency
+func (c *CarsClient) RegisterLien(lenderId string, carId string, principal int64, outstanding int64, options ...CallOption) (*Lien, error) {
+	var result Lien
+	err := c.submit(&result, options, "RegisterLien", lenderId, carId, formatInt64(principal), formatInt64(outstanding))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AcceptLien lets the owner of the car agree to the proposed lien
+func (c *CarsClient) AcceptLien(carId string, lienId string, options ...CallOption) error {
+	return c.submit(nil, options, "AcceptLien", carId, lienId)
+}
+
+// RepayLien lets the owner of the car repay the amount in minor units of the
+// lien
+func (c *CarsClient) RepayLien(carId string, lienId string, amount int64, options ...CallOption) (*Lien, error) {
+	var result Lien
+	err := c.submit(&result, options, "RepayLien", carId, lienId, formatInt64(amount))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ReleaseLien lets the lender release the lien
+func (c *CarsClient) ReleaseLien(carId string, lienId string, options ...CallOption) error {
+	return c.submit(nil, options, "ReleaseLien", carId, lienId)
+}
+
+// GetLien returns the lien on the car
+func (c *CarsClient) GetLien(carId string, lienId string, options ...CallOption) (*Lien, error) {
+	var result Lien
+	err := c.evaluate(&result, options, "GetLien", carId, lienId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLiensForCar returns every lien on the car
+func (c *CarsClient) GetLiensForCar(carId string, options ...CallOption) ([]*Lien, error) {
+	var result []*Lien
+	err := c.evaluate(&result, options, "GetLiensForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetLiensByLender returns every lien of the lender
+func (c *CarsClient) GetLiensByLender(lenderId string, options ...CallOption) ([]*Lien, error) {
+	var result []*Lien
+	err := c.evaluate(&result, options, "GetLiensByLender", lenderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,76 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// ListCarForSale puts the car of the submitting owner up for sale at the
+// asking price in minor units
+func (c *CarsClient) ListCarForSale(carId string, askingPrice int64, options ...CallOption) error {
+	return c.submit(nil, options, "ListCarForSale", carId, formatInt64(askingPrice))
+}
+
+// CancelListing takes the car off sale and refunds the open offers
+func (c *CarsClient) CancelListing(carId string, options ...CallOption) error {
+	return c.submit(nil, options, "CancelListing", carId)
+}
+
+// GetListing returns the listing of the car
+func (c *CarsClient) GetListing(carId string, options ...CallOption) (*Listing, error) {
+	var result Listing
+	err := c.evaluate(&result, options, "GetListing", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllListings returns every car for sale
+func (c *CarsClient) GetAllListings(options ...CallOption) ([]*Listing, error) {
+	var result []*Listing
+	err := c.evaluate(&result, options, "GetAllListings")
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MakeOffer bids the amount in minor units for a listed car on behalf of the
+// buyer, given by key such as OWNER1
+func (c *CarsClient) MakeOffer(carId string, buyerId string, amount int64, options ...CallOption) (*Offer, error) {
+	var result Offer
+	err := c.submit(&result, options, "MakeOffer", carId, buyerId, formatInt64(amount))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AcceptOffer sells the car to the buyer of the offer
+func (c *CarsClient) AcceptOffer(carId string, offerId string, options ...CallOption) error {
+	return c.submit(nil, options, "AcceptOffer", carId, offerId)
+}
+
+// RejectOffer lets the owner of the car turn down the offer
+func (c *CarsClient) RejectOffer(carId string, offerId string, options ...CallOption) error {
+	return c.submit(nil, options, "RejectOffer", carId, offerId)
+}
+
+// WithdrawOffer lets the buyer take the offer back
+func (c *CarsClient) WithdrawOffer(carId string, offerId string, options ...CallOption) error {
+	return c.submit(nil, options, "WithdrawOffer", carId, offerId)
+}
+
+// GetOffersForCar returns every offer for the car
+func (c *CarsClient) GetOffersForCar(carId string, options ...CallOption) ([]*Offer, error) {
+	var result []*Offer
+	err := c.evaluate(&result, options, "GetOffersForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
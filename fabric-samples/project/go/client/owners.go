@@ -0,0 +1,173 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+const (
+	// ownerTransientKey is the transient field that carries the personal
+	// details of an owner into CreateOwner and UpdateOwner
+	ownerTransientKey = "owner"
+
+	// saltTransientKey is the transient field that carries the random salt
+	// the chaincode hashes the personal details of owners with
+	saltTransientKey = "salt"
+
+	// saltLength is the number of random bytes of a salt
+	saltLength = 32
+)
+
+// withSalt adds a fresh random salt to the transient map of the call
+func withSalt(options []CallOption) ([]CallOption, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return append([]CallOption{WithTransient(map[string][]byte{saltTransientKey: salt})}, options...), nil
+}
+
+// CreateOwner adds a new owner bound to the submitting client identity. The
+// details are passed in the transient map, so that they only end up in the
+// private collection, together with a random salt for their hash. An empty id takes the next free owner id. Only
+// identities with the cars.admin role may give the owner starting money.
+func (c *CarsClient) CreateOwner(id string, details OwnerDetails, options ...CallOption) (*Owner, error) {
+	detailsArg, err := jsonArg(details)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err = withSalt(append([]CallOption{WithTransient(map[string][]byte{ownerTransientKey: []byte(detailsArg)})}, options...))
+	if err != nil {
+		return nil, err
+	}
+
+	var result Owner
+	err = c.submit(&result, options, "CreateOwner", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UpdateOwner changes the name, surname and email of the owner to those of
+// the details, which are passed in the transient map with a new random salt
+func (c *CarsClient) UpdateOwner(ownerId string, details OwnerDetails, options ...CallOption) (*Owner, error) {
+	detailsArg, err := jsonArg(details)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err = withSalt(append([]CallOption{WithTransient(map[string][]byte{ownerTransientKey: []byte(detailsArg)})}, options...))
+	if err != nil {
+		return nil, err
+	}
+
+	var result Owner
+	err = c.submit(&result, options, "UpdateOwner", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DeleteOwner removes the owner, which must not own any cars
+func (c *CarsClient) DeleteOwner(ownerId string, options ...CallOption) error {
+	return c.submit(nil, options, "DeleteOwner", ownerId)
+}
+
+// OwnerExists tells whether an owner is stored under the key
+func (c *CarsClient) OwnerExists(ownerId string, options ...CallOption) (bool, error) {
+	var result bool
+	err := c.evaluate(&result, options, "OwnerExists", ownerId)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// GetOwnerById returns the owner with its private details, which only clients
+// of the organization of the owner can read
+func (c *CarsClient) GetOwnerById(ownerId string, options ...CallOption) (*Owner, error) {
+	var result Owner
+	err := c.evaluate(&result, options, "GetOwnerById", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetOwnerRecord returns the public part of the owner
+func (c *CarsClient) GetOwnerRecord(ownerId string, options ...CallOption) (*OwnerRecord, error) {
+	var result OwnerRecord
+	err := c.evaluate(&result, options, "GetOwnerRecord", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllOwners returns the owners of the submitting client's organization
+func (c *CarsClient) GetAllOwners(options ...CallOption) ([]*Owner, error) {
+	var result []*Owner
+	err := c.evaluate(&result, options, "GetAllOwners")
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// BindOwnerIdentity binds the owner to a client identity of the owner's
+// organization. It is called by an admin of that organization.
+func (c *CarsClient) BindOwnerIdentity(ownerId string, clientId string, mspId string, options ...CallOption) error {
+	return c.submit(nil, options, "BindOwnerIdentity", ownerId, clientId, mspId)
+}
+
+// GetPayments returns the payments due to the owner that it has not collected
+// yet, which any client can read
+func (c *CarsClient) GetPayments(ownerId string, options ...CallOption) ([]*Payment, error) {
+	var result []*Payment
+	err := c.evaluate(&result, options, "GetPayments", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DepositFunds moves the amount, in minor units of the owner's currency, from
+// the owner's balance into a payment, with which the owner pays for cars of
+// other organizations. It returns the owner.
+func (c *CarsClient) DepositFunds(ownerId string, amount int64, options ...CallOption) (*Owner, error) {
+	var result Owner
+	err := c.submit(&result, options, "DepositFunds", ownerId, formatInt64(amount))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CollectPayments adds the pending payments of the owner to its balance and
+// returns the owner
+func (c *CarsClient) CollectPayments(ownerId string, options ...CallOption) (*Owner, error) {
+	var result Owner
+	err := c.submit(&result, options, "CollectPayments", ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,62 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// IssueRecall lets a manufacturer recall the cars of the make and model built
+// from yearFrom to yearTo. A year of 0 leaves that end of the range open.
+func (c *CarsClient) IssueRecall(make string, model string, yearFrom int, yearTo int, description string, remedy string, severity string, options ...CallOption) (*Recall, error) {
+	var result Recall
+	err := c.submit(&result, options, "IssueRecall", make, model, itoa(yearFrom), itoa(yearTo), description, remedy, severity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetRecall returns the recall with the id
+func (c *CarsClient) GetRecall(recallId string, options ...CallOption) (*Recall, error) {
+	var result Recall
+	err := c.evaluate(&result, options, "GetRecall", recallId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ApplyRecall lets the owner of the car turn the notice of the recall into a
+// malfunction of the car that repair shops fix at no cost
+func (c *CarsClient) ApplyRecall(carId string, recallId string, options ...CallOption) (*Malfunction, error) {
+	var result Malfunction
+	err := c.submit(&result, options, "ApplyRecall", carId, recallId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetOpenRecallsForCar returns the recalls the car has not been repaired for
+func (c *CarsClient) GetOpenRecallsForCar(carId string, options ...CallOption) ([]*Recall, error) {
+	var result []*Recall
+	err := c.evaluate(&result, options, "GetOpenRecallsForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetOpenCarsForRecall returns the cars not yet repaired for the recall
+func (c *CarsClient) GetOpenCarsForRecall(recallId string, options ...CallOption) ([]*Car, error) {
+	var result []*Car
+	err := c.evaluate(&result, options, "GetOpenCarsForRecall", recallId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
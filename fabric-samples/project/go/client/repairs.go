@@ -0,0 +1,80 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// AddMalfunction reports a malfunction of the car, priced in minor units of
+// the car's currency
+func (c *CarsClient) AddMalfunction(carId string, description string, price int64, severity string, options ...CallOption) error {
+	return c.submit(nil, options, "AddMalfunction", carId, description, formatInt64(price), severity)
+}
+
+// GetMalfunctions returns every malfunction of the car
+func (c *CarsClient) GetMalfunctions(carId string, options ...CallOption) ([]Malfunction, error) {
+	var result []Malfunction
+	err := c.evaluate(&result, options, "GetMalfunctions", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// StartMalfunctionRepair lets the owner send one malfunction of the car to the
+// repair shop
+func (c *CarsClient) StartMalfunctionRepair(carId string, malfunctionId string, shopId string, options ...CallOption) error {
+	return c.submit(nil, options, "StartMalfunctionRepair", carId, malfunctionId, shopId)
+}
+
+// RepairMalfunction lets a mechanic of the shop repair one malfunction of the
+// car
+func (c *CarsClient) RepairMalfunction(carId string, malfunctionId string, options ...CallOption) error {
+	return c.submit(nil, options, "RepairMalfunction", carId, malfunctionId)
+}
+
+// SendCarToRepairShop lets the owner send every open malfunction of the car to
+// the repair shop
+func (c *CarsClient) SendCarToRepairShop(carId string, shopId string, options ...CallOption) error {
+	return c.submit(nil, options, "SendCarToRepairShop", carId, shopId)
+}
+
+// RepairCar lets a mechanic of the shop repair the malfunctions of the car
+// sent to the shop
+func (c *CarsClient) RepairCar(carId string, shopId string, options ...CallOption) error {
+	return c.submit(nil, options, "RepairCar", carId, shopId)
+}
+
+// RegisterRepairShop adds a repair shop of the submitting mechanic's
+// organization
+func (c *CarsClient) RegisterRepairShop(shopId string, name string, options ...CallOption) (*RepairShop, error) {
+	var result RepairShop
+	err := c.submit(&result, options, "RegisterRepairShop", shopId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetRepairShop returns the repair shop with the id
+func (c *CarsClient) GetRepairShop(shopId string, options ...CallOption) (*RepairShop, error) {
+	var result RepairShop
+	err := c.evaluate(&result, options, "GetRepairShop", shopId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllRepairShops returns every repair shop
+func (c *CarsClient) GetAllRepairShops(options ...CallOption) ([]*RepairShop, error) {
+	var result []*RepairShop
+	err := c.evaluate(&result, options, "GetAllRepairShops")
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+// SetLawEnforcementOrg configures the organization whose clients report stolen
+// cars
+func (c *CarsClient) SetLawEnforcementOrg(mspId string, options ...CallOption) error {
+	return c.submit(nil, options, "SetLawEnforcementOrg", mspId)
+}
+
+// ReportStolen opens a police case about the stolen car
+func (c *CarsClient) ReportStolen(carId string, caseNumber string, options ...CallOption) (*TheftReport, error) {
+	var result TheftReport
+	err := c.submit(&result, options, "ReportStolen", carId, caseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ReportRecovered closes the police case of the recovered car
+func (c *CarsClient) ReportRecovered(carId string, caseNumber string, options ...CallOption) (*TheftReport, error) {
+	var result TheftReport
+	err := c.submit(&result, options, "ReportRecovered", carId, caseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetTheftReport returns the police case of the car
+func (c *CarsClient) GetTheftReport(carId string, caseNumber string, options ...CallOption) (*TheftReport, error) {
+	var result TheftReport
+	err := c.evaluate(&result, options, "GetTheftReport", carId, caseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetTheftReportsForCar returns every police case of the car
+func (c *CarsClient) GetTheftReportsForCar(carId string, options ...CallOption) ([]*TheftReport, error) {
+	var result []*TheftReport
+	err := c.evaluate(&result, options, "GetTheftReportsForCar", carId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// IsStolen tells whether the car is reported stolen
+func (c *CarsClient) IsStolen(carId string, options ...CallOption) (bool, error) {
+	var result bool
+	err := c.evaluate(&result, options, "IsStolen", carId)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
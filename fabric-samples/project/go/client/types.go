@@ -0,0 +1,435 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statuses of cars
+const (
+	CarActive   = "ACTIVE"
+	CarForSale  = "FOR_SALE"
+	CarInRepair = "IN_REPAIR"
+	CarStolen   = "STOLEN"
+	CarScrapped = "SCRAPPED"
+)
+
+// Severities and statuses of malfunctions
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+
+	MalfunctionOpen     = "OPEN"
+	MalfunctionInRepair = "IN_REPAIR"
+	MalfunctionRepaired = "REPAIRED"
+)
+
+// Types of the events the chaincode emits
+const (
+	EventCarCreated        = "CarCreated"
+	EventCarColorChanged   = "CarColorChanged"
+	EventMalfunctionAdded  = "MalfunctionAdded"
+	EventCarRepaired       = "CarRepaired"
+	EventOwnershipTransfer = "CarOwnershipTransferred"
+	EventCarDeleted        = "CarDeleted"
+	EventCarScrapped       = "CarScrapped"
+	EventMileageRecorded   = "MileageRecorded"
+	EventCarStolen         = "CarStolen"
+	EventCarRecovered      = "CarRecovered"
+	EventCarLeased         = "CarLeased"
+	EventCarReturned       = "CarReturned"
+)
+
+// Amount is an exact amount of money in minor units (cents) of its currency
+type Amount struct {
+	Value    int64  `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// NewAmount returns the amount of minor units in the currency
+func NewAmount(value int64, currency string) Amount {
+	return Amount{Value: value, Currency: currency}
+}
+
+func (a Amount) String() string {
+	sign := ""
+	value := a.Value
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	return fmt.Sprintf("%s%d.%02d %s", sign, value/100, value%100, a.Currency)
+}
+
+// Car is a car as the chaincode stores it. Owner is the id of its owner,
+// whose key is OWNER followed by the id.
+type Car struct {
+	DocType          string            `json:"docType"`
+	Id               int               `json:"id"`
+	Vin              string            `json:"vin"`
+	Make             string            `json:"make"`
+	Model            string            `json:"model"`
+	Year             int               `json:"year"`
+	Mileage          int               `json:"mileage"`
+	Color            string            `json:"color"`
+	Owner            string            `json:"owner"`
+	Status           string            `json:"status"`
+	Malfunctions     []Malfunction     `json:"malfunctions"`
+	Price            Amount            `json:"price"`
+	ApprovedBuyer    string            `json:"approvedBuyer,omitempty"`
+	OdometerReadings []OdometerReading `json:"odometerReadings"`
+	MileageTampered  bool              `json:"mileageTampered"`
+	Shares           []OwnerShare      `json:"shares,omitempty"`
+	SaleMajority     int               `json:"saleMajority,omitempty"`
+	SaleConsents     []string          `json:"saleConsents,omitempty"`
+	ProposedShares   *SharesProposal   `json:"proposedShares,omitempty"`
+}
+
+// Malfunction is a malfunction reported for a car
+type Malfunction struct {
+	Id          string    `json:"id"`
+	Description string    `json:"description"`
+	Price       Amount    `json:"price"`
+	Severity    string    `json:"severity"`
+	Status      string    `json:"status"`
+	ReportedAt  time.Time `json:"reportedAt"`
+	ReportedBy  string    `json:"reportedBy"`
+	ShopId      string    `json:"shopId,omitempty"`
+	RepairedAt  time.Time `json:"repairedAt"`
+	RecallId    string    `json:"recallId,omitempty"`
+}
+
+// OdometerReading is the mileage of a car as submitted by its owner or a
+// mechanic at the time of the transaction
+type OdometerReading struct {
+	Mileage    int       `json:"mileage"`
+	RecordedAt time.Time `json:"recordedAt"`
+	RecordedBy string    `json:"recordedBy"`
+	Suspicious bool      `json:"suspicious"`
+}
+
+// OwnerShare is the percentage of a car held by one of its co-owners
+type OwnerShare struct {
+	Owner   string `json:"owner"`
+	Percent int    `json:"percent"`
+}
+
+// SharesProposal is a split of a car among co-owners that its owner proposed.
+// It takes effect once every co-owner it grants a share to accepted it.
+type SharesProposal struct {
+	ProposedBy   string       `json:"proposedBy"`
+	Shares       []OwnerShare `json:"shares"`
+	SaleMajority int          `json:"saleMajority"`
+	AcceptedBy   []string     `json:"acceptedBy"`
+}
+
+// String lists the proposed shares and the co-owners who accepted theirs,
+// so that a proposal fits in a single cell of a table
+func (p SharesProposal) String() string {
+	shares := make([]string, 0, len(p.Shares))
+	for _, share := range p.Shares {
+		shares = append(shares, fmt.Sprintf("%s=%d", share.Owner, share.Percent))
+	}
+
+	return fmt.Sprintf("%s (accepted by %s)", strings.Join(shares, " "), strings.Join(p.AcceptedBy, ","))
+}
+
+// OwnedCar is a car together with the share of it held by an owner
+type OwnedCar struct {
+	Car     *Car `json:"car"`
+	Percent int  `json:"percent"`
+}
+
+// Owner is an owner together with the private details only clients of its
+// organization can read
+type Owner struct {
+	DocType  string `json:"docType"`
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+	Email    string `json:"email"`
+	Money    Amount `json:"money"`
+	ClientId string `json:"clientId"`
+	MSPID    string `json:"mspId"`
+	Salt     string `json:"salt"`
+}
+
+// OwnerRecord is the public part of an owner kept in world state. Only
+// clients of the organization in MSPID can read the private details, kept in
+// Collection, whose SHA-256 hash is in Hash. ClientIdHash is the SHA-256
+// hash of the client identity bound to the owner.
+type OwnerRecord struct {
+	DocType      string `json:"docType"`
+	Id           int    `json:"id"`
+	MSPID        string `json:"mspId"`
+	Collection   string `json:"collection"`
+	Hash         string `json:"hash"`
+	ClientIdHash string `json:"clientIdHash,omitempty"`
+}
+
+// Reasons of payments to owners
+const (
+	PaymentSale    = "SALE"
+	PaymentRefund  = "REFUND"
+	PaymentRent    = "RENT"
+	PaymentDeposit = "DEPOSIT"
+)
+
+// Payment is money held for an owner in world state, such as the proceeds of
+// a sale or funds deposited with DepositFunds, that the owner has not added
+// to their balance with CollectPayments yet
+type Payment struct {
+	DocType string `json:"docType"`
+	Owner   string `json:"owner"`
+	TxId    string `json:"txId"`
+	Reason  string `json:"reason"`
+	Amount  Amount `json:"amount"`
+}
+
+// OwnerDetails are the personal details of an owner, which CreateOwner and
+// UpdateOwner pass in the transient map. Money is in minor units of the
+// currency and only taken by CreateOwner.
+type OwnerDetails struct {
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+	Email    string `json:"email"`
+	Money    int64  `json:"money"`
+	Currency string `json:"currency"`
+}
+
+// CarHistory is the vehicle history report of a car
+type CarHistory struct {
+	CarId    string             `json:"carId"`
+	Records  []CarHistoryRecord `json:"records"`
+	Timeline []CarHistoryEvent  `json:"timeline"`
+}
+
+// CarHistoryRecord is one version of a car as written by a transaction
+type CarHistoryRecord struct {
+	Record    *Car      `json:"record"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// CarHistoryEvent is a single change between two versions of a car
+type CarHistoryEvent struct {
+	Type        string    `json:"type"`
+	TxId        string    `json:"txId"`
+	Timestamp   time.Time `json:"timestamp"`
+	From        string    `json:"from,omitempty"`
+	To          string    `json:"to,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Amount      Amount    `json:"amount"`
+}
+
+// CarEvent is the payload of every car chaincode event
+type CarEvent struct {
+	Type     string `json:"type"`
+	CarId    string `json:"carId"`
+	OldValue string `json:"oldValue,omitempty"`
+	NewValue string `json:"newValue,omitempty"`
+	Amount   Amount `json:"amount"`
+	TxId     string `json:"txId"`
+}
+
+// Listing puts a car up for sale at the asking price of its owner
+type Listing struct {
+	DocType     string `json:"docType"`
+	CarId       string `json:"carId"`
+	Seller      string `json:"seller"`
+	AskingPrice Amount `json:"askingPrice"`
+}
+
+// Offer is a bid of a buyer for a listed car. The offered amount is held in
+// escrow, taken from the buyer's money, for as long as the offer is open.
+type Offer struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	CarId   string `json:"carId"`
+	Buyer   string `json:"buyer"`
+	Amount  Amount `json:"amount"`
+	Status  string `json:"status"`
+}
+
+// RepairShop belongs to a mechanic organization and is paid for the repairs
+// its mechanics perform
+type RepairShop struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// Insurer belongs to an insurer organization. It collects the premiums of its
+// policies and pays the approved claims.
+type Insurer struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// Policy insures a car of its owner up to the coverage limit. Every claim
+// is reduced by the deductible, and Covered is the part of the coverage limit
+// already promised to approved claims.
+type Policy struct {
+	DocType       string    `json:"docType"`
+	Id            string    `json:"id"`
+	CarId         string    `json:"carId"`
+	InsurerId     string    `json:"insurerId"`
+	OwnerId       string    `json:"ownerId"`
+	CoverageLimit Amount    `json:"coverageLimit"`
+	Deductible    Amount    `json:"deductible"`
+	Premium       Amount    `json:"premium"`
+	Covered       Amount    `json:"covered"`
+	ValidFrom     time.Time `json:"validFrom"`
+	ValidUntil    time.Time `json:"validUntil"`
+	Status        string    `json:"status"`
+}
+
+// Claim asks the insurer of a policy to pay for the repair of a malfunction
+type Claim struct {
+	DocType       string `json:"docType"`
+	Id            string `json:"id"`
+	CarId         string `json:"carId"`
+	PolicyId      string `json:"policyId"`
+	MalfunctionId string `json:"malfunctionId"`
+	Payout        Amount `json:"payout"`
+	Status        string `json:"status"`
+}
+
+// Lender belongs to a bank organization that finances cars
+type Lender struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+	Money   Amount `json:"money"`
+}
+
+// Lien secures the loan of a lender on a car. A lien is proposed by the
+// lender and takes effect once the owner of the car accepts it. A car with an
+// active lien can only be sold when the price pays off the outstanding
+// balance.
+type Lien struct {
+	DocType      string    `json:"docType"`
+	Id           string    `json:"id"`
+	CarId        string    `json:"carId"`
+	LenderId     string    `json:"lenderId"`
+	OwnerId      string    `json:"ownerId"`
+	Principal    Amount    `json:"principal"`
+	Outstanding  Amount    `json:"outstanding"`
+	Status       string    `json:"status"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// Recall is a campaign of a manufacturer to fix a defect of a model. Every
+// matching car gets a notice of the recall, which its owner turns into a
+// malfunction with ApplyRecall for repair shops to fix at no cost.
+type Recall struct {
+	DocType     string    `json:"docType"`
+	Id          string    `json:"id"`
+	Make        string    `json:"make"`
+	Model       string    `json:"model"`
+	YearFrom    int       `json:"yearFrom,omitempty"`
+	YearTo      int       `json:"yearTo,omitempty"`
+	Description string    `json:"description"`
+	Remedy      string    `json:"remedy"`
+	Severity    string    `json:"severity"`
+	MSPID       string    `json:"mspId"`
+	IssuedAt    time.Time `json:"issuedAt"`
+}
+
+// TheftReport is a police case about a stolen car. RecoveredAt is zero
+// while the car is still missing.
+type TheftReport struct {
+	DocType     string    `json:"docType"`
+	CarId       string    `json:"carId"`
+	CaseNumber  string    `json:"caseNumber"`
+	MSPID       string    `json:"mspId"`
+	ReportedAt  time.Time `json:"reportedAt"`
+	ReportedBy  string    `json:"reportedBy"`
+	RecoveredAt time.Time `json:"recoveredAt"`
+}
+
+// Lease rents a car out to a lessee from the start date to the end date, for
+// a rate per day paid to the owner of the car. Once the lease is accepted the
+// owner can no longer sell the car, and while it is in force, from its start
+// date, the lessee has custody of the car and can report its malfunctions and
+// the owner can not recolor it. The rent of the whole term is held in Escrow
+// until the lease ends, when the lessee is Charged for the days they had the
+// car and refunded the rest.
+type Lease struct {
+	DocType    string       `json:"docType"`
+	Id         string       `json:"id"`
+	CarId      string       `json:"carId"`
+	Lessee     string       `json:"lessee"`
+	Lessor     string       `json:"lessor"`
+	StartDate  time.Time    `json:"startDate"`
+	EndDate    time.Time    `json:"endDate"`
+	DailyRate  Amount       `json:"dailyRate"`
+	Rent       Amount       `json:"rent"`
+	Status     string       `json:"status"`
+	ReturnedAt time.Time    `json:"returnedAt"`
+	Shares     []OwnerShare `json:"shares,omitempty"`
+	Escrow     Amount       `json:"escrow"`
+	Charged    Amount       `json:"charged"`
+}
+
+// InspectionStation belongs to an inspector organization and certifies that
+// cars are roadworthy
+type InspectionStation struct {
+	DocType string `json:"docType"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	MSPID   string `json:"mspId"`
+}
+
+// Defect is a defect found by an inspection, with its price of repair in
+// cents of the car's currency
+type Defect struct {
+	Description string `json:"description"`
+	Price       int64  `json:"price"`
+	Severity    string `json:"severity"`
+}
+
+// Certificate is the outcome of a roadworthiness inspection. The defects
+// found are added to the car as malfunctions, whose ids the certificate
+// keeps. A passing certificate is valid until it expires.
+type Certificate struct {
+	DocType        string    `json:"docType"`
+	Id             string    `json:"id"`
+	CarId          string    `json:"carId"`
+	StationId      string    `json:"stationId"`
+	Passed         bool      `json:"passed"`
+	Mileage        int       `json:"mileage"`
+	MalfunctionIds []string  `json:"malfunctionIds"`
+	IssuedAt       time.Time `json:"issuedAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// IndexReport lists the entries missing from the secondary indexes of cars
+// and the stale entries that do not match any car
+type IndexReport struct {
+	Cars    int          `json:"cars"`
+	Missing []IndexEntry `json:"missing"`
+	Stale   []IndexEntry `json:"stale"`
+}
+
+// IndexEntry is an entry of a secondary index, given by the index name and
+// its attributes, the last of which is the car id
+type IndexEntry struct {
+	Index      string   `json:"index"`
+	Attributes []string `json:"attributes"`
+}
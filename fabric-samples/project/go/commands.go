@@ -0,0 +1,722 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fabcar/client"
+)
+
+// ownerKeyPrefix starts the key of every owner, followed by its numeric id
+const ownerKeyPrefix = "OWNER"
+
+// runFunc runs a command with its positional arguments and returns the result
+// to print, nil if the transaction has none
+type runFunc func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error)
+
+// command is a subcommand of the tool, such as car get. The positional
+// arguments are named in args, and a last name ending in ... takes any number
+// of arguments. setup defines the flags of the command and returns the
+// function that runs it.
+type command struct {
+	group string
+	name  string
+	args  []string
+	help  string
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// noFlags is the setup of commands that take no flags
+func noFlags(run runFunc) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return run
+	}
+}
+
+// usage returns the synopsis of the command
+func (c command) usage() string {
+	synopsis := c.group + " " + c.name
+	for _, arg := range c.args {
+		synopsis += " <" + arg + ">"
+	}
+
+	return synopsis
+}
+
+// checkArgs makes sure that the command got as many arguments as it names
+func (c command) checkArgs(args []string) error {
+	want := len(c.args)
+	variadic := want > 0 && strings.HasSuffix(c.args[want-1], "...")
+	if variadic && len(args) >= want-1 || len(args) == want {
+		return nil
+	}
+
+	return fmt.Errorf("usage: cars %s", c.usage())
+}
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func intArg(name string, value string) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s must be a whole number", name, value)
+	}
+
+	return i, nil
+}
+
+func amountArg(name string, value string) (int64, error) {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s must be a whole number of cents", name, value)
+	}
+
+	return i, nil
+}
+
+func boolArg(name string, value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s %s must be true or false", name, value)
+	}
+
+	return b, nil
+}
+
+// ownerIdArg returns the numeric id of an owner given by key, such as
+// OWNER1, or by the id itself
+func ownerIdArg(value string) (string, error) {
+	id := strings.TrimPrefix(strings.ToUpper(value), ownerKeyPrefix)
+	n, err := strconv.Atoi(id)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("owner %s must be given by key, such as %s1, or by its numeric id", value, ownerKeyPrefix)
+	}
+
+	return strconv.Itoa(n), nil
+}
+
+// ownerKeyArg returns the key, such as OWNER1, of an owner given by key or by
+// its numeric id
+func ownerKeyArg(value string) (string, error) {
+	id, err := ownerIdArg(value)
+	if err != nil {
+		return "", err
+	}
+
+	return ownerKeyPrefix + id, nil
+}
+
+// shareArgs parses co-owner shares given as owner=percent, such as OWNER2=60
+func shareArgs(args []string) ([]client.OwnerShare, error) {
+	shares := make([]client.OwnerShare, 0, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("share %s must be given as owner=percent", arg)
+		}
+
+		percent, err := intArg("percent", parts[1])
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, client.OwnerShare{Owner: parts[0], Percent: percent})
+	}
+
+	return shares, nil
+}
+
+// defectArgs parses inspection defects given as description:price:severity
+func defectArgs(args []string) ([]client.Defect, error) {
+	defects := make([]client.Defect, 0, len(args))
+	for _, arg := range args {
+		parts := strings.Split(arg, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("defect %s must be given as description:price:severity", arg)
+		}
+
+		price, err := amountArg("price", parts[1])
+		if err != nil {
+			return nil, err
+		}
+		defects = append(defects, client.Defect{Description: parts[0], Price: price, Severity: parts[2]})
+	}
+
+	return defects, nil
+}
+
+// commands lists every command of the tool, one or more for each transaction
+// of the cars chaincode
+func commands() []command {
+	return []command{
+		{group: "car", name: "register", args: []string{"vin", "make", "model", "year", "mileage", "color", "price", "ownerId"},
+			help: "register a car of the owner, such as OWNER1, priced in cents",
+			setup: func(fs *flag.FlagSet) runFunc {
+				id := fs.String("id", "", "id of the car, the next free id if empty")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					year, err := intArg("year", args[3])
+					if err != nil {
+						return nil, err
+					}
+					mileage, err := intArg("mileage", args[4])
+					if err != nil {
+						return nil, err
+					}
+					price, err := amountArg("price", args[6])
+					if err != nil {
+						return nil, err
+					}
+					return cars.RegisterCar(*id, args[0], args[1], args[2], year, mileage, args[5], price, args[7], options...)
+				}
+			}},
+		{group: "car", name: "get", args: []string{"carId"}, help: "show a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetCarById(args[0], options...)
+			})},
+		{group: "car", name: "exists", args: []string{"carId"}, help: "tell whether a car exists",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.CarExists(args[0], options...)
+			})},
+		{group: "car", name: "list", help: "list cars, all of them or those matching the flags",
+			setup: func(fs *flag.FlagSet) runFunc {
+				color := fs.String("color", "", "only cars of the color")
+				owner := fs.String("owner", "", "only cars of the owner, given by key such as OWNER1 or by numeric id")
+				carMake := fs.String("make", "", "only cars of the make, needs --model")
+				model := fs.String("model", "", "only cars of the model, needs --make")
+				status := fs.String("status", "", "only cars in the status")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					switch {
+					case *carMake != "" || *model != "":
+						if *carMake == "" || *model == "" || *color != "" || *owner != "" || *status != "" {
+							return nil, errors.New("--make and --model must be given together and without other filters")
+						}
+						return cars.GetCarsByMakeAndModel(*carMake, *model, options...)
+					case *status != "":
+						if *color != "" || *owner != "" {
+							return nil, errors.New("--status can not be combined with other filters")
+						}
+						return cars.GetCarsByStatus(*status, options...)
+					case *color != "" && *owner != "":
+						ownerId, err := ownerIdArg(*owner)
+						if err != nil {
+							return nil, err
+						}
+						return cars.GetCarsByColorAndOwner(*color, ownerId, options...)
+					case *color != "":
+						return cars.GetCarsByColor(*color, options...)
+					case *owner != "":
+						ownerKey, err := ownerKeyArg(*owner)
+						if err != nil {
+							return nil, err
+						}
+						return cars.GetCarsByOwner(ownerKey, options...)
+					default:
+						return cars.GetAllCars(options...)
+					}
+				}
+			}},
+		{group: "car", name: "history", args: []string{"carId"}, help: "show the history report of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetCarHistory(args[0], options...)
+			})},
+		{group: "car", name: "endorsers", args: []string{"carId"}, help: "list the organizations that endorse changes to a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetCarEndorsingOrgs(args[0], options...)
+			})},
+		{group: "car", name: "recolor", args: []string{"carId", "color"}, help: "change the color of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.ChangeCarColor(args[0], args[1], options...)
+			})},
+		{group: "car", name: "mileage", args: []string{"carId", "mileage"}, help: "record an odometer reading of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				mileage, err := intArg("mileage", args[1])
+				if err != nil {
+					return nil, err
+				}
+				return nil, cars.RecordMileage(args[0], mileage, options...)
+			})},
+		{group: "car", name: "approve", args: []string{"carId", "newOwner"}, help: "approve the buyer, such as OWNER1, that may take over a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.ApproveTransfer(args[0], args[1], options...)
+			})},
+		{group: "car", name: "transfer", args: []string{"carId", "newOwner"}, help: "take over an approved car and pay its price",
+			setup: func(fs *flag.FlagSet) runFunc {
+				acceptsMalfunctions := fs.Bool("accept-malfunctions", false, "take the car with its open malfunctions, at its price less theirs")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					return nil, cars.TransferOwnership(args[0], args[1], *acceptsMalfunctions, options...)
+				}
+			}},
+		{group: "car", name: "delete", args: []string{"carId"}, help: "scrap a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.DeleteCar(args[0], options...)
+			})},
+		{group: "car", name: "shares", args: []string{"carId", "saleMajority", "owner=percent..."}, help: "propose to split a car among co-owners, given by key such as OWNER2=60",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				saleMajority, err := intArg("sale majority", args[1])
+				if err != nil {
+					return nil, err
+				}
+				shares, err := shareArgs(args[2:])
+				if err != nil {
+					return nil, err
+				}
+				return nil, cars.SetCarShares(args[0], shares, saleMajority, options...)
+			})},
+		{group: "car", name: "accept-shares", args: []string{"carId"}, help: "accept the share of a car proposed to the identity's owner",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.AcceptCarShares(args[0], options...)
+			})},
+		{group: "car", name: "send-to-shop", args: []string{"carId", "shopId"}, help: "send every open malfunction of a car to a repair shop",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.SendCarToRepairShop(args[0], args[1], options...)
+			})},
+		{group: "car", name: "repair", args: []string{"carId", "shopId"}, help: "repair the malfunctions of a car sent to the shop",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.RepairCar(args[0], args[1], options...)
+			})},
+
+		{group: "owner", name: "create", help: "create an owner bound to the submitting identity",
+			setup: func(fs *flag.FlagSet) runFunc {
+				id := fs.String("id", "", "id of the owner, the next free id if empty")
+				details := ownerDetailsFlags(fs)
+				money := fs.Int64("money", 0, "starting money of the owner in cents, only for cars.admin identities")
+				currency := fs.String("currency", "EUR", "currency of the owner's money")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					details.Money = *money
+					details.Currency = *currency
+					return cars.CreateOwner(*id, *details, options...)
+				}
+			}},
+		{group: "owner", name: "update", args: []string{"ownerId"}, help: "change the name, surname and email of an owner",
+			setup: func(fs *flag.FlagSet) runFunc {
+				details := ownerDetailsFlags(fs)
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					return cars.UpdateOwner(args[0], *details, options...)
+				}
+			}},
+		{group: "owner", name: "delete", args: []string{"ownerId"}, help: "delete an owner without cars",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.DeleteOwner(args[0], options...)
+			})},
+		{group: "owner", name: "get", args: []string{"ownerId"}, help: "show an owner with its private details",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetOwnerById(args[0], options...)
+			})},
+		{group: "owner", name: "record", args: []string{"ownerId"}, help: "show the public record of an owner",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetOwnerRecord(args[0], options...)
+			})},
+		{group: "owner", name: "exists", args: []string{"ownerId"}, help: "tell whether an owner exists",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.OwnerExists(args[0], options...)
+			})},
+		{group: "owner", name: "list", help: "list the owners the identity can read",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetAllOwners(options...)
+			})},
+		{group: "owner", name: "bind", args: []string{"ownerId", "clientId", "mspId"}, help: "bind an owner to a client identity",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.BindOwnerIdentity(args[0], args[1], args[2], options...)
+			})},
+		{group: "owner", name: "payments", args: []string{"ownerId"}, help: "list the payments an owner has not collected",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetPayments(args[0], options...)
+			})},
+		{group: "owner", name: "deposit", args: []string{"ownerId", "amount"}, help: "move money of an owner into a payment to buy cars of other organizations, in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				amount, err := amountArg("amount", args[1])
+				if err != nil {
+					return nil, err
+				}
+				return cars.DepositFunds(args[0], amount, options...)
+			})},
+		{group: "owner", name: "collect", args: []string{"ownerId"}, help: "add the pending payments of an owner to its money",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.CollectPayments(args[0], options...)
+			})},
+
+		{group: "malfunction", name: "add", args: []string{"carId", "description", "price", "severity"}, help: "report a malfunction of a car, priced in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				price, err := amountArg("price", args[2])
+				if err != nil {
+					return nil, err
+				}
+				return nil, cars.AddMalfunction(args[0], args[1], price, args[3], options...)
+			})},
+		{group: "malfunction", name: "list", args: []string{"carId"}, help: "list the malfunctions of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetMalfunctions(args[0], options...)
+			})},
+		{group: "malfunction", name: "start-repair", args: []string{"carId", "malfunctionId", "shopId"}, help: "send a malfunction to a repair shop",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.StartMalfunctionRepair(args[0], args[1], args[2], options...)
+			})},
+		{group: "malfunction", name: "repair", args: []string{"carId", "malfunctionId"}, help: "repair a malfunction sent to the shop",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.RepairMalfunction(args[0], args[1], options...)
+			})},
+
+		{group: "shop", name: "register", args: []string{"shopId", "name"}, help: "register a repair shop of the identity's organization",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.RegisterRepairShop(args[0], args[1], options...)
+			})},
+		{group: "shop", name: "get", args: []string{"shopId"}, help: "show a repair shop",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetRepairShop(args[0], options...)
+			})},
+		{group: "shop", name: "list", help: "list the repair shops",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetAllRepairShops(options...)
+			})},
+
+		{group: "listing", name: "create", args: []string{"carId", "askingPrice"}, help: "put a car up for sale, priced in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				askingPrice, err := amountArg("asking price", args[1])
+				if err != nil {
+					return nil, err
+				}
+				return nil, cars.ListCarForSale(args[0], askingPrice, options...)
+			})},
+		{group: "listing", name: "cancel", args: []string{"carId"}, help: "take a car off sale",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.CancelListing(args[0], options...)
+			})},
+		{group: "listing", name: "get", args: []string{"carId"}, help: "show the listing of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetListing(args[0], options...)
+			})},
+		{group: "listing", name: "list", help: "list the cars for sale",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetAllListings(options...)
+			})},
+
+		{group: "offer", name: "make", args: []string{"carId", "buyerId", "amount"}, help: "bid for a listed car on behalf of the buyer, in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				amount, err := amountArg("amount", args[2])
+				if err != nil {
+					return nil, err
+				}
+				return cars.MakeOffer(args[0], args[1], amount, options...)
+			})},
+		{group: "offer", name: "accept", args: []string{"carId", "offerId"}, help: "sell a car to the buyer of the offer",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.AcceptOffer(args[0], args[1], options...)
+			})},
+		{group: "offer", name: "reject", args: []string{"carId", "offerId"}, help: "turn down an offer",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.RejectOffer(args[0], args[1], options...)
+			})},
+		{group: "offer", name: "withdraw", args: []string{"carId", "offerId"}, help: "take an offer back",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.WithdrawOffer(args[0], args[1], options...)
+			})},
+		{group: "offer", name: "list", args: []string{"carId"}, help: "list the offers for a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetOffersForCar(args[0], options...)
+			})},
+
+		{group: "insurer", name: "register", args: []string{"insurerId", "name", "capital", "currency"}, help: "register an insurer of the identity's organization, with its capital in cents (0 unless the identity has cars.admin)",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				capital, err := amountArg("capital", args[2])
+				if err != nil {
+					return nil, err
+				}
+				return cars.RegisterInsurer(args[0], args[1], capital, args[3], options...)
+			})},
+		{group: "insurer", name: "get", args: []string{"insurerId"}, help: "show an insurer",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetInsurer(args[0], options...)
+			})},
+
+		{group: "policy", name: "issue", args: []string{"insurerId", "carId", "coverageLimit", "deductible", "premium", "validDays"}, help: "propose a policy for a car, amounts in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				coverageLimit, err := amountArg("coverage limit", args[2])
+				if err != nil {
+					return nil, err
+				}
+				deductible, err := amountArg("deductible", args[3])
+				if err != nil {
+					return nil, err
+				}
+				premium, err := amountArg("premium", args[4])
+				if err != nil {
+					return nil, err
+				}
+				validDays, err := intArg("valid days", args[5])
+				if err != nil {
+					return nil, err
+				}
+				return cars.IssuePolicy(args[0], args[1], coverageLimit, deductible, premium, validDays, options...)
+			})},
+		{group: "policy", name: "accept", args: []string{"carId", "policyId"}, help: "pay the premium of a proposed policy",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.AcceptPolicy(args[0], args[1], options...)
+			})},
+		{group: "policy", name: "get", args: []string{"carId", "policyId"}, help: "show a policy",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetPolicy(args[0], args[1], options...)
+			})},
+		{group: "policy", name: "list", args: []string{"carId"}, help: "list the policies of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetPoliciesForCar(args[0], options...)
+			})},
+
+		{group: "claim", name: "file", args: []string{"carId", "policyId", "malfunctionId"}, help: "claim the repair of a malfunction",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.FileClaim(args[0], args[1], args[2], options...)
+			})},
+		{group: "claim", name: "approve", args: []string{"carId", "claimId"}, help: "approve a claim",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.ApproveClaim(args[0], args[1], options...)
+			})},
+		{group: "claim", name: "deny", args: []string{"carId", "claimId"}, help: "deny a claim",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.DenyClaim(args[0], args[1], options...)
+			})},
+		{group: "claim", name: "list", args: []string{"carId"}, help: "list the claims for a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetClaimsForCar(args[0], options...)
+			})},
+
+		{group: "lender", name: "register", args: []string{"lenderId", "name"}, help: "register a lender of the identity's organization",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.RegisterLender(args[0], args[1], options...)
+			})},
+		{group: "lender", name: "get", args: []string{"lenderId"}, help: "show a lender",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetLender(args[0], options...)
+			})},
+
+		{group: "lien", name: "register", args: []string{"lenderId", "carId", "principal", "outstanding"}, help: "propose a lien for a loan on a car, amounts in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				principal, err := amountArg("principal", args[2])
+				if err != nil {
+					return nil, err
+				}
+				outstanding, err := amountArg("outstanding", args[3])
+				if err != nil {
+					return nil, err
+				}
+				return cars.RegisterLien(args[0], args[1], principal, outstanding, options...)
+			})},
+		{group: "lien", name: "accept", args: []string{"carId", "lienId"}, help: "agree to a lien proposed for a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.AcceptLien(args[0], args[1], options...)
+			})},
+		{group: "lien", name: "repay", args: []string{"carId", "lienId", "amount"}, help: "repay part of a lien, in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				amount, err := amountArg("amount", args[2])
+				if err != nil {
+					return nil, err
+				}
+				return cars.RepayLien(args[0], args[1], amount, options...)
+			})},
+		{group: "lien", name: "release", args: []string{"carId", "lienId"}, help: "withdraw a proposed lien or release an active one",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.ReleaseLien(args[0], args[1], options...)
+			})},
+		{group: "lien", name: "get", args: []string{"carId", "lienId"}, help: "show a lien",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetLien(args[0], args[1], options...)
+			})},
+		{group: "lien", name: "list", help: "list the liens on a car or of a lender",
+			setup: func(fs *flag.FlagSet) runFunc {
+				carId := fs.String("car", "", "only liens on the car")
+				lenderId := fs.String("lender", "", "only liens of the lender")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					if (*carId == "") == (*lenderId == "") {
+						return nil, errors.New("exactly one of --car and --lender must be given")
+					}
+					if *carId != "" {
+						return cars.GetLiensForCar(*carId, options...)
+					}
+					return cars.GetLiensByLender(*lenderId, options...)
+				}
+			}},
+
+		{group: "lease", name: "offer", args: []string{"carId", "lesseeId", "startDate", "endDate", "dailyRate"}, help: "propose to lease a car between dates formatted as 2006-01-02, rate in cents",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				dailyRate, err := amountArg("daily rate", args[4])
+				if err != nil {
+					return nil, err
+				}
+				return cars.OfferLease(args[0], args[1], args[2], args[3], dailyRate, options...)
+			})},
+		{group: "lease", name: "accept", args: []string{"carId", "leaseId"}, help: "pay the rent of a proposed lease into escrow",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.AcceptLease(args[0], args[1], options...)
+			})},
+		{group: "lease", name: "return", args: []string{"carId"}, help: "hand a leased car back",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.ReturnCar(args[0], options...)
+			})},
+		{group: "lease", name: "settle", args: []string{"carId", "leaseId"}, help: "release the rent of an expired lease to the car's owners",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.SettleLease(args[0], args[1], options...)
+			})},
+		{group: "lease", name: "get", args: []string{"carId", "leaseId"}, help: "show a lease",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetLease(args[0], args[1], options...)
+			})},
+		{group: "lease", name: "list", args: []string{"carId"}, help: "list the leases of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetLeasesForCar(args[0], options...)
+			})},
+
+		{group: "station", name: "register", args: []string{"stationId", "name"}, help: "register an inspection station of the identity's organization",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.RegisterInspectionStation(args[0], args[1], options...)
+			})},
+		{group: "station", name: "get", args: []string{"stationId"}, help: "show an inspection station",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetInspectionStation(args[0], options...)
+			})},
+
+		{group: "certificate", name: "issue", args: []string{"stationId", "carId", "validDays"}, help: "record an inspection of a car",
+			setup: func(fs *flag.FlagSet) runFunc {
+				failed := fs.Bool("failed", false, "the car failed the inspection")
+				var defects stringList
+				fs.Var(&defects, "defect", "defect found, as description:price:severity, may be repeated")
+				return func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+					validDays, err := intArg("valid days", args[2])
+					if err != nil {
+						return nil, err
+					}
+					found, err := defectArgs(defects)
+					if err != nil {
+						return nil, err
+					}
+					return cars.IssueCertificate(args[0], args[1], !*failed, found, validDays, options...)
+				}
+			}},
+		{group: "certificate", name: "get", args: []string{"carId", "certificateId"}, help: "show an inspection certificate",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetCertificate(args[0], args[1], options...)
+			})},
+		{group: "certificate", name: "list", args: []string{"carId"}, help: "list the inspection certificates of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetCertificatesForCar(args[0], options...)
+			})},
+		{group: "certificate", name: "expiring", args: []string{"days"}, help: "list the cars whose inspection expires within the days",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				days, err := intArg("days", args[0])
+				if err != nil {
+					return nil, err
+				}
+				return cars.GetCarsWithExpiringInspection(days, options...)
+			})},
+
+		{group: "recall", name: "issue", args: []string{"make", "model", "yearFrom", "yearTo", "description", "remedy", "severity"}, help: "recall the cars of a model, a year of 0 leaves the range open",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				yearFrom, err := intArg("year from", args[2])
+				if err != nil {
+					return nil, err
+				}
+				yearTo, err := intArg("year to", args[3])
+				if err != nil {
+					return nil, err
+				}
+				return cars.IssueRecall(args[0], args[1], yearFrom, yearTo, args[4], args[5], args[6], options...)
+			})},
+		{group: "recall", name: "get", args: []string{"recallId"}, help: "show a recall",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetRecall(args[0], options...)
+			})},
+		{group: "recall", name: "apply", args: []string{"carId", "recallId"}, help: "add the malfunction of a recall to a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.ApplyRecall(args[0], args[1], options...)
+			})},
+		{group: "recall", name: "open-for-car", args: []string{"carId"}, help: "list the recalls a car is not repaired for",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetOpenRecallsForCar(args[0], options...)
+			})},
+		{group: "recall", name: "open-cars", args: []string{"recallId"}, help: "list the cars not repaired for a recall",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetOpenCarsForRecall(args[0], options...)
+			})},
+
+		{group: "theft", name: "report", args: []string{"carId", "caseNumber"}, help: "report a car stolen",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.ReportStolen(args[0], args[1], options...)
+			})},
+		{group: "theft", name: "recover", args: []string{"carId", "caseNumber"}, help: "report a stolen car recovered",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.ReportRecovered(args[0], args[1], options...)
+			})},
+		{group: "theft", name: "get", args: []string{"carId", "caseNumber"}, help: "show a theft report",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetTheftReport(args[0], args[1], options...)
+			})},
+		{group: "theft", name: "list", args: []string{"carId"}, help: "list the theft reports of a car",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.GetTheftReportsForCar(args[0], options...)
+			})},
+		{group: "theft", name: "check", args: []string{"carId"}, help: "tell whether a car is reported stolen",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.IsStolen(args[0], options...)
+			})},
+
+		{group: "admin", name: "init", help: "put the sample owners and cars in world state",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.InitLedger(options...)
+			})},
+		{group: "admin", name: "police", args: []string{"mspId"}, help: "set the organization that reports stolen cars",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return nil, cars.SetLawEnforcementOrg(args[0], options...)
+			})},
+		{group: "admin", name: "require-inspection", args: []string{"required"}, help: "tell whether cars need a valid inspection to change owner",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				required, err := boolArg("required", args[0])
+				if err != nil {
+					return nil, err
+				}
+				return nil, cars.SetInspectionRequired(required, options...)
+			})},
+		{group: "admin", name: "migrate-amounts", args: []string{"currency"}, help: "convert amounts stored as numbers into cents of the currency",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.MigrateAmounts(args[0], options...)
+			})},
+		{group: "admin", name: "migrate-keys", help: "move cars to the CAR key namespace",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.MigrateKeys(options...)
+			})},
+		{group: "admin", name: "migrate-owners", help: "move the personal details of owners to the private collection",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.MigrateOwnerPrivateData(options...)
+			})},
+		{group: "admin", name: "verify-indexes", help: "report the drift of the secondary indexes of cars",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.VerifyIndexes(options...)
+			})},
+		{group: "admin", name: "rebuild-indexes", help: "repair the secondary indexes of cars",
+			setup: noFlags(func(cars *client.CarsClient, args []string, options []client.CallOption) (interface{}, error) {
+				return cars.RebuildIndexes(options...)
+			})},
+	}
+}
+
+// ownerDetailsFlags defines the flags of the personal details of an owner
+func ownerDetailsFlags(fs *flag.FlagSet) *client.OwnerDetails {
+	details := new(client.OwnerDetails)
+	fs.StringVar(&details.Name, "name", "", "name of the owner")
+	fs.StringVar(&details.Surname, "surname", "", "surname of the owner")
+	fs.StringVar(&details.Email, "email", "", "email address of the owner")
+
+	return details
+}
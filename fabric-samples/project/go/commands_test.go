@@ -0,0 +1,171 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"fabcar/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContract records the calls it is given and answers them with a fixed
+// payload
+type fakeContract struct {
+	calls   []client.Call
+	payload string
+}
+
+func (f *fakeContract) Invoke(call client.Call) ([]byte, error) {
+	f.calls = append(f.calls, call)
+	return []byte(f.payload), nil
+}
+
+// runCommandLine parses the command line of a command, without the global
+// flags, and runs it against the fake contract
+func runCommandLine(t *testing.T, contract *fakeContract, args ...string) (interface{}, error) {
+	cmd, ok := findCommand(args)
+	require.True(t, ok, "unknown command %v", args)
+
+	fs := flag.NewFlagSet(cmd.group+" "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	run := cmd.setup(fs)
+	err := fs.Parse(args[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.checkArgs(fs.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	return run(client.New(contract), fs.Args(), nil)
+}
+
+func TestCommandLines(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		call client.Call
+		err  string
+	}{
+		{
+			name: "all cars",
+			args: []string{"car", "list"},
+			call: client.Call{Name: "GetAllCars"},
+		},
+		{
+			name: "cars of an owner given by key",
+			args: []string{"car", "list", "--owner", "OWNER2"},
+			call: client.Call{Name: "GetCarsByOwner", Args: []string{"OWNER2"}},
+		},
+		{
+			name: "cars of an owner given by id",
+			args: []string{"car", "list", "--owner", "2"},
+			call: client.Call{Name: "GetCarsByOwner", Args: []string{"OWNER2"}},
+		},
+		{
+			name: "cars of a color and an owner given by key",
+			args: []string{"car", "list", "--color", "blue", "--owner", "owner1"},
+			call: client.Call{Name: "GetCarsByColorAndOwner", Args: []string{"blue", "1"}},
+		},
+		{
+			name: "cars of a color and an owner given by id",
+			args: []string{"car", "list", "--color", "blue", "--owner", "1"},
+			call: client.Call{Name: "GetCarsByColorAndOwner", Args: []string{"blue", "1"}},
+		},
+		{
+			name: "owner that is neither key nor id",
+			args: []string{"car", "list", "--color", "blue", "--owner", "Ana"},
+			err:  "owner Ana must be given by key, such as OWNER1, or by its numeric id",
+		},
+		{
+			name: "make without model",
+			args: []string{"car", "list", "--make", "VW"},
+			err:  "--make and --model must be given together and without other filters",
+		},
+		{
+			name: "status with another filter",
+			args: []string{"car", "list", "--status", "ACTIVE", "--color", "red"},
+			err:  "--status can not be combined with other filters",
+		},
+		{
+			name: "unknown flag",
+			args: []string{"car", "list", "--price", "100"},
+			err:  "flag provided but not defined: -price",
+		},
+		{
+			name: "missing argument",
+			args: []string{"car", "get"},
+			err:  "usage: cars car get <carId>",
+		},
+		{
+			name: "too many arguments",
+			args: []string{"car", "get", "1", "2"},
+			err:  "usage: cars car get <carId>",
+		},
+		{
+			name: "shares given as owner=percent",
+			args: []string{"car", "shares", "4", "100", "OWNER2=60", "OWNER3=40"},
+			call: client.Call{Name: "SetCarShares", Args: []string{"4", `[{"owner":"OWNER2","percent":60},{"owner":"OWNER3","percent":40}]`, "100"}, Submit: true},
+		},
+		{
+			name: "share without percent",
+			args: []string{"car", "shares", "4", "100", "OWNER2"},
+			err:  "share OWNER2 must be given as owner=percent",
+		},
+		{
+			name: "sale majority that is not a number",
+			args: []string{"car", "shares", "4", "most", "OWNER2=100"},
+			err:  "sale majority most must be a whole number",
+		},
+		{
+			name: "price in cents",
+			args: []string{"malfunction", "add", "6", "Broken door", "250000", "MEDIUM"},
+			call: client.Call{Name: "AddMalfunction", Args: []string{"6", "Broken door", "250000", "MEDIUM"}, Submit: true},
+		},
+		{
+			name: "price that is not in cents",
+			args: []string{"malfunction", "add", "6", "Broken door", "2500.00", "MEDIUM"},
+			err:  "price 2500.00 must be a whole number of cents",
+		},
+		{
+			name: "defect without severity",
+			args: []string{"certificate", "issue", "--defect", "Worn tires:8000", "station1", "4", "365"},
+			err:  "defect Worn tires:8000 must be given as description:price:severity",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &fakeContract{payload: "[]"}
+			_, err := runCommandLine(t, contract, test.args...)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				require.Empty(t, contract.calls)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, contract.calls, 1)
+			require.Equal(t, test.call.Name, contract.calls[0].Name)
+			require.Equal(t, test.call.Args, contract.calls[0].Args)
+			require.Equal(t, test.call.Submit, contract.calls[0].Submit)
+		})
+	}
+}
+
+func TestCommandsAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, cmd := range commands() {
+		usage := cmd.group + " " + cmd.name
+		require.False(t, seen[usage], "%s is defined twice", usage)
+		seen[usage] = true
+	}
+}
@@ -0,0 +1,209 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"fabcar/client"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// Config tells the command line tool which network, chaincode and identity
+// to use. It is read from the file given with --config, and flags given on
+// the command line take precedence over the file.
+type Config struct {
+	Channel     string              `json:"channel"`
+	Chaincode   string              `json:"chaincode"`
+	Profile     string              `json:"profile"`
+	MSPID       string              `json:"mspId"`
+	Wallet      string              `json:"wallet"`
+	Identity    string              `json:"identity"`
+	Credentials string              `json:"credentials"`
+	Output      string              `json:"output"`
+	Peers       map[string][]string `json:"peers"`
+}
+
+// defaultConfig runs as User1 of org4 on the test network
+func defaultConfig() Config {
+	org := filepath.Join("..", "..", "test-network", "organizations", "peerOrganizations", "org4.example.com")
+
+	return Config{
+		Channel:     "mychannel",
+		Chaincode:   "cars",
+		Profile:     filepath.Join(org, "connection-org4.yaml"),
+		MSPID:       "Org4MSP",
+		Wallet:      "wallet",
+		Identity:    "appUser",
+		Credentials: filepath.Join(org, "users", "User1@org4.example.com", "msp"),
+		Output:      outputTable,
+	}
+}
+
+// globalFlags are the flags given before the command
+type globalFlags struct {
+	set           *flag.FlagSet
+	configPath    string
+	config        Config
+	json          bool
+	table         bool
+	dryRun        bool
+	endorsingOrgs string
+}
+
+func newGlobalFlags() *globalFlags {
+	g := &globalFlags{set: flag.NewFlagSet("cars", flag.ContinueOnError)}
+	defaults := defaultConfig()
+
+	g.set.StringVar(&g.configPath, "config", "", "JSON file with the settings below, overridden by flags")
+	g.set.StringVar(&g.config.Channel, "channel", defaults.Channel, "channel the chaincode is deployed on")
+	g.set.StringVar(&g.config.Chaincode, "chaincode", defaults.Chaincode, "name of the cars chaincode")
+	g.set.StringVar(&g.config.Profile, "profile", defaults.Profile, "connection profile of the organization")
+	g.set.StringVar(&g.config.MSPID, "msp", defaults.MSPID, "MSP ID of the identity")
+	g.set.StringVar(&g.config.Wallet, "wallet", defaults.Wallet, "directory of the wallet")
+	g.set.StringVar(&g.config.Identity, "identity", defaults.Identity, "label of the identity in the wallet")
+	g.set.StringVar(&g.config.Credentials, "credentials", defaults.Credentials, "MSP directory the identity is imported from when it is not in the wallet")
+	g.set.BoolVar(&g.json, "json", false, "print results as JSON")
+	g.set.BoolVar(&g.table, "table", false, "print results as a table (default)")
+	g.set.BoolVar(&g.dryRun, "dry-run", false, "evaluate transactions without committing them")
+	g.set.StringVar(&g.endorsingOrgs, "endorsing-orgs", "", "comma separated MSP IDs of the organizations that endorse the transaction")
+
+	return g
+}
+
+// parse reads the flags, then the config file, and returns the settings in
+// effect. Flags given on the command line win over the config file, which
+// wins over the defaults.
+func (g *globalFlags) parse(args []string) (Config, error) {
+	err := g.set.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if g.json && g.table {
+		return Config{}, errors.New("--json and --table can not be combined")
+	}
+
+	cfg := defaultConfig()
+	if g.configPath != "" {
+		configAsBytes, err := ioutil.ReadFile(filepath.Clean(g.configPath))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config: %w", err)
+		}
+
+		err = json.Unmarshal(configAsBytes, &cfg)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse config %s: %w", g.configPath, err)
+		}
+	}
+
+	g.set.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "channel":
+			cfg.Channel = g.config.Channel
+		case "chaincode":
+			cfg.Chaincode = g.config.Chaincode
+		case "profile":
+			cfg.Profile = g.config.Profile
+		case "msp":
+			cfg.MSPID = g.config.MSPID
+		case "wallet":
+			cfg.Wallet = g.config.Wallet
+		case "identity":
+			cfg.Identity = g.config.Identity
+		case "credentials":
+			cfg.Credentials = g.config.Credentials
+		}
+	})
+
+	if g.json {
+		cfg.Output = outputJSON
+	}
+	if g.table {
+		cfg.Output = outputTable
+	}
+	if cfg.Output != outputJSON && cfg.Output != outputTable {
+		return Config{}, fmt.Errorf("output %s is not one of %s or %s", cfg.Output, outputJSON, outputTable)
+	}
+
+	return cfg, nil
+}
+
+// callOptions are the options every transaction of the command runs with
+func (g *globalFlags) callOptions() []client.CallOption {
+	var options []client.CallOption
+	if g.dryRun {
+		options = append(options, client.Evaluate())
+	}
+	if g.endorsingOrgs != "" {
+		options = append(options, client.WithEndorsingOrgs(strings.Split(g.endorsingOrgs, ",")...))
+	}
+
+	return options
+}
+
+// connect opens a gateway to the network of the config, importing the
+// identity into the wallet first if it is not there yet
+func connect(cfg Config) (*gateway.Gateway, error) {
+	wallet, err := gateway.NewFileSystemWallet(cfg.Wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	if !wallet.Exists(cfg.Identity) {
+		err = populateWallet(wallet, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to populate wallet contents: %w", err)
+		}
+	}
+
+	gw, err := gateway.Connect(
+		gateway.WithConfig(config.FromFile(filepath.Clean(cfg.Profile))),
+		gateway.WithIdentity(wallet, cfg.Identity),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+
+	return gw, nil
+}
+
+// populateWallet imports the certificate and private key of the MSP
+// directory in the config into the wallet
+func populateWallet(wallet *gateway.Wallet, cfg Config) error {
+	certPath := filepath.Join(cfg.Credentials, "signcerts", "cert.pem")
+	// read the certificate pem
+	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+	if err != nil {
+		return err
+	}
+
+	keyDir := filepath.Join(cfg.Credentials, "keystore")
+	// there's a single file in this dir containing the private key
+	files, err := ioutil.ReadDir(keyDir)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return errors.New("keystore folder should have contain one file")
+	}
+	keyPath := filepath.Join(keyDir, files[0].Name())
+	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	if err != nil {
+		return err
+	}
+
+	identity := gateway.NewX509Identity(cfg.MSPID, string(cert), string(key))
+
+	return wallet.Put(cfg.Identity, identity)
+}
@@ -0,0 +1,160 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fabcar/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "org1.json")
+	err = ioutil.WriteFile(configPath, []byte(`{"channel":"cars","mspId":"Org1MSP","identity":"ana","output":"json","peers":{"Org2MSP":["peer0.org2.example.com"]}}`), 0600)
+	require.NoError(t, err)
+
+	badOutputPath := filepath.Join(dir, "yaml.json")
+	err = ioutil.WriteFile(badOutputPath, []byte(`{"output":"yaml"}`), 0600)
+	require.NoError(t, err)
+
+	withConfig := func(change func(cfg *Config)) Config {
+		cfg := defaultConfig()
+		cfg.Channel = "cars"
+		cfg.MSPID = "Org1MSP"
+		cfg.Identity = "ana"
+		cfg.Output = outputJSON
+		cfg.Peers = map[string][]string{"Org2MSP": {"peer0.org2.example.com"}}
+		if change != nil {
+			change(&cfg)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		config Config
+		rest   []string
+		err    string
+	}{
+		{
+			name:   "defaults",
+			args:   []string{"car", "get", "1"},
+			config: defaultConfig(),
+			rest:   []string{"car", "get", "1"},
+		},
+		{
+			name: "flags over defaults",
+			args: []string{"--channel", "other", "--identity", "ivo", "car", "list"},
+			config: func() Config {
+				cfg := defaultConfig()
+				cfg.Channel = "other"
+				cfg.Identity = "ivo"
+				return cfg
+			}(),
+			rest: []string{"car", "list"},
+		},
+		{
+			name:   "config over defaults",
+			args:   []string{"--config", configPath, "car", "list"},
+			config: withConfig(nil),
+			rest:   []string{"car", "list"},
+		},
+		{
+			name:   "flags over config, wherever they are given",
+			args:   []string{"--msp", "Org2MSP", "--config", configPath, "--identity", "ivo", "car", "list"},
+			config: withConfig(func(cfg *Config) { cfg.MSPID = "Org2MSP"; cfg.Identity = "ivo" }),
+			rest:   []string{"car", "list"},
+		},
+		{
+			name:   "table over the output of the config",
+			args:   []string{"--config", configPath, "--table", "car", "list"},
+			config: withConfig(func(cfg *Config) { cfg.Output = outputTable }),
+			rest:   []string{"car", "list"},
+		},
+		{
+			name:   "json over the default output",
+			args:   []string{"--json", "car", "list"},
+			config: func() Config { cfg := defaultConfig(); cfg.Output = outputJSON; return cfg }(),
+			rest:   []string{"car", "list"},
+		},
+		{
+			name: "json and table",
+			args: []string{"--json", "--table", "car", "list"},
+			err:  "--json and --table can not be combined",
+		},
+		{
+			name: "unknown output in the config",
+			args: []string{"--config", badOutputPath, "car", "list"},
+			err:  "output yaml is not one of json or table",
+		},
+		{
+			name: "missing config",
+			args: []string{"--config", filepath.Join(dir, "missing.json"), "car", "list"},
+			err:  "failed to read config: open " + filepath.Join(dir, "missing.json") + ": no such file or directory",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newGlobalFlags()
+			cfg, err := g.parse(test.args)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.config, cfg)
+			require.Equal(t, test.rest, g.set.Args())
+		})
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		call client.Call
+	}{
+		{
+			name: "submitted",
+			args: []string{"car", "recolor", "1", "red"},
+			call: client.Call{Name: "ChangeCarColor", Args: []string{"1", "red"}, Submit: true},
+		},
+		{
+			name: "dry run",
+			args: []string{"--dry-run", "car", "recolor", "1", "red"},
+			call: client.Call{Name: "ChangeCarColor", Args: []string{"1", "red"}},
+		},
+		{
+			name: "endorsing organizations",
+			args: []string{"--endorsing-orgs", "Org1MSP,Org2MSP", "car", "recolor", "1", "red"},
+			call: client.Call{Name: "ChangeCarColor", Args: []string{"1", "red"}, Submit: true, EndorsingOrgs: []string{"Org1MSP", "Org2MSP"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newGlobalFlags()
+			_, err := g.parse(test.args)
+			require.NoError(t, err)
+
+			contract := &fakeContract{}
+			err = client.New(contract).ChangeCarColor("1", "red", g.callOptions()...)
+			require.NoError(t, err)
+			require.Equal(t, []client.Call{test.call}, contract.calls)
+		})
+	}
+}
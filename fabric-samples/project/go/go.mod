@@ -2,4 +2,7 @@ module fabcar
 
 go 1.14
 
-require github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+require (
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/stretchr/testify v1.5.1
+)
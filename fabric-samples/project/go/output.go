@@ -0,0 +1,220 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"fabcar/client"
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+// indexEntryRow is a row of the table of an index report
+type indexEntryRow struct {
+	Drift      string   `json:"drift"`
+	Index      string   `json:"index"`
+	Attributes []string `json:"attributes"`
+}
+
+// column is a column of a table, read from a field of the rows, which may be
+// nested in struct fields
+type column struct {
+	header string
+	index  []int
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// printResult prints the result of a transaction. Transactions without a
+// result print nothing as JSON and OK as a table.
+func printResult(w io.Writer, output string, result interface{}) error {
+	if output == outputJSON {
+		if result == nil {
+			return nil
+		}
+
+		resultAsBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(resultAsBytes))
+		return err
+	}
+
+	if result == nil {
+		_, err := fmt.Fprintln(w, "OK")
+		return err
+	}
+
+	return printTable(w, tableRows(result))
+}
+
+// tableRows picks what the table of a result shows. Structs are a single
+// row and slices a row per element, except for the history of a car, which
+// is its timeline, and an index report, which is its drifted entries.
+func tableRows(result interface{}) interface{} {
+	switch r := result.(type) {
+	case *client.CarHistory:
+		return r.Timeline
+	case *client.IndexReport:
+		rows := make([]indexEntryRow, 0, len(r.Missing)+len(r.Stale))
+		for _, entry := range r.Missing {
+			rows = append(rows, indexEntryRow{Drift: "missing", Index: entry.Index, Attributes: entry.Attributes})
+		}
+		for _, entry := range r.Stale {
+			rows = append(rows, indexEntryRow{Drift: "stale", Index: entry.Index, Attributes: entry.Attributes})
+		}
+		return rows
+	}
+
+	return result
+}
+
+func printTable(w io.Writer, rows interface{}) error {
+	value := reflect.ValueOf(rows)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	var elemType reflect.Type
+	var elems []reflect.Value
+	switch value.Kind() {
+	case reflect.Slice:
+		elemType = value.Type().Elem()
+		for i := 0; i < value.Len(); i++ {
+			elems = append(elems, value.Index(i))
+		}
+	case reflect.Struct:
+		elemType = value.Type()
+		elems = []reflect.Value{value}
+	default:
+		_, err := fmt.Fprintln(w, value.Interface())
+		return err
+	}
+
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		for _, elem := range elems {
+			fmt.Fprintln(w, cell(elem, nil))
+		}
+		return nil
+	}
+	columns := tableColumns(elemType, nil)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.header
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, elem := range elems {
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			cells[i] = cell(elem, c.index)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// tableColumns returns a column for every field of the struct type that fits
+// in a cell. Fields of nested structs are inlined, other slices and maps are
+// left out, as is the docType every record has.
+func tableColumns(t reflect.Type, index []int) []column {
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || name == "docType" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		switch {
+		case fieldType == timeType || fieldType.Implements(stringerType):
+		case fieldType.Kind() == reflect.Struct:
+			columns = append(columns, tableColumns(fieldType, fieldIndex)...)
+			continue
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() != reflect.String:
+			continue
+		case fieldType.Kind() == reflect.Map:
+			continue
+		}
+
+		columns = append(columns, column{header: header(name), index: fieldIndex})
+	}
+
+	return columns
+}
+
+// cell formats the field at the index of the row
+func cell(row reflect.Value, index []int) string {
+	value := row
+	for _, i := range index {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return ""
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04")
+	case []string:
+		return strings.Join(v, ",")
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// header turns a JSON field name such as approvedBuyer into APPROVED_BUYER
+func header(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
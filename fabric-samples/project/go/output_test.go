@@ -0,0 +1,131 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"fabcar/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintResult(t *testing.T) {
+	repairedAt := time.Date(2021, 3, 4, 15, 30, 0, 0, time.UTC)
+	car := &client.Car{
+		DocType: "car",
+		Id:      4,
+		Make:    "VW",
+		Model:   "Passat",
+		Color:   "blue",
+		Owner:   "2",
+		Status:  "ACTIVE",
+		Price:   client.NewAmount(700000, "EUR"),
+		ProposedShares: &client.SharesProposal{
+			ProposedBy:   "2",
+			Shares:       []client.OwnerShare{{Owner: "2", Percent: 60}, {Owner: "3", Percent: 40}},
+			SaleMajority: 100,
+			AcceptedBy:   []string{"2"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		result interface{}
+		want   string
+	}{
+		{
+			name:   "no result as table",
+			output: outputTable,
+			want:   "OK\n",
+		},
+		{
+			name:   "no result as JSON",
+			output: outputJSON,
+		},
+		{
+			name:   "value as table",
+			output: outputTable,
+			result: true,
+			want:   "true\n",
+		},
+		{
+			name:   "struct as JSON",
+			output: outputJSON,
+			result: &client.OwnerShare{Owner: "2", Percent: 60},
+			want:   "{\n  \"owner\": \"2\",\n  \"percent\": 60\n}\n",
+		},
+		{
+			name:   "struct as a single row",
+			output: outputTable,
+			result: &client.OwnerShare{Owner: "2", Percent: 60},
+			want: "OWNER  PERCENT\n" +
+				"2      60\n",
+		},
+		{
+			name:   "slice as a row per element",
+			output: outputTable,
+			result: []client.OwnerShare{{Owner: "2", Percent: 60}, {Owner: "13", Percent: 40}},
+			want: "OWNER  PERCENT\n" +
+				"2      60\n" +
+				"13     40\n",
+		},
+		{
+			name:   "amounts and times formatted, zero times empty",
+			output: outputTable,
+			result: []client.Malfunction{
+				{Id: "1", Description: "Broken brake", Price: client.NewAmount(20000, "EUR"), Severity: "HIGH", Status: "REPAIRED", RepairedAt: repairedAt},
+				{Id: "2", Description: "Flat tire", Price: client.NewAmount(5000, "EUR"), Severity: "LOW", Status: "OPEN"},
+			},
+			want: "ID  DESCRIPTION   PRICE       SEVERITY  STATUS    REPORTED_AT  REPORTED_BY  SHOP_ID  REPAIRED_AT       RECALL_ID\n" +
+				"1   Broken brake  200.00 EUR  HIGH      REPAIRED                                     2021-03-04 15:30  \n" +
+				"2   Flat tire     50.00 EUR   LOW       OPEN                                                           \n",
+		},
+		{
+			name:   "nested structs inlined, stringers in a cell, other slices left out",
+			output: outputTable,
+			result: []*client.OwnedCar{{Car: car, Percent: 60}},
+			want: "ID  VIN  MAKE  MODEL   YEAR  MILEAGE  COLOR  OWNER  STATUS  PRICE        APPROVED_BUYER  MILEAGE_TAMPERED  SALE_MAJORITY  SALE_CONSENTS  PROPOSED_SHARES            PERCENT\n" +
+				"4        VW    Passat  0     0        blue   2      ACTIVE  7000.00 EUR                  false             0                             2=60 3=40 (accepted by 2)  60\n",
+		},
+		{
+			name:   "index report as its drifted entries",
+			output: outputTable,
+			result: &client.IndexReport{
+				Cars:    6,
+				Missing: []client.IndexEntry{{Index: "color~id", Attributes: []string{"red", "4"}}},
+				Stale:   []client.IndexEntry{{Index: "owner~id", Attributes: []string{"3", "4"}}},
+			},
+			want: "DRIFT    INDEX     ATTRIBUTES\n" +
+				"missing  color~id  red,4\n" +
+				"stale    owner~id  3,4\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := printResult(&out, test.output, test.result)
+			require.NoError(t, err)
+			require.Equal(t, test.want, out.String())
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := map[string]string{
+		"id":               "ID",
+		"approvedBuyer":    "APPROVED_BUYER",
+		"mileageTampered":  "MILEAGE_TAMPERED",
+		"odometerReadings": "ODOMETER_READINGS",
+	}
+
+	for name, want := range tests {
+		require.Equal(t, want, header(name))
+	}
+}
@@ -15,6 +15,7 @@ CC_SRC_LANGUAGE=`echo "$CC_SRC_LANGUAGE" | tr [:upper:] [:lower:]`
 
 if [ "$CC_SRC_LANGUAGE" = "go" -o "$CC_SRC_LANGUAGE" = "golang" ] ; then
 	CC_SRC_PATH="../chaincode/project/go/"
+	CC_COLL_CONFIG="../chaincode/project/go/collections_config.json"
 elif [ "$CC_SRC_LANGUAGE" = "javascript" ]; then
 	CC_SRC_PATH="../chaincode/project/javascript/"
 elif [ "$CC_SRC_LANGUAGE" = "java" ]; then
@@ -37,7 +38,11 @@ rm -rf go/wallet/*
 pushd ../test-network
 ./network.sh down
 ./network.sh up createChannel -ca -s couchdb
-./network.sh deployCC -ccn cars -ccv 1 -cci initLedger -ccl ${CC_SRC_LANGUAGE} -ccp ${CC_SRC_PATH}
+# any single peer endorses keys without a policy of their own, such as
+# payments, so that owners of different organizations can trade; cars and
+# the owners' collections carry the policies of their organizations
+./network.sh deployCC -ccn cars -ccv 1 -cci initLedger -ccl ${CC_SRC_LANGUAGE} -ccp ${CC_SRC_PATH} -cccg ${CC_COLL_CONFIG:-NA} \
+	-ccep "OR('Org1MSP.peer','Org2MSP.peer','Org3MSP.peer','Org4MSP.peer')"
 popd
 
 cat <<EOF
@@ -115,14 +120,21 @@ Go:
   Start by changing into the "go" directory:
     cd go
 
-  Then, install dependencies and run the test using:
-    go run fabcar.go
-
-  The test will invoke the sample client app which perform the following:
-    - Import user credentials into the wallet (if they don't already exist there)
-    - Submit a transaction to create a new car
-    - Evaluate a transaction (query) to return details of this car
-    - Submit a transaction to change the owner of this car
-    - Evaluate a transaction (query) to return the updated details of this car
+  Then run the cars command line tool, which imports the user credentials into
+  the wallet the first time it runs:
+    ./cars.sh car get 4
+    ./cars.sh --json car list --color blue
+    ./cars.sh listen
+
+  The sample owners OWNER1 to OWNER3 are not bound to client identities, an
+  identity with the cars.admin attribute binds them with owner bind. Without
+  one, create an owner for the user and register a car of their own:
+    ./cars.sh owner create --name Ana --surname Anic --email ana@example.com
+    ./cars.sh car register 1M8GDM9AXKP042788 Fiat Punto 2012 120000 white 350000 OWNER4
+    ./cars.sh car recolor 7 red
+
+  Run ./cars.sh -h for every command and the flags that choose the channel,
+  chaincode, connection profile, MSP and wallet identity. The same settings can
+  be kept in a JSON file passed with --config.
 
 EOF